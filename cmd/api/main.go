@@ -4,7 +4,10 @@ import (
 	"context"
 	"demo-cosebase/cmd/injector"
 	"demo-cosebase/internal/api/handler"
+	"demo-cosebase/internal/services"
 	"github.com/joho/godotenv"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/samber/do"
 	"github.com/urfave/cli/v2"
 	"golang.org/x/sync/errgroup"
@@ -14,6 +17,7 @@ import (
 	"os/signal"
 	"strings"
 	"syscall"
+	"time"
 )
 
 func init() {
@@ -44,6 +48,16 @@ func commandServer(container *do.Injector) *cli.Command {
 				Value: "0.0.0.0:8080",
 				Usage: "serve address",
 			},
+			&cli.StringFlag{
+				Name:  "admin-addr",
+				Value: "127.0.0.1:9090",
+				Usage: "address of the admin listener serving /metrics, empty to disable",
+			},
+			&cli.DurationFlag{
+				Name:  "drain",
+				Value: 5 * time.Second,
+				Usage: "how long /readyz fails before the server shuts down, so load balancers stop sending requests",
+			},
 		},
 		Action: func(c *cli.Context) error {
 			vs := do.MustInvokeNamed[map[string]string](container, "envs")
@@ -56,11 +70,33 @@ func commandServer(container *do.Injector) *cli.Command {
 				return err
 			}
 
+			// publishes the scheduled chapters
+			servicePublish, err := do.Invoke[*services.ServicePublish](container)
+			if err != nil {
+				return err
+			}
+
+			serviceHealth, err := do.Invoke[*services.ServiceHealth](container)
+			if err != nil {
+				return err
+			}
+
 			srv := &http.Server{
 				Addr:    c.String("addr"),
 				Handler: router,
 			}
 
+			registry, err := do.Invoke[*prometheus.Registry](container)
+			if err != nil {
+				return err
+			}
+			adminMux := http.NewServeMux()
+			adminMux.Handle("/metrics", promhttp.HandlerFor(registry, promhttp.HandlerOpts{}))
+			adminSrv := &http.Server{
+				Addr:    c.String("admin-addr"),
+				Handler: adminMux,
+			}
+
 			ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
 			defer stop()
 
@@ -76,9 +112,35 @@ func commandServer(container *do.Injector) *cli.Command {
 
 			errWg.Go(func() error {
 				<-errCtx.Done()
+				// keep serving while load balancers see /readyz fail
+				serviceHealth.Drain()
+				if ctx.Err() != nil {
+					log.Printf("draining for %s\n", c.Duration("drain"))
+					time.Sleep(c.Duration("drain"))
+				}
 				return srv.Shutdown(context.TODO())
 			})
 
+			if adminSrv.Addr != "" {
+				errWg.Go(func() error {
+					log.Printf("ListenAndServe admin: %s\n", adminSrv.Addr)
+					if err := adminSrv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+						return err
+					}
+					return nil
+				})
+
+				errWg.Go(func() error {
+					<-errCtx.Done()
+					return adminSrv.Shutdown(context.TODO())
+				})
+			}
+
+			errWg.Go(func() error {
+				servicePublish.RunScheduler(errCtx, services.PublishSchedulerInterval)
+				return nil
+			})
+
 			return errWg.Wait()
 		},
 	}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"demo-cosebase/cmd/injector"
+	"demo-cosebase/pkg/caching"
+	"fmt"
+	"github.com/joho/godotenv"
+	"github.com/redis/go-redis/v9"
+	"github.com/samber/do"
+	"github.com/urfave/cli/v2"
+	"log"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+)
+
+func init() {
+	godotenv.Load("../../.env") // for develop
+	godotenv.Load("./.env")     // for production
+}
+
+func main() {
+	vs := map[string]string{}
+	container := injector.NewContainer(vs)
+	app := &cli.App{
+		Name:  "cache",
+		Usage: "maintain the api cache",
+		Commands: []*cli.Command{
+			commandPurge(container),
+		},
+	}
+
+	if err := app.Run(os.Args); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func commandPurge(container *do.Injector) *cli.Command {
+	return &cli.Command{
+		Name:  "purge",
+		Usage: "delete the keys matching a pattern",
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:     "pattern",
+				Required: true,
+				Usage:    "SCAN pattern of the keys, e.g. 'feed:category:*'",
+			},
+			&cli.BoolFlag{
+				Name:  "dry-run",
+				Usage: "only count the matching keys",
+			},
+			&cli.StringFlag{
+				Name:  "redis",
+				Value: "redis-cache",
+				Usage: "redis to purge (redis-cache, redis-db)",
+			},
+			&cli.IntFlag{
+				Name:  "batch",
+				Value: caching.DefaultPurgeBatch,
+				Usage: "keys per scan and pipeline",
+			},
+			&cli.Float64Flag{
+				Name:  "rate",
+				Value: 5000,
+				Usage: "keys deleted per second at most, 0 for no limit",
+			},
+		},
+		Action: func(c *cli.Context) error {
+			name := c.String("redis")
+			if name != "redis-cache" && name != "redis-db" {
+				return fmt.Errorf("unknown redis %q", name)
+			}
+			client, err := do.InvokeNamed[redis.UniversalClient](container, name)
+			if err != nil {
+				return err
+			}
+
+			ctx, stop := signal.NotifyContext(c.Context, syscall.SIGINT, syscall.SIGTERM)
+			defer stop()
+
+			started := time.Now()
+			var mu sync.Mutex
+			last := started
+			stats, err := caching.Purge(ctx, client, &caching.PurgeOptions{
+				Pattern: c.String("pattern"),
+				DryRun:  c.Bool("dry-run"),
+				Batch:   c.Int("batch"),
+				Rate:    c.Float64("rate"),
+				Progress: func(stats caching.PurgeStats) {
+					// cluster nodes report concurrently
+					mu.Lock()
+					defer mu.Unlock()
+					if time.Since(last) >= 5*time.Second {
+						last = time.Now()
+						log.Printf("purge: %d matched, %d deleted\n", stats.Matched, stats.Deleted)
+					}
+				},
+			})
+			if c.Bool("dry-run") {
+				log.Printf("purge %s: %d keys match (dry run) in %s\n", c.String("pattern"), stats.Matched, time.Since(started).Round(time.Millisecond))
+			} else {
+				log.Printf("purge %s: %d matched, %d deleted, %d untagged, %d batches failed in %s\n",
+					c.String("pattern"), stats.Matched, stats.Deleted, stats.Untagged, stats.Failed, time.Since(started).Round(time.Millisecond))
+			}
+			return err
+		},
+	}
+}
@@ -1,17 +1,34 @@
 package main
 
 import (
+	"context"
+	"demo-cosebase/cmd/injector"
+	"demo-cosebase/internal/crawler"
+	_ "demo-cosebase/internal/crawler/tangthuvien"
+	"demo-cosebase/internal/dedupe"
+	"demo-cosebase/internal/media"
+	"demo-cosebase/internal/models"
+	"demo-cosebase/internal/services"
 	"demo-cosebase/pkg"
+	"demo-cosebase/pkg/caching"
+	"demo-cosebase/pkg/fetcher"
+	"demo-cosebase/pkg/storage"
+	"demo-cosebase/pkg/textclean"
 	"fmt"
-	"github.com/PuerkitoBio/goquery"
 	"github.com/joho/godotenv"
+	"github.com/redis/go-redis/v9"
+	"github.com/samber/do"
 	"github.com/urfave/cli/v2"
-	"html"
 	"log"
+	"math"
+	"net/http"
 	"os"
-	"regexp"
+	"os/signal"
+	"path/filepath"
 	"strings"
 	"sync"
+	"syscall"
+	"time"
 )
 
 func init() {
@@ -19,16 +36,69 @@ func init() {
 	godotenv.Load("./.env")     // for production
 }
 
-const (
-	BaseURL = "https://tangthuvien.net/"
-)
-
 func main() {
 	app := &cli.App{
 		Name: "crawl",
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:  "source",
+				Value: "tangthuvien",
+				Usage: fmt.Sprintf("site to crawl (%s)", strings.Join(crawler.Sources(), ", ")),
+			},
+			&cli.StringFlag{
+				Name:  "user-agent",
+				Value: fetcher.DefaultUserAgent,
+				Usage: "user agent sent to the crawled sites",
+			},
+			&cli.Float64Flag{
+				Name:  "rate",
+				Value: fetcher.DefaultRate,
+				Usage: "requests per second allowed for each host",
+			},
+			&cli.DurationFlag{
+				Name:  "timeout",
+				Value: fetcher.DefaultTimeout,
+				Usage: "timeout of a single request",
+			},
+			&cli.IntFlag{
+				Name:  "retries",
+				Value: fetcher.DefaultMaxRetries,
+				Usage: "retries on 429 and 5xx responses",
+			},
+			&cli.BoolFlag{
+				Name:  "ignore-robots",
+				Usage: "do not check robots.txt",
+			},
+			&cli.StringFlag{
+				Name:  "clean-rules",
+				Usage: "json file with extra text cleaning rules for the source",
+			},
+			&cli.StringFlag{
+				Name:    "media-dir",
+				Value:   "media",
+				EnvVars: []string{"MEDIA_DIR"},
+				Usage:   "directory where story covers are mirrored",
+			},
+			&cli.BoolFlag{
+				Name:  "no-covers",
+				Usage: "do not mirror story covers",
+			},
+			&cli.StringFlag{
+				Name:  "record",
+				Usage: "save every response in this archive directory",
+			},
+			&cli.StringFlag{
+				Name:  "replay",
+				Usage: "answer requests from this archive directory instead of the network",
+			},
+		},
 		Commands: []*cli.Command{
 			commandCategory(),
-			commandNm(),
+			commandRanking(),
+			commandStory(),
+			commandDaemon(),
+			commandDuplicates(),
+			commandSeedArchive(),
 		},
 	}
 
@@ -37,162 +107,325 @@ func main() {
 	}
 }
 
+func newFetcher(c *cli.Context) (*fetcher.Fetcher, error) {
+	cfg := fetcher.DefaultConfig()
+	cfg.UserAgent = c.String("user-agent")
+	cfg.Rate = c.Float64("rate")
+	cfg.Timeout = c.Duration("timeout")
+	cfg.MaxRetries = c.Int("retries")
+	cfg.IgnoreRobots = c.Bool("ignore-robots")
+
+	record, replay := c.String("record"), c.String("replay")
+	switch {
+	case record != "" && replay != "":
+		return nil, fmt.Errorf("--record and --replay cannot be used together")
+	case record != "":
+		archive, err := fetcher.OpenArchive(record)
+		if err != nil {
+			return nil, err
+		}
+		// conditional requests would record 304s that cannot be replayed alone
+		cfg.Cache = nil
+		cfg.Transport = archive.Recorder(nil)
+	case replay != "":
+		archive, err := fetcher.OpenArchive(replay)
+		if err != nil {
+			return nil, err
+		}
+		cfg.Cache = nil
+		cfg.Transport = archive.Replayer()
+		cfg.Rate = math.Inf(1)
+		cfg.MaxRetries = 0
+	}
+	return fetcher.New(cfg), nil
+}
+
+// apiCache is the cache of the api, crawled stories are invalidated there. It is nil
+// when no cache redis is configured.
+var apiCache = sync.OnceValues(func() (caching.Cache, error) {
+	if os.Getenv("REDIS_CACHE") == "" && os.Getenv("CLUSTER_REDIS_CACHE") == "" {
+		return nil, nil
+	}
+	return do.Invoke[caching.Cache](injector.NewContainer(map[string]string{}))
+})
+
+func newCrawler(c *cli.Context, sourceName string) (*crawler.Crawler, error) {
+	f, err := newFetcher(c)
+	if err != nil {
+		return nil, err
+	}
+	source, err := crawler.New(sourceName, f)
+	if err != nil {
+		return nil, err
+	}
+
+	db, err := pkg.GetDb()
+	if err != nil {
+		return nil, err
+	}
+
+	cr, err := crawler.NewCrawler(source, db)
+	if err != nil {
+		return nil, err
+	}
+
+	cache, err := apiCache()
+	if err != nil {
+		return nil, err
+	}
+	if cache != nil {
+		cr.SetInvalidate(func(ctx context.Context, story *models.Story) {
+			services.InvalidateStory(ctx, cache, db, story)
+		})
+	}
+
+	if !c.Bool("no-covers") {
+		mediaStorage, err := storage.NewLocal(c.String("media-dir"))
+		if err != nil {
+			return nil, err
+		}
+		cr.SetCovers(media.NewCovers(mediaStorage, f))
+	}
+
+	if path := c.String("clean-rules"); path != "" {
+		rules, err := textclean.LoadRules(path)
+		if err != nil {
+			return nil, err
+		}
+		if err := cr.SetRules(rules); err != nil {
+			return nil, err
+		}
+	}
+	return cr, nil
+}
+
 func commandCategory() *cli.Command {
 	return &cli.Command{
 		Name:  "category",
 		Usage: "crawl category",
 		Action: func(c *cli.Context) error {
-			_, err := pkg.GetDb()
+			return record(c, &crawler.Job{Command: crawler.JobCategory})
+		},
+	}
+}
+
+func commandRanking() *cli.Command {
+	return &cli.Command{
+		Name:    "ranking",
+		Aliases: []string{"story-nominate"},
+		Usage:   "crawl the stories of a ranking",
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:  "rank",
+				Value: "nm",
+				Usage: "ranking name of the source",
+			},
+			&cli.IntFlag{
+				Name:  "page",
+				Value: 1,
+				Usage: "ranking page",
+			},
+			&cli.BoolFlag{
+				Name:  "chapters",
+				Usage: "also crawl the chapters of every story",
+			},
+		},
+		Action: func(c *cli.Context) error {
+			return record(c, &crawler.Job{
+				Command:  crawler.JobRanking,
+				Rank:     c.String("rank"),
+				Pages:    c.Int("page"),
+				Chapters: c.Bool("chapters"),
+			})
+		},
+	}
+}
+
+func commandStory() *cli.Command {
+	return &cli.Command{
+		Name:      "story",
+		Usage:     "crawl stories by url or slug",
+		ArgsUsage: "<url or slug>...",
+		Flags: []cli.Flag{
+			&cli.BoolFlag{
+				Name:  "chapters",
+				Usage: "also crawl the chapters",
+			},
+		},
+		Action: func(c *cli.Context) error {
+			if c.NArg() == 0 {
+				return fmt.Errorf("missing story url or slug")
+			}
+
+			return record(c, &crawler.Job{
+				Command:  crawler.JobStory,
+				Stories:  c.Args().Slice(),
+				Chapters: c.Bool("chapters"),
+			})
+		},
+	}
+}
+
+// record runs job and saves it in the crawl history.
+func record(c *cli.Context, job *crawler.Job) error {
+	cr, err := newCrawler(c, c.String("source"))
+	if err != nil {
+		return err
+	}
+
+	run, err := crawler.NewRun(job, models.CrawlTriggerCLI)
+	if err != nil {
+		return err
+	}
+	if err := cr.Record(c.Context, run, job); err != nil {
+		return err
+	}
+
+	log.Printf("crawl run %d: %d stories, %d chapters, %d errors\n", run.ID, run.StoriesFetched, run.ChaptersSaved, run.ErrorCount)
+	return nil
+}
+
+func commandDaemon() *cli.Command {
+	return &cli.Command{
+		Name:  "daemon",
+		Usage: "run the crawl jobs of a schedule file until SIGINT or SIGTERM",
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:  "schedule",
+				Value: "crawl-schedule.yml",
+				Usage: "yaml schedule file, see schedule.example.yml",
+			},
+			&cli.DurationFlag{
+				Name:  "grace",
+				Value: 30 * time.Second,
+				Usage: "time given to running jobs on shutdown before they are canceled",
+			},
+		},
+		Action: func(c *cli.Context) error {
+			file, err := crawler.LoadSchedule(c.String("schedule"))
 			if err != nil {
 				return err
 			}
 
-			htmlContent, err := pkg.FetchContent(fmt.Sprintf("%s%s", BaseURL, "tong-hop"))
+			// the admin api queues runs on its redis-db client, the daemon must pop them there
+			dbRedis, err := do.InvokeNamed[redis.UniversalClient](injector.NewContainer(map[string]string{}), "redis-db")
 			if err != nil {
 				return err
 			}
 
-			content := html.UnescapeString(htmlContent)
-
-			re, err := regexp.Compile(`<a.*?data-name="ctg".*?>([^<]+)</a>`)
+			db, err := pkg.GetDb()
 			if err != nil {
 				return err
 			}
-			matches := re.FindAllStringSubmatch(content, -1)
 
-			// Lưu kết quả vào slice
-			var categories string
-			for _, match := range matches {
-				//categories = append(categories, match[1]) // match[1] chứa nội dung bên trong thẻ <a>
-				categories = fmt.Sprintf("%s%s", categories, match[1])
+			factory := func(sourceName string) (*crawler.Crawler, error) {
+				if sourceName == "" {
+					sourceName = c.String("source")
+				}
+				return newCrawler(c, sourceName)
 			}
 
-			err = os.WriteFile("output.txt", []byte(categories), 0644)
+			daemon, err := crawler.NewDaemon(file, db, dbRedis, factory, c.Duration("grace"))
 			if err != nil {
 				return err
 			}
 
-			return nil
+			ctx, stop := signal.NotifyContext(c.Context, syscall.SIGINT, syscall.SIGTERM)
+			defer stop()
+
+			log.Printf("daemon: %d jobs scheduled\n", len(file.Jobs))
+			return daemon.Run(ctx)
 		},
 	}
 }
 
-func commandNm() *cli.Command {
+func commandDuplicates() *cli.Command {
 	return &cli.Command{
-		Name:  "story-nominate",
-		Usage: "crawl category",
+		Name:  "duplicates",
+		Usage: "queue stories crawled more than once for review in the admin api",
+		Flags: []cli.Flag{
+			&cli.Float64Flag{
+				Name:  "threshold",
+				Value: dedupe.DefaultThreshold,
+				Usage: "minimum score, from 0 to 1, of a queued pair",
+			},
+		},
 		Action: func(c *cli.Context) error {
-			_, err := pkg.GetDb()
+			db, err := pkg.GetDb()
 			if err != nil {
 				return err
 			}
 
-			htmlContent, err := pkg.FetchContent(fmt.Sprintf("%s%s", BaseURL, "tong-hop?rank=nm"))
+			found, err := dedupe.Scan(c.Context, db, c.Float64("threshold"))
 			if err != nil {
 				return err
 			}
 
-			content := html.UnescapeString(htmlContent)
+			log.Printf("found %d possible duplicates\n", found)
+			return nil
+		},
+	}
+}
 
-			re, err := regexp.Compile(`(?s)<div class="book-mid-info">.*?<h4><a href="([^"]+)"`)
+func commandSeedArchive() *cli.Command {
+	return &cli.Command{
+		Name:  "seed-archive",
+		Usage: "import saved story pages into a replay archive",
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:  "pages",
+				Value: "crawl",
+				Usage: "directory of story pages named <slug>.txt",
+			},
+			&cli.StringFlag{
+				Name:     "archive",
+				Required: true,
+				Usage:    "archive directory to use with --replay",
+			},
+		},
+		Action: func(c *cli.Context) error {
+			source, err := crawler.New(c.String("source"), nil)
 			if err != nil {
 				return err
 			}
-			matches := re.FindAllStringSubmatch(content, -1)
-
-			var wg sync.WaitGroup
-			limit := make(chan struct{}, 5)
-
-			for _, match := range matches {
-				wg.Add(1)
-				limit <- struct{}{}
+			storyURLs, ok := source.(crawler.StoryURLs)
+			if !ok {
+				return fmt.Errorf("source %s cannot build story urls", source.Name())
+			}
 
-				go func(url string) {
-					defer wg.Done()
-					defer func() { <-limit }()
+			archive, err := fetcher.OpenArchive(c.String("archive"))
+			if err != nil {
+				return err
+			}
 
-					err := crawlStory(url)
-					if err != nil {
-						log.Println(err)
-					}
-				}(match[1])
-				break
+			names, err := filepath.Glob(filepath.Join(c.String("pages"), "*.txt"))
+			if err != nil {
+				return err
+			}
+			for _, name := range names {
+				body, err := os.ReadFile(name)
+				if err != nil {
+					return err
+				}
+
+				storyURL, err := pkg.NormalizeURL(storyURLs.StoryURL(strings.TrimSuffix(filepath.Base(name), ".txt")))
+				if err != nil {
+					return err
+				}
+				err = archive.Save(&fetcher.Record{
+					Method:     http.MethodGet,
+					URL:        storyURL,
+					StatusCode: http.StatusOK,
+					Header:     http.Header{"Content-Type": {"text/html; charset=utf-8"}},
+					Body:       body,
+				})
+				if err != nil {
+					return err
+				}
 			}
 
-			wg.Wait()
-			fmt.Println("craw stories nominates successfully")
+			log.Printf("seed %d pages into %s\n", len(names), c.String("archive"))
 			return nil
 		},
 	}
 }
-
-func crawlStory(rawUrl string) error {
-	encodedUrl, err := pkg.NormalizeURL(rawUrl)
-	if err != nil {
-		return err
-	}
-
-	//	log.Println("Crawling:", encodedUrl) // Debug
-
-	htmlContent, err := pkg.FetchContent(encodedUrl)
-	if err != nil {
-		return err
-	}
-	unescapedContent := html.UnescapeString(htmlContent)
-	doc, err := goquery.NewDocumentFromReader(strings.NewReader(unescapedContent))
-	if err != nil {
-		log.Fatal(err)
-	}
-
-	content, err := doc.Find("div.book-information").Html()
-	if err != nil {
-		log.Fatal(err)
-	}
-
-	parts := strings.Split(encodedUrl, "/")
-	slug := parts[len(parts)-1]
-
-	// img
-
-	imgStoryUrlRegex, err := regexp.Compile(`(?s)class="book-img".*?<img src="(.*?)"`)
-	if err != nil {
-		return err
-	}
-	imgStoryContentMatch := imgStoryUrlRegex.FindStringSubmatch(content)
-	if len(imgStoryContentMatch) == 2 {
-		//	fmt.Println(slug, imgStoryContentMatch[1])
-	}
-
-	// title
-	titleRegex, err := regexp.Compile(`(?s)class="book-info.*?<h1>(.*?)</h1>`)
-	if err != nil {
-		return err
-	}
-	titleContentMatch := titleRegex.FindStringSubmatch(content)
-
-	description := doc.Find("div.book-intro").Text()
-
-	err = os.WriteFile("output.txt", []byte(description), 0644)
-	if err != nil {
-		return err
-	}
-	if len(titleContentMatch) == 2 {
-		fmt.Println(slug, titleContentMatch[1])
-	}
-
-	return nil
-}
-
-func crawlChapters(storyID int) error {
-	page, limit := 0, 75
-	for ; ; page++ {
-		urlPageChapter := getChapterUrl(storyID, page, limit)
-		resp, err := pkg.FetchContent(urlPageChapter)
-
-	}
-	return nil
-}
-
-func getChapterUrl(storyID, page, limit int) string {
-	return fmt.Sprintf("https://tangthuvien.net/doc-truyen/page/%d?page=%d&limit=%d", storyID, page, limit)
-}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"demo-cosebase/internal/datastore"
+	"demo-cosebase/internal/export"
+	"demo-cosebase/internal/media"
+	"demo-cosebase/pkg"
+	"demo-cosebase/pkg/storage"
+	"fmt"
+	"github.com/joho/godotenv"
+	"github.com/urfave/cli/v2"
+	"log"
+	"os"
+)
+
+func init() {
+	godotenv.Load("../../.env") // for develop
+	godotenv.Load("./.env")     // for production
+}
+
+func main() {
+	app := &cli.App{
+		Name:  "export",
+		Usage: "export stories to files",
+		Commands: []*cli.Command{
+			commandEPUB(),
+			commandMarkdown(),
+		},
+	}
+
+	if err := app.Run(os.Args); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func commandEPUB() *cli.Command {
+	return &cli.Command{
+		Name:      "epub",
+		Usage:     "write a story as an EPUB book",
+		ArgsUsage: "<slug>",
+		Flags: []cli.Flag{
+			&cli.IntFlag{
+				Name:  "from",
+				Usage: "first chapter number",
+			},
+			&cli.IntFlag{
+				Name:  "to",
+				Usage: "last chapter number",
+			},
+			&cli.StringFlag{
+				Name:    "out",
+				Aliases: []string{"o"},
+				Usage:   "output file, <slug>.epub by default",
+			},
+			&cli.StringFlag{
+				Name:    "media-dir",
+				Value:   "media",
+				EnvVars: []string{"MEDIA_DIR"},
+				Usage:   "directory of the mirrored covers",
+			},
+		},
+		Action: func(c *cli.Context) error {
+			if c.NArg() != 1 {
+				return fmt.Errorf("expected one story slug")
+			}
+			slug := c.Args().First()
+
+			db, err := pkg.GetDb()
+			if err != nil {
+				return err
+			}
+
+			story, err := datastore.FindStoryBySlug(c.Context, db, slug)
+			if err != nil {
+				return fmt.Errorf("story %s: %w", slug, err)
+			}
+
+			mediaStorage, err := storage.NewLocal(c.String("media-dir"))
+			if err != nil {
+				return err
+			}
+
+			out := c.String("out")
+			if out == "" {
+				out = slug + ".epub"
+			}
+			file, err := os.Create(out)
+			if err != nil {
+				return err
+			}
+
+			r := export.Range{From: c.Int("from"), To: c.Int("to")}
+			err = export.EPUB(c.Context, file, db, media.NewCovers(mediaStorage, nil), story, r)
+			if closeErr := file.Close(); err == nil {
+				err = closeErr
+			}
+			if err != nil {
+				os.Remove(out)
+				return err
+			}
+
+			log.Printf("export %s to %s successfully\n", slug, out)
+			return nil
+		},
+	}
+}
+
+func commandMarkdown() *cli.Command {
+	return &cli.Command{
+		Name:      "markdown",
+		Usage:     "write a story as a directory of markdown files, see the import command",
+		ArgsUsage: "<slug>",
+		Flags: []cli.Flag{
+			&cli.IntFlag{
+				Name:  "from",
+				Usage: "first chapter number",
+			},
+			&cli.IntFlag{
+				Name:  "to",
+				Usage: "last chapter number",
+			},
+			&cli.StringFlag{
+				Name:    "out",
+				Aliases: []string{"o"},
+				Usage:   "output directory, <slug> by default",
+			},
+		},
+		Action: func(c *cli.Context) error {
+			if c.NArg() != 1 {
+				return fmt.Errorf("expected one story slug")
+			}
+			slug := c.Args().First()
+
+			db, err := pkg.GetDb()
+			if err != nil {
+				return err
+			}
+
+			story, err := datastore.FindStoryBySlug(c.Context, db, slug)
+			if err != nil {
+				return fmt.Errorf("story %s: %w", slug, err)
+			}
+
+			out := c.String("out")
+			if out == "" {
+				out = slug
+			}
+			written, err := export.Markdown(c.Context, out, db, story, export.Range{From: c.Int("from"), To: c.Int("to")})
+			if err != nil {
+				return err
+			}
+
+			log.Printf("export %s with %d chapters to %s successfully\n", slug, written, out)
+			return nil
+		},
+	}
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"demo-cosebase/cmd/injector"
+	"demo-cosebase/internal/export"
+	"demo-cosebase/internal/services"
+	"demo-cosebase/pkg"
+	"demo-cosebase/pkg/caching"
+	"errors"
+	"fmt"
+	"github.com/joho/godotenv"
+	"github.com/samber/do"
+	"github.com/urfave/cli/v2"
+	"log"
+	"os"
+)
+
+func init() {
+	godotenv.Load("../../.env") // for develop
+	godotenv.Load("./.env")     // for production
+}
+
+func main() {
+	app := &cli.App{
+		Name:  "import",
+		Usage: "import stories from files",
+		Commands: []*cli.Command{
+			commandMarkdown(),
+		},
+	}
+
+	if err := app.Run(os.Args); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func commandMarkdown() *cli.Command {
+	return &cli.Command{
+		Name:      "markdown",
+		Usage:     "import a story directory written by export markdown or by a translator",
+		ArgsUsage: "<dir>",
+		Flags: []cli.Flag{
+			&cli.BoolFlag{
+				Name:  "allow-gaps",
+				Usage: "accept missing chapter numbers",
+			},
+			&cli.BoolFlag{
+				Name:  "overwrite",
+				Usage: "replace stored chapters of the same source that differ",
+			},
+			&cli.BoolFlag{
+				Name:  "dry-run",
+				Usage: "check the import against the database without saving it",
+			},
+		},
+		Action: func(c *cli.Context) error {
+			if c.NArg() != 1 {
+				return fmt.Errorf("expected one directory")
+			}
+
+			story, err := export.ReadMarkdown(c.Args().First(), c.Bool("allow-gaps"))
+			if err != nil {
+				return err
+			}
+
+			db, err := pkg.GetDb()
+			if err != nil {
+				return err
+			}
+
+			result, err := export.ImportMarkdown(c.Context, db, story, export.ImportOptions{
+				Overwrite: c.Bool("overwrite"),
+				DryRun:    c.Bool("dry-run"),
+			})
+			if errors.Is(err, export.ErrConflicts) {
+				return fmt.Errorf("%w, use --overwrite to replace them: %v", err, result.Conflicts)
+			}
+			if err != nil {
+				return err
+			}
+
+			prefix := ""
+			if c.Bool("dry-run") {
+				prefix = "dry run: "
+			} else if os.Getenv("REDIS_CACHE") != "" || os.Getenv("CLUSTER_REDIS_CACHE") != "" {
+				cache, err := do.Invoke[caching.Cache](injector.NewContainer(map[string]string{}))
+				if err != nil {
+					return err
+				}
+				services.InvalidateStory(c.Context, cache, db, result.Story)
+			}
+			log.Printf("%simport %s: %d chapters inserted, %d updated, %d unchanged\n",
+				prefix, result.Story.Slug, result.Inserted, result.Updated, result.Unchanged)
+			return nil
+		},
+	}
+}
@@ -4,17 +4,24 @@ import (
 	"database/sql"
 	"demo-cosebase/internal/services"
 	"demo-cosebase/pkg/caching"
+	"demo-cosebase/pkg/metrics"
+	"demo-cosebase/pkg/storage"
 	"fmt"
 	"github.com/hiendaovinh/toolkit/pkg/db"
 	"github.com/joho/godotenv"
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/redis/go-redis/v9"
 	"github.com/samber/do"
 	"github.com/uptrace/bun"
 	"github.com/uptrace/bun/dialect/pgdialect"
 	"github.com/uptrace/bun/driver/pgdriver"
 	"os"
+	"slices"
 )
 
+// RedisClients are the named redis clients of the container.
+var RedisClients = []string{"redis-db", "redis-cache", "redis-cache-readonly"}
+
 func init() {
 	godotenv.Load("../../.env") // for develop
 	godotenv.Load("./.env")     // for production
@@ -30,6 +37,10 @@ func NewContainer(vs map[string]string) *do.Injector {
 	if vs["API_ORIGINS"] == "" {
 		vs["API_ORIGINS"] = "*"
 	}
+	vs["SITE_URL"] = os.Getenv("SITE_URL")
+	if vs["SITE_URL"] == "" {
+		vs["SITE_URL"] = "http://localhost:8080"
+	}
 
 	do.ProvideNamedValue(injector, "envs", vs)
 
@@ -41,6 +52,8 @@ func NewContainer(vs map[string]string) *do.Injector {
 		sqlDb.SetMaxOpenConns(50)
 		sqlDb.SetMaxIdleConns(20)
 		if err := sqlDb.Ping(); err != nil {
+			// the provider runs again on the next invoke, e.g. every readiness probe
+			sqlDb.Close()
 			return nil, fmt.Errorf("failed to connect to database: %w", err)
 		}
 		db := bun.NewDB(sqlDb, pgdialect.New())
@@ -101,27 +114,165 @@ func NewContainer(vs map[string]string) *do.Injector {
 		})
 	})
 
+	// caches keeping hot keys in process memory, a write on one api instance evicts the
+	// local copies of the others over pub/sub
+	localCaches := map[string]bool{
+		"redis-cache":          os.Getenv("CACHE_LOCAL") == "true",
+		"redis-cache-readonly": os.Getenv("CACHE_READONLY_LOCAL") == "true",
+	}
+	syncLocal := localCaches["redis-cache"] || localCaches["redis-cache-readonly"]
+
 	do.Provide(injector, func(i *do.Injector) (caching.Cache, error) {
+		// single node deployments can do without a cache redis
+		if os.Getenv("CACHE_DRIVER") == "memory" {
+			return caching.NewCacheMemory(caching.DefaultMemorySize, caching.EvictLFU), nil
+		}
+
 		dbRedis, err := do.InvokeNamed[redis.UniversalClient](i, "redis-cache")
 		if err != nil {
 			return nil, err
 		}
 
-		return caching.NewCacheRedis(dbRedis, false)
+		cache, err := caching.NewCacheRedis(dbRedis, localCaches["redis-cache"])
+		if err != nil {
+			return nil, err
+		}
+		// coalesce misses across api instances
+		if os.Getenv("CACHE_LOCK") == "true" {
+			cache.EnableLock(caching.DefaultLockTTL, caching.DefaultLockWait)
+		}
+		if syncLocal {
+			return cache.SyncLocal(caching.DefaultInvalidationChannel)
+		}
+		return cache, nil
 	})
 
 	do.Provide(injector, func(i *do.Injector) (caching.ReadOnlyCache, error) {
+		// there is no replica of a memory cache, reads must see the writes
+		if os.Getenv("CACHE_DRIVER") == "memory" {
+			return do.Invoke[caching.Cache](i)
+		}
+
 		dbRedis, err := do.InvokeNamed[redis.UniversalClient](i, "redis-cache-readonly")
 		if err != nil {
 			return nil, err
 		}
 
-		return caching.NewCacheRedis(dbRedis, false)
+		cache, err := caching.NewCacheRedis(dbRedis, localCaches["redis-cache-readonly"])
+		if err != nil {
+			return nil, err
+		}
+		if syncLocal {
+			return cache.SyncLocal(caching.DefaultInvalidationChannel)
+		}
+		return cache, nil
+	})
+
+	do.Provide(injector, func(i *do.Injector) (storage.Storage, error) {
+		mediaDir := os.Getenv("MEDIA_DIR")
+		if mediaDir == "" {
+			mediaDir = "media"
+		}
+		return storage.NewLocal(mediaDir)
 	})
 
 	do.Provide(injector, func(i *do.Injector) (*services.ServiceUser, error) {
 		return services.NewServiceUser(injector)
 	})
 
+	do.Provide(injector, func(i *do.Injector) (*services.ServiceMedia, error) {
+		return services.NewServiceMedia(injector)
+	})
+
+	do.Provide(injector, func(i *do.Injector) (*services.ServiceCrawl, error) {
+		return services.NewServiceCrawl(injector)
+	})
+
+	do.Provide(injector, func(i *do.Injector) (*services.ServiceDuplicate, error) {
+		return services.NewServiceDuplicate(injector)
+	})
+
+	do.Provide(injector, func(i *do.Injector) (*services.ServiceStory, error) {
+		return services.NewServiceStory(injector)
+	})
+
+	do.Provide(injector, func(i *do.Injector) (*services.ServiceFeed, error) {
+		return services.NewServiceFeed(injector)
+	})
+
+	do.Provide(injector, func(i *do.Injector) (*services.ServicePublish, error) {
+		return services.NewServicePublish(injector)
+	})
+
+	do.Provide(injector, func(i *do.Injector) (*services.ServiceNotification, error) {
+		return services.NewServiceNotification(injector)
+	})
+
+	do.Provide(injector, func(i *do.Injector) (*services.ServiceModeration, error) {
+		return services.NewServiceModeration(injector)
+	})
+
+	do.Provide(injector, func(i *do.Injector) (*services.ServiceReading, error) {
+		return services.NewServiceReading(injector)
+	})
+
+	do.Provide(injector, func(i *do.Injector) (*services.ServiceRecommendation, error) {
+		return services.NewServiceRecommendation(injector)
+	})
+
+	do.Provide(injector, func(i *do.Injector) (*services.ServiceSettings, error) {
+		return services.NewServiceSettings(injector)
+	})
+
+	do.Provide(injector, func(i *do.Injector) (*services.ServiceCache, error) {
+		return services.NewServiceCache(injector)
+	})
+
+	do.Provide(injector, func(i *do.Injector) (*services.ServiceHealth, error) {
+		return services.NewServiceHealth(injector, RedisClients)
+	})
+
+	do.Provide(injector, func(i *do.Injector) (*prometheus.Registry, error) {
+		registry, err := metrics.NewRegistry()
+		if err != nil {
+			return nil, err
+		}
+
+		// connections nothing used yet are not opened just to be scraped
+		invoked := func(name string) bool {
+			return slices.Contains(i.ListInvokedServices(), name)
+		}
+		err = registry.Register(metrics.NewDBCollector(func() (*sql.DB, bool) {
+			// the name do gives unnamed services
+			if !invoked(fmt.Sprintf("%T", (*bun.DB)(nil))) {
+				return nil, false
+			}
+			postgresDB, err := do.Invoke[*bun.DB](i)
+			if err != nil {
+				return nil, false
+			}
+			return postgresDB.DB, true
+		}))
+		if err != nil {
+			return nil, err
+		}
+		err = registry.Register(metrics.NewRedisCollector(RedisClients, func(name string) (redis.UniversalClient, bool) {
+			if !invoked(name) {
+				return nil, false
+			}
+			client, err := do.InvokeNamed[redis.UniversalClient](i, name)
+			return client, err == nil
+		}))
+		return registry, err
+	})
+
+	do.Provide(injector, func(i *do.Injector) (*metrics.HTTP, error) {
+		registry, err := do.Invoke[*prometheus.Registry](i)
+		if err != nil {
+			return nil, err
+		}
+		return metrics.NewHTTP(registry)
+	})
+
 	return injector
 }
@@ -48,6 +48,68 @@ func commandMigration() *cli.Command {
 				log.Fatal(err)
 			}
 
+			log.Println("Start migrate story tables")
+			err = datastore.CreateTableStory(ctx, db)
+			if err != nil {
+				log.Fatal(err)
+			}
+			err = datastore.CreateTableStorySource(ctx, db)
+			if err != nil {
+				log.Fatal(err)
+			}
+			err = datastore.CreateTableCategory(ctx, db)
+			if err != nil {
+				log.Fatal(err)
+			}
+
+			log.Println("Start migrate chapter table")
+			err = datastore.CreateTableChapter(ctx, db)
+			if err != nil {
+				log.Fatal(err)
+			}
+
+			log.Println("Start migrate follow table")
+			err = datastore.CreateTableFollow(ctx, db)
+			if err != nil {
+				log.Fatal(err)
+			}
+
+			log.Println("Start migrate comment table")
+			err = datastore.CreateTableComment(ctx, db)
+			if err != nil {
+				log.Fatal(err)
+			}
+
+			log.Println("Start migrate story duplicate table")
+			err = datastore.CreateTableStoryDuplicate(ctx, db)
+			if err != nil {
+				log.Fatal(err)
+			}
+
+			log.Println("Start migrate crawl run tables")
+			err = datastore.CreateTableCrawlRun(ctx, db)
+			if err != nil {
+				log.Fatal(err)
+			}
+
+			log.Println("Start migrate notification table")
+			err = datastore.CreateTableNotification(ctx, db)
+			if err != nil {
+				log.Fatal(err)
+			}
+
+			log.Println("Start migrate moderation tables")
+			err = datastore.CreateTableModeration(ctx, db)
+			if err != nil {
+				log.Fatal(err)
+			}
+
+			log.Println("Start migrate reading tables")
+			err = datastore.CreateTableReading(ctx, db)
+			if err != nil {
+				log.Fatal(err)
+			}
+
 			log.Println("Migration success")
 
 			return nil
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"demo-cosebase/cmd/injector"
+	"demo-cosebase/internal/recommend"
+	"github.com/joho/godotenv"
+	"github.com/redis/go-redis/v9"
+	"github.com/samber/do"
+	"github.com/uptrace/bun"
+	"github.com/urfave/cli/v2"
+	"log"
+	"os"
+)
+
+func init() {
+	godotenv.Load("../../.env") // for develop
+	godotenv.Load("./.env")     // for production
+}
+
+func main() {
+	vs := map[string]string{}
+	container := injector.NewContainer(vs)
+	app := &cli.App{
+		Name:  "recommend",
+		Usage: "precompute story recommendations",
+		Commands: []*cli.Command{
+			commandBuild(container),
+		},
+	}
+
+	if err := app.Run(os.Args); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// commandBuild is meant to run from cron, daily or more often, the lists expire after
+// --ttl if builds stop.
+func commandBuild(container *do.Injector) *cli.Command {
+	return &cli.Command{
+		Name:  "build",
+		Usage: "compute similar stories and user recommendations into Redis",
+		Flags: []cli.Flag{
+			&cli.DurationFlag{
+				Name:  "ttl",
+				Value: recommend.DefaultTTL,
+				Usage: "lifetime of the stored lists",
+			},
+			&cli.IntFlag{
+				Name:  "min-co-readers",
+				Value: recommend.DefaultOptions.MinCoReaders,
+				Usage: "readers two stories must share to be similar",
+			},
+		},
+		Action: func(c *cli.Context) error {
+			db, err := do.Invoke[*bun.DB](container)
+			if err != nil {
+				return err
+			}
+			client, err := do.InvokeNamed[redis.UniversalClient](container, "redis-db")
+			if err != nil {
+				return err
+			}
+
+			opts := recommend.DefaultOptions
+			opts.MinCoReaders = c.Int("min-co-readers")
+			stats, err := recommend.Run(c.Context, db, client, opts, c.Duration("ttl"))
+			if err != nil {
+				return err
+			}
+			log.Printf("recommend: %d interactions, %d users and %d stories with recommendations in %s\n",
+				stats.Interactions, stats.Users, stats.Stories, stats.Duration.Round(1e6))
+			return nil
+		},
+	}
+}
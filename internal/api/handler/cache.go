@@ -0,0 +1,71 @@
+package handler
+
+import (
+	"demo-cosebase/internal/services"
+	"github.com/hiendaovinh/toolkit/pkg/errorx"
+	"github.com/hiendaovinh/toolkit/pkg/httpx-echo"
+	"github.com/labstack/echo/v4"
+	"github.com/samber/do"
+	"net/http"
+)
+
+type groupCache struct {
+	container *do.Injector
+}
+
+func (gr *groupCache) Metrics(c echo.Context) error {
+	serviceCache, err := do.Invoke[*services.ServiceCache](gr.container)
+	if err != nil {
+		return httpx.RestAbort(c, nil, errorx.Wrap(err, errorx.Service))
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{"metrics": serviceCache.Metrics()})
+}
+
+func (gr *groupCache) InspectKey(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	serviceCache, err := do.Invoke[*services.ServiceCache](gr.container)
+	if err != nil {
+		return httpx.RestAbort(c, nil, errorx.Wrap(err, errorx.Service))
+	}
+
+	info, err := serviceCache.Inspect(ctx, c.Param("key"))
+	if err != nil {
+		return httpx.RestAbort(c, nil, err)
+	}
+
+	return c.JSON(http.StatusOK, info)
+}
+
+func (gr *groupCache) DeleteKey(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	serviceCache, err := do.Invoke[*services.ServiceCache](gr.container)
+	if err != nil {
+		return httpx.RestAbort(c, nil, errorx.Wrap(err, errorx.Service))
+	}
+
+	key := c.Param("key")
+	if err := serviceCache.Delete(ctx, key); err != nil {
+		return httpx.RestAbort(c, nil, err)
+	}
+
+	return c.JSON(http.StatusOK, map[string]string{"deleted": key})
+}
+
+func (gr *groupCache) InvalidateTag(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	serviceCache, err := do.Invoke[*services.ServiceCache](gr.container)
+	if err != nil {
+		return httpx.RestAbort(c, nil, errorx.Wrap(err, errorx.Service))
+	}
+
+	tag := c.Param("tag")
+	if err := serviceCache.InvalidateTag(ctx, tag); err != nil {
+		return httpx.RestAbort(c, nil, err)
+	}
+
+	return c.JSON(http.StatusOK, map[string]string{"invalidated": tag})
+}
@@ -0,0 +1,86 @@
+package handler
+
+import (
+	"demo-cosebase/internal/datastore"
+	"demo-cosebase/internal/models"
+	"demo-cosebase/internal/services"
+	"errors"
+	"github.com/hiendaovinh/toolkit/pkg/errorx"
+	"github.com/hiendaovinh/toolkit/pkg/httpx-echo"
+	"github.com/labstack/echo/v4"
+	"github.com/samber/do"
+	"net/http"
+	"strconv"
+)
+
+type groupCrawl struct {
+	container *do.Injector
+}
+
+func (gr *groupCrawl) List(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	serviceCrawl, err := do.Invoke[*services.ServiceCrawl](gr.container)
+	if err != nil {
+		return httpx.RestAbort(c, nil, errorx.Wrap(err, errorx.Service))
+	}
+
+	filter := &datastore.CrawlRunFilter{
+		Source: c.QueryParam("source"),
+		Status: c.QueryParam("status"),
+		Limit:  httpx.QueryParamInt(c, "limit", services.CrawlRunsDefaultLimit),
+		Offset: httpx.QueryParamInt(c, "offset", 0),
+	}
+	runs, total, err := serviceCrawl.ListRuns(ctx, filter)
+	if err != nil {
+		return httpx.RestAbort(c, nil, errorx.Wrap(err, errorx.Database))
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{"runs": runs, "total": total})
+}
+
+func (gr *groupCrawl) Get(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	ID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		return httpx.RestAbort(c, nil, errorx.Wrap(errors.New("invalid crawl run id"), errorx.Invalid))
+	}
+
+	serviceCrawl, err := do.Invoke[*services.ServiceCrawl](gr.container)
+	if err != nil {
+		return httpx.RestAbort(c, nil, errorx.Wrap(err, errorx.Service))
+	}
+
+	run, err := serviceCrawl.FindRun(ctx, ID)
+	if err != nil {
+		return httpx.RestAbort(c, nil, err)
+	}
+
+	return c.JSON(http.StatusOK, run)
+}
+
+func (gr *groupCrawl) Create(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	var req models.CrawlRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request payload"})
+	}
+
+	if err := c.Validate(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Validation failed"})
+	}
+
+	serviceCrawl, err := do.Invoke[*services.ServiceCrawl](gr.container)
+	if err != nil {
+		return httpx.RestAbort(c, nil, errorx.Wrap(err, errorx.Service))
+	}
+
+	run, err := serviceCrawl.TriggerRun(ctx, &req)
+	if err != nil {
+		return httpx.RestAbort(c, nil, err)
+	}
+
+	return c.JSON(http.StatusAccepted, run)
+}
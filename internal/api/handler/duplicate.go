@@ -0,0 +1,112 @@
+package handler
+
+import (
+	"demo-cosebase/internal/dedupe"
+	"demo-cosebase/internal/models"
+	"demo-cosebase/internal/services"
+	"errors"
+	"github.com/hiendaovinh/toolkit/pkg/errorx"
+	"github.com/hiendaovinh/toolkit/pkg/httpx-echo"
+	"github.com/labstack/echo/v4"
+	"github.com/samber/do"
+	"net/http"
+	"strconv"
+)
+
+type groupDuplicate struct {
+	container *do.Injector
+}
+
+func (gr *groupDuplicate) List(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	serviceDuplicate, err := do.Invoke[*services.ServiceDuplicate](gr.container)
+	if err != nil {
+		return httpx.RestAbort(c, nil, errorx.Wrap(err, errorx.Service))
+	}
+
+	status := c.QueryParam("status")
+	if status == "" {
+		status = models.DuplicatePending
+	}
+	duplicates, total, err := serviceDuplicate.List(ctx, status,
+		httpx.QueryParamInt(c, "limit", services.DuplicatesDefaultLimit),
+		httpx.QueryParamInt(c, "offset", 0))
+	if err != nil {
+		return httpx.RestAbort(c, nil, errorx.Wrap(err, errorx.Database))
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{"duplicates": duplicates, "total": total})
+}
+
+func (gr *groupDuplicate) Scan(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	threshold := dedupe.DefaultThreshold
+	if v := c.QueryParam("threshold"); v != "" {
+		var err error
+		threshold, err = strconv.ParseFloat(v, 64)
+		if err != nil {
+			return httpx.RestAbort(c, nil, errorx.Wrap(errors.New("invalid threshold"), errorx.Invalid))
+		}
+	}
+
+	serviceDuplicate, err := do.Invoke[*services.ServiceDuplicate](gr.container)
+	if err != nil {
+		return httpx.RestAbort(c, nil, errorx.Wrap(err, errorx.Service))
+	}
+
+	found, err := serviceDuplicate.Scan(ctx, threshold)
+	if err != nil {
+		return httpx.RestAbort(c, nil, errorx.Wrap(err, errorx.Database))
+	}
+
+	return c.JSON(http.StatusOK, map[string]int{"found": found})
+}
+
+func (gr *groupDuplicate) Merge(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	ID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		return httpx.RestAbort(c, nil, errorx.Wrap(errors.New("invalid duplicate id"), errorx.Invalid))
+	}
+
+	var req models.MergeDuplicateRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request payload"})
+	}
+
+	serviceDuplicate, err := do.Invoke[*services.ServiceDuplicate](gr.container)
+	if err != nil {
+		return httpx.RestAbort(c, nil, errorx.Wrap(err, errorx.Service))
+	}
+
+	duplicate, err := serviceDuplicate.Merge(ctx, ID, req.KeepID, c.Get("user").(*models.User))
+	if err != nil {
+		return httpx.RestAbort(c, nil, err)
+	}
+
+	return c.JSON(http.StatusOK, duplicate)
+}
+
+func (gr *groupDuplicate) Dismiss(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	ID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		return httpx.RestAbort(c, nil, errorx.Wrap(errors.New("invalid duplicate id"), errorx.Invalid))
+	}
+
+	serviceDuplicate, err := do.Invoke[*services.ServiceDuplicate](gr.container)
+	if err != nil {
+		return httpx.RestAbort(c, nil, errorx.Wrap(err, errorx.Service))
+	}
+
+	duplicate, err := serviceDuplicate.Dismiss(ctx, ID, c.Get("user").(*models.User))
+	if err != nil {
+		return httpx.RestAbort(c, nil, err)
+	}
+
+	return c.JSON(http.StatusOK, duplicate)
+}
@@ -0,0 +1,118 @@
+package handler
+
+import (
+	"demo-cosebase/internal/models"
+	"demo-cosebase/internal/services"
+	"demo-cosebase/pkg/atom"
+	"errors"
+	"github.com/hiendaovinh/toolkit/pkg/errorx"
+	"github.com/hiendaovinh/toolkit/pkg/httpx-echo"
+	"github.com/labstack/echo/v4"
+	"github.com/samber/do"
+	"net/http"
+	"strings"
+	"time"
+)
+
+type groupFeed struct {
+	container *do.Injector
+}
+
+func (gr *groupFeed) Story(c echo.Context) error {
+	slug, ok := strings.CutSuffix(c.Param("file"), ".atom")
+	if !ok {
+		return httpx.RestAbort(c, nil, errorx.Wrap(errors.New("feed not found"), errorx.NotExist))
+	}
+
+	serviceFeed, err := do.Invoke[*services.ServiceFeed](gr.container)
+	if err != nil {
+		return httpx.RestAbort(c, nil, errorx.Wrap(err, errorx.Service))
+	}
+
+	feed, err := serviceFeed.StoryFeed(c.Request().Context(), slug)
+	if err != nil {
+		return httpx.RestAbort(c, nil, err)
+	}
+	return serveFeed(c, feed, "public")
+}
+
+func (gr *groupFeed) Category(c echo.Context) error {
+	slug, ok := strings.CutSuffix(c.Param("file"), ".atom")
+	if !ok {
+		return httpx.RestAbort(c, nil, errorx.Wrap(errors.New("feed not found"), errorx.NotExist))
+	}
+
+	serviceFeed, err := do.Invoke[*services.ServiceFeed](gr.container)
+	if err != nil {
+		return httpx.RestAbort(c, nil, errorx.Wrap(err, errorx.Service))
+	}
+
+	feed, err := serviceFeed.CategoryFeed(c.Request().Context(), slug)
+	if err != nil {
+		return httpx.RestAbort(c, nil, err)
+	}
+	return serveFeed(c, feed, "public")
+}
+
+// Followed is the private feed of a user, the token in the url replaces the login
+// feed readers cannot do.
+func (gr *groupFeed) Followed(c echo.Context) error {
+	token, ok := strings.CutSuffix(c.Param("file"), ".atom")
+	if !ok || token == "" {
+		return httpx.RestAbort(c, nil, errorx.Wrap(errors.New("feed not found"), errorx.NotExist))
+	}
+
+	serviceFeed, err := do.Invoke[*services.ServiceFeed](gr.container)
+	if err != nil {
+		return httpx.RestAbort(c, nil, errorx.Wrap(err, errorx.Service))
+	}
+
+	feed, err := serviceFeed.FollowedFeed(c.Request().Context(), token)
+	if err != nil {
+		return httpx.RestAbort(c, nil, err)
+	}
+	return serveFeed(c, feed, "private")
+}
+
+func (gr *groupFeed) RotateToken(c echo.Context) error {
+	serviceFeed, err := do.Invoke[*services.ServiceFeed](gr.container)
+	if err != nil {
+		return httpx.RestAbort(c, nil, errorx.Wrap(err, errorx.Service))
+	}
+
+	token, err := serviceFeed.RotateFeedToken(c.Request().Context(), c.Get("user").(*models.User))
+	if err != nil {
+		return httpx.RestAbort(c, nil, err)
+	}
+	return c.JSON(http.StatusOK, token)
+}
+
+func serveFeed(c echo.Context, feed *services.RenderedFeed, visibility string) error {
+	updated := time.Unix(feed.Updated, 0).UTC()
+	header := c.Response().Header()
+	header.Set("ETag", feed.ETag)
+	header.Set(echo.HeaderLastModified, updated.Format(http.TimeFormat))
+	header.Set(echo.HeaderCacheControl, visibility+", max-age=300")
+
+	if notModified(c.Request(), feed.ETag, updated) {
+		return c.NoContent(http.StatusNotModified)
+	}
+	return c.Blob(http.StatusOK, atom.ContentType, feed.Body)
+}
+
+// notModified follows RFC 9110: If-None-Match wins over If-Modified-Since.
+func notModified(r *http.Request, etag string, modified time.Time) bool {
+	if match := r.Header.Get("If-None-Match"); match != "" {
+		for _, candidate := range strings.Split(match, ",") {
+			candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+			if candidate == etag || candidate == "*" {
+				return true
+			}
+		}
+		return false
+	}
+	if since, err := http.ParseTime(r.Header.Get("If-Modified-Since")); err == nil {
+		return !modified.Truncate(time.Second).After(since)
+	}
+	return false
+}
@@ -0,0 +1,35 @@
+package handler
+
+import (
+	"demo-cosebase/internal/services"
+	"github.com/hiendaovinh/toolkit/pkg/errorx"
+	"github.com/hiendaovinh/toolkit/pkg/httpx-echo"
+	"github.com/labstack/echo/v4"
+	"github.com/samber/do"
+	"net/http"
+)
+
+type groupHealth struct {
+	container *do.Injector
+}
+
+// Live only tells the process serves requests, a dependency being down is no reason to restart it.
+func (gr *groupHealth) Live(c echo.Context) error {
+	return c.JSON(http.StatusOK, services.HealthReport{Status: services.HealthOK})
+}
+
+func (gr *groupHealth) Ready(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	serviceHealth, err := do.Invoke[*services.ServiceHealth](gr.container)
+	if err != nil {
+		return httpx.RestAbort(c, nil, errorx.Wrap(err, errorx.Service))
+	}
+
+	// degraded still takes traffic
+	report := serviceHealth.Ready(ctx)
+	if report.Status == services.HealthFailing {
+		return c.JSON(http.StatusServiceUnavailable, report)
+	}
+	return c.JSON(http.StatusOK, report)
+}
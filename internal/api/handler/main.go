@@ -1,6 +1,8 @@
 package handler
 
 import (
+	"demo-cosebase/internal/models"
+	"demo-cosebase/pkg/metrics"
 	"github.com/go-playground/validator/v10"
 	"github.com/hiendaovinh/toolkit/pkg/httpx-echo"
 	"github.com/labstack/echo-contrib/pprof"
@@ -37,6 +39,12 @@ func New(cfg *Config) (http.Handler, error) {
 	r.Use(middleware.LoggerWithConfig(middleware.LoggerConfig{
 		Format: "${time_rfc3339}\t${method}\t${uri}\t${status}\t${latency_human}\n",
 	}))
+	// outside Recover so panics are counted as the 500 they become
+	httpMetrics, err := do.Invoke[*metrics.HTTP](cfg.Container)
+	if err != nil {
+		return nil, err
+	}
+	r.Use(requestMetrics(httpMetrics))
 	r.Use(middleware.Recover())
 
 	routesAPIv1 := r.Group("/api/v1")
@@ -59,10 +67,106 @@ func New(cfg *Config) (http.Handler, error) {
 			routesAPIv1User.POST("/register", u.Register)
 			routesAPIv1User.POST("/activate", u.ActivateUser)
 			routesAPIv1User.GET("/auth/google/callback", u.GoogleCallbackHandlerLogin)
+
+			f := groupFeed{cfg.Container}
+			routesAPIv1User.POST("/feed-token", f.RotateToken, JWTMiddleware(cfg.Container), authorize(cfg.Container))
+
+			n := groupNotification{cfg.Container}
+			routesAPIv1User.GET("/notifications", n.List, JWTMiddleware(cfg.Container), authorize(cfg.Container))
+			routesAPIv1User.POST("/notifications/read", n.MarkRead, JWTMiddleware(cfg.Container), authorize(cfg.Container))
+
+			p := groupPublish{cfg.Container}
+			routesAPIv1User.GET("/chapters", p.ListUploads, JWTMiddleware(cfg.Container), authorize(cfg.Container, models.RoleUploader, models.RoleAdmin))
+		}
+
+		routesAPIv1Me := routesAPIv1.Group("/me", JWTMiddleware(cfg.Container), authorize(cfg.Container))
+		{
+			rd := groupReading{cfg.Container}
+			routesAPIv1Me.GET("/recommendations", rd.Recommendations)
+			routesAPIv1Me.GET("/history", rd.History)
+
+			se := groupSettings{cfg.Container}
+			routesAPIv1Me.GET("/settings", se.Get)
+			routesAPIv1Me.PATCH("/settings", se.Update)
 		}
 
+		uploader := []echo.MiddlewareFunc{JWTMiddleware(cfg.Container), authorize(cfg.Container, models.RoleUploader, models.RoleAdmin)}
+
+		routesAPIv1Story := routesAPIv1.Group("/stories")
+		{
+			st := groupStory{cfg.Container}
+			routesAPIv1Story.GET("/:slug/export.epub", st.ExportEPUB)
+
+			p := groupPublish{cfg.Container}
+			routesAPIv1Story.POST("/:slug/chapters", p.CreateDraft, uploader...)
+
+			mo := groupModeration{cfg.Container}
+			routesAPIv1Story.POST("/:slug/reports", mo.Report, JWTMiddleware(cfg.Container), authorize(cfg.Container))
+
+			rd := groupReading{cfg.Container}
+			routesAPIv1Story.GET("/:slug/similar", rd.Similar)
+			routesAPIv1Story.PUT("/:slug/progress", rd.SaveProgress, JWTMiddleware(cfg.Container), authorize(cfg.Container))
+			routesAPIv1Story.PUT("/:slug/rating", rd.Rate, JWTMiddleware(cfg.Container), authorize(cfg.Container))
+		}
+
+		routesAPIv1Chapter := routesAPIv1.Group("/chapters", uploader...)
+		{
+			p := groupPublish{cfg.Container}
+			routesAPIv1Chapter.PATCH("/:id", p.Edit)
+			routesAPIv1Chapter.POST("/:id/schedule", p.Schedule)
+			routesAPIv1Chapter.POST("/:id/publish", p.Publish)
+			routesAPIv1Chapter.GET("/:id/revisions", p.Revisions)
+			routesAPIv1Chapter.GET("/:id/revisions/:revision/diff", p.Diff)
+			routesAPIv1Chapter.POST("/:id/revisions/:revision/revert", p.Revert)
+		}
+
+	}
+
+	routesMedia := r.Group("/media")
+	{
+		m := groupMedia{cfg.Container}
+		routesMedia.GET("/covers/:hash/:size", m.Cover)
+	}
+
+	routesFeeds := r.Group("/feeds")
+	{
+		f := groupFeed{cfg.Container}
+		routesFeeds.GET("/stories/:file", f.Story)
+		routesFeeds.GET("/categories/:file", f.Category)
+		routesFeeds.GET("/followed/:file", f.Followed)
 	}
 
+	routesAdmin := r.Group("/admin", JWTMiddleware(cfg.Container), authorize(cfg.Container, models.RoleAdmin))
+	{
+		cr := groupCrawl{cfg.Container}
+		routesAdmin.GET("/crawls", cr.List)
+		routesAdmin.POST("/crawls", cr.Create)
+		routesAdmin.GET("/crawls/:id", cr.Get)
+
+		d := groupDuplicate{cfg.Container}
+		routesAdmin.GET("/duplicates", d.List)
+		routesAdmin.POST("/duplicates/scan", d.Scan)
+		routesAdmin.POST("/duplicates/:id/merge", d.Merge)
+		routesAdmin.POST("/duplicates/:id/dismiss", d.Dismiss)
+
+		mo := groupModeration{cfg.Container}
+		routesAdmin.GET("/moderation", mo.Queue)
+		routesAdmin.POST("/moderation/dmca", mo.FlagDMCA)
+		routesAdmin.POST("/moderation/:id/resolve", mo.Resolve)
+		routesAdmin.PUT("/stories/:slug/status", mo.ChangeStatus)
+		routesAdmin.GET("/stories/:slug/status-history", mo.History)
+
+		ca := groupCache{cfg.Container}
+		routesAdmin.GET("/cache/metrics", ca.Metrics)
+		routesAdmin.GET("/cache/keys/:key", ca.InspectKey)
+		routesAdmin.DELETE("/cache/keys/:key", ca.DeleteKey)
+		routesAdmin.DELETE("/cache/tags/:tag", ca.InvalidateTag)
+	}
+
+	h := groupHealth{cfg.Container}
+	r.GET("/healthz", h.Live)
+	r.GET("/readyz", h.Ready)
+
 	r.GET("", func(c echo.Context) error {
 		return c.String(http.StatusOK, "👻️")
 	})
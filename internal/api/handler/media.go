@@ -0,0 +1,51 @@
+package handler
+
+import (
+	"demo-cosebase/internal/services"
+	"demo-cosebase/pkg/storage"
+	"errors"
+	"fmt"
+	"github.com/hiendaovinh/toolkit/pkg/errorx"
+	"github.com/hiendaovinh/toolkit/pkg/httpx-echo"
+	"github.com/labstack/echo/v4"
+	"github.com/samber/do"
+	"net/http"
+	"time"
+)
+
+type groupMedia struct {
+	container *do.Injector
+}
+
+func (gr *groupMedia) Cover(c echo.Context) error {
+	ctx := c.Request().Context()
+	hash, size := c.Param("hash"), c.Param("size")
+
+	serviceMedia, err := do.Invoke[*services.ServiceMedia](gr.container)
+	if err != nil {
+		return httpx.RestAbort(c, nil, errorx.Wrap(err, errorx.Service))
+	}
+
+	reader, info, err := serviceMedia.OpenCover(ctx, hash, size)
+	if errors.Is(err, storage.ErrNotFound) {
+		return httpx.RestAbort(c, nil, errorx.Wrap(errors.New("cover not found"), errorx.NotExist))
+	}
+	if err != nil {
+		return httpx.RestAbort(c, nil, errorx.Wrap(err, errorx.Service))
+	}
+	defer reader.Close()
+
+	// covers are addressed by content hash, a given url never changes
+	etag := fmt.Sprintf(`"%s-%s"`, hash, size)
+	header := c.Response().Header()
+	header.Set(echo.HeaderCacheControl, "public, max-age=31536000, immutable")
+	header.Set("ETag", etag)
+	header.Set(echo.HeaderLastModified, info.ModTime.UTC().Format(http.TimeFormat))
+	if c.Request().Header.Get("If-None-Match") == etag {
+		return c.NoContent(http.StatusNotModified)
+	}
+
+	header.Set(echo.HeaderContentLength, fmt.Sprintf("%d", info.Size))
+	header.Set("Expires", time.Now().AddDate(1, 0, 0).UTC().Format(http.TimeFormat))
+	return c.Stream(http.StatusOK, info.ContentType, reader)
+}
@@ -1,24 +1,70 @@
 package handler
 
 import (
+	"database/sql"
+	"demo-cosebase/internal/services"
+	"demo-cosebase/pkg/metrics"
+	"errors"
 	"fmt"
 	"github.com/golang-jwt/jwt/v4"
+	"github.com/hiendaovinh/toolkit/pkg/errorx"
+	"github.com/hiendaovinh/toolkit/pkg/httpx-echo"
 	"github.com/labstack/echo/v4"
 	"github.com/samber/do"
 	"net/http"
 	"os"
+	"slices"
+	"strconv"
 	"strings"
+	"time"
 )
 
-func authorize(container *do.Injector) echo.MiddlewareFunc {
+// authorize lets through the users of JWTMiddleware having one of roles, any active
+// user when roles is empty. The user is stored in the context as "user".
+func authorize(container *do.Injector, roles ...string) echo.MiddlewareFunc {
 	return func(next echo.HandlerFunc) echo.HandlerFunc {
 		return func(c echo.Context) error {
+			userID, err := contextUserID(c)
+			if err != nil {
+				return httpx.RestAbort(c, nil, errorx.Wrap(err, errorx.Authn))
+			}
+
+			serviceUser, err := do.Invoke[*services.ServiceUser](container)
+			if err != nil {
+				return httpx.RestAbort(c, nil, errorx.Wrap(err, errorx.Service))
+			}
 
+			user, err := serviceUser.FindUserByID(c.Request().Context(), userID)
+			if errors.Is(err, sql.ErrNoRows) {
+				return httpx.RestAbort(c, nil, errorx.Wrap(errors.New("user not found"), errorx.Authn))
+			}
+			if err != nil {
+				return httpx.RestAbort(c, nil, errorx.Wrap(err, errorx.Database))
+			}
+			if !user.IsActive {
+				return httpx.RestAbort(c, nil, errorx.Wrap(errors.New("user is not activated"), errorx.Authz))
+			}
+			if len(roles) > 0 && !slices.Contains(roles, user.Role) {
+				return httpx.RestAbort(c, nil, errorx.Wrap(errors.New("permission denied"), errorx.Authz))
+			}
+
+			c.Set("user", user)
 			return next(c)
 		}
 	}
 }
 
+// contextUserID reads the user id set by JWTMiddleware.
+func contextUserID(c echo.Context) (int64, error) {
+	switch v := c.Get("user_id").(type) {
+	case string:
+		return strconv.ParseInt(v, 10, 64)
+	case float64:
+		return int64(v), nil
+	}
+	return 0, errors.New("user id not found in token")
+}
+
 func JWTMiddleware(container *do.Injector) echo.MiddlewareFunc {
 	return func(next echo.HandlerFunc) echo.HandlerFunc {
 		return func(c echo.Context) error {
@@ -47,7 +93,7 @@ func JWTMiddleware(container *do.Injector) echo.MiddlewareFunc {
 			}
 
 			if claims, ok := token.Claims.(jwt.MapClaims); ok && token.Valid {
-				c.Set("user_id", claims["user_id"])
+				c.Set("user_id", claims["id"])
 			} else {
 				return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Invalid token claims"})
 			}
@@ -56,3 +102,29 @@ func JWTMiddleware(container *do.Injector) echo.MiddlewareFunc {
 		}
 	}
 }
+
+// requestMetrics records every request under its route template, unknown paths under "unmatched".
+func requestMetrics(m *metrics.HTTP) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			started := time.Now()
+			err := next(c)
+
+			status := c.Response().Status
+			if err != nil {
+				// the error handler has not written the response yet
+				status = http.StatusInternalServerError
+				var he *echo.HTTPError
+				if errors.As(err, &he) {
+					status = he.Code
+				}
+			}
+			route := c.Path()
+			if route == "" || status == http.StatusNotFound && route == "/*" {
+				route = "unmatched"
+			}
+			m.Observe(c.Request().Method, route, status, time.Since(started))
+			return err
+		}
+	}
+}
@@ -0,0 +1,148 @@
+package handler
+
+import (
+	"demo-cosebase/internal/datastore"
+	"demo-cosebase/internal/models"
+	"demo-cosebase/internal/services"
+	"errors"
+	"github.com/hiendaovinh/toolkit/pkg/errorx"
+	"github.com/hiendaovinh/toolkit/pkg/httpx-echo"
+	"github.com/labstack/echo/v4"
+	"github.com/samber/do"
+	"net/http"
+	"strconv"
+)
+
+type groupModeration struct {
+	container *do.Injector
+}
+
+// Report lets a reader report a story to the moderators.
+func (gr *groupModeration) Report(c echo.Context) error {
+	var req models.StoryReportRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request payload"})
+	}
+
+	if err := c.Validate(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Validation failed"})
+	}
+
+	serviceModeration, err := do.Invoke[*services.ServiceModeration](gr.container)
+	if err != nil {
+		return httpx.RestAbort(c, nil, errorx.Wrap(err, errorx.Service))
+	}
+
+	report, err := serviceModeration.Report(c.Request().Context(), c.Get("user").(*models.User), c.Param("slug"), &req)
+	if err != nil {
+		return httpx.RestAbort(c, nil, err)
+	}
+	return c.JSON(http.StatusCreated, report)
+}
+
+func (gr *groupModeration) Queue(c echo.Context) error {
+	serviceModeration, err := do.Invoke[*services.ServiceModeration](gr.container)
+	if err != nil {
+		return httpx.RestAbort(c, nil, errorx.Wrap(err, errorx.Service))
+	}
+
+	status := c.QueryParam("status")
+	if status == "" {
+		status = models.ReportOpen
+	}
+	filter := &datastore.StoryReportFilter{
+		Status: status,
+		Kind:   c.QueryParam("kind"),
+		Limit:  httpx.QueryParamInt(c, "limit", services.ReportsDefaultLimit),
+		Offset: httpx.QueryParamInt(c, "offset", 0),
+	}
+	reports, total, err := serviceModeration.Queue(c.Request().Context(), filter)
+	if err != nil {
+		return httpx.RestAbort(c, nil, err)
+	}
+	return c.JSON(http.StatusOK, map[string]interface{}{"reports": reports, "total": total})
+}
+
+func (gr *groupModeration) FlagDMCA(c echo.Context) error {
+	var req models.DMCAReportRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request payload"})
+	}
+
+	if err := c.Validate(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Validation failed"})
+	}
+
+	serviceModeration, err := do.Invoke[*services.ServiceModeration](gr.container)
+	if err != nil {
+		return httpx.RestAbort(c, nil, errorx.Wrap(err, errorx.Service))
+	}
+
+	report, err := serviceModeration.FlagDMCA(c.Request().Context(), c.Get("user").(*models.User), &req)
+	if err != nil {
+		return httpx.RestAbort(c, nil, err)
+	}
+	return c.JSON(http.StatusCreated, report)
+}
+
+func (gr *groupModeration) Resolve(c echo.Context) error {
+	ID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		return httpx.RestAbort(c, nil, errorx.Wrap(errors.New("invalid report id"), errorx.Invalid))
+	}
+
+	var req models.ModerationRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request payload"})
+	}
+
+	if err := c.Validate(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Validation failed"})
+	}
+
+	serviceModeration, err := do.Invoke[*services.ServiceModeration](gr.container)
+	if err != nil {
+		return httpx.RestAbort(c, nil, errorx.Wrap(err, errorx.Service))
+	}
+
+	report, err := serviceModeration.Resolve(c.Request().Context(), c.Get("user").(*models.User), ID, &req)
+	if err != nil {
+		return httpx.RestAbort(c, nil, err)
+	}
+	return c.JSON(http.StatusOK, report)
+}
+
+func (gr *groupModeration) ChangeStatus(c echo.Context) error {
+	var req models.StoryStatusRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request payload"})
+	}
+
+	if err := c.Validate(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Validation failed"})
+	}
+
+	serviceModeration, err := do.Invoke[*services.ServiceModeration](gr.container)
+	if err != nil {
+		return httpx.RestAbort(c, nil, errorx.Wrap(err, errorx.Service))
+	}
+
+	story, err := serviceModeration.ChangeStatus(c.Request().Context(), c.Get("user").(*models.User), c.Param("slug"), &req)
+	if err != nil {
+		return httpx.RestAbort(c, nil, err)
+	}
+	return c.JSON(http.StatusOK, story)
+}
+
+func (gr *groupModeration) History(c echo.Context) error {
+	serviceModeration, err := do.Invoke[*services.ServiceModeration](gr.container)
+	if err != nil {
+		return httpx.RestAbort(c, nil, errorx.Wrap(err, errorx.Service))
+	}
+
+	changes, err := serviceModeration.History(c.Request().Context(), c.Param("slug"))
+	if err != nil {
+		return httpx.RestAbort(c, nil, err)
+	}
+	return c.JSON(http.StatusOK, map[string]interface{}{"changes": changes})
+}
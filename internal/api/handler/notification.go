@@ -0,0 +1,52 @@
+package handler
+
+import (
+	"demo-cosebase/internal/models"
+	"demo-cosebase/internal/services"
+	"github.com/hiendaovinh/toolkit/pkg/errorx"
+	"github.com/hiendaovinh/toolkit/pkg/httpx-echo"
+	"github.com/labstack/echo/v4"
+	"github.com/samber/do"
+	"net/http"
+)
+
+type groupNotification struct {
+	container *do.Injector
+}
+
+// List returns the notifications of the user, ?unread=true only the unread ones.
+func (gr *groupNotification) List(c echo.Context) error {
+	serviceNotification, err := do.Invoke[*services.ServiceNotification](gr.container)
+	if err != nil {
+		return httpx.RestAbort(c, nil, errorx.Wrap(err, errorx.Service))
+	}
+
+	user := c.Get("user").(*models.User)
+	notifications, unread, err := serviceNotification.List(c.Request().Context(), user.ID,
+		c.QueryParam("unread") == "true",
+		httpx.QueryParamInt(c, "limit", services.NotificationsDefaultLimit),
+		httpx.QueryParamInt(c, "offset", 0))
+	if err != nil {
+		return httpx.RestAbort(c, nil, err)
+	}
+	return c.JSON(http.StatusOK, map[string]interface{}{"notifications": notifications, "unread": unread})
+}
+
+func (gr *groupNotification) MarkRead(c echo.Context) error {
+	var req models.NotificationReadRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request payload"})
+	}
+
+	serviceNotification, err := do.Invoke[*services.ServiceNotification](gr.container)
+	if err != nil {
+		return httpx.RestAbort(c, nil, errorx.Wrap(err, errorx.Service))
+	}
+
+	user := c.Get("user").(*models.User)
+	marked, err := serviceNotification.MarkRead(c.Request().Context(), user.ID, req.IDs)
+	if err != nil {
+		return httpx.RestAbort(c, nil, err)
+	}
+	return c.JSON(http.StatusOK, map[string]int{"marked": marked})
+}
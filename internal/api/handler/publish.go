@@ -0,0 +1,204 @@
+package handler
+
+import (
+	"demo-cosebase/internal/models"
+	"demo-cosebase/internal/services"
+	"errors"
+	"github.com/hiendaovinh/toolkit/pkg/errorx"
+	"github.com/hiendaovinh/toolkit/pkg/httpx-echo"
+	"github.com/labstack/echo/v4"
+	"github.com/samber/do"
+	"net/http"
+	"strconv"
+)
+
+type groupPublish struct {
+	container *do.Injector
+}
+
+func (gr *groupPublish) CreateDraft(c echo.Context) error {
+	var req models.ChapterDraftRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request payload"})
+	}
+
+	if err := c.Validate(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Validation failed"})
+	}
+
+	servicePublish, err := do.Invoke[*services.ServicePublish](gr.container)
+	if err != nil {
+		return httpx.RestAbort(c, nil, errorx.Wrap(err, errorx.Service))
+	}
+
+	chapter, err := servicePublish.CreateDraft(c.Request().Context(), c.Get("user").(*models.User), c.Param("slug"), &req)
+	if err != nil {
+		return httpx.RestAbort(c, nil, err)
+	}
+	return c.JSON(http.StatusCreated, chapter)
+}
+
+func (gr *groupPublish) Edit(c echo.Context) error {
+	ID, err := chapterID(c)
+	if err != nil {
+		return httpx.RestAbort(c, nil, err)
+	}
+
+	var req models.ChapterEditRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request payload"})
+	}
+
+	if err := c.Validate(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Validation failed"})
+	}
+
+	servicePublish, err := do.Invoke[*services.ServicePublish](gr.container)
+	if err != nil {
+		return httpx.RestAbort(c, nil, errorx.Wrap(err, errorx.Service))
+	}
+
+	chapter, err := servicePublish.Edit(c.Request().Context(), c.Get("user").(*models.User), ID, &req)
+	if err != nil {
+		return httpx.RestAbort(c, nil, err)
+	}
+	return c.JSON(http.StatusOK, chapter)
+}
+
+func (gr *groupPublish) Schedule(c echo.Context) error {
+	ID, err := chapterID(c)
+	if err != nil {
+		return httpx.RestAbort(c, nil, err)
+	}
+
+	var req models.ChapterScheduleRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request payload"})
+	}
+
+	if err := c.Validate(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Validation failed"})
+	}
+
+	servicePublish, err := do.Invoke[*services.ServicePublish](gr.container)
+	if err != nil {
+		return httpx.RestAbort(c, nil, errorx.Wrap(err, errorx.Service))
+	}
+
+	chapter, err := servicePublish.Schedule(c.Request().Context(), c.Get("user").(*models.User), ID, req.PublishAt)
+	if err != nil {
+		return httpx.RestAbort(c, nil, err)
+	}
+	return c.JSON(http.StatusOK, chapter)
+}
+
+func (gr *groupPublish) Publish(c echo.Context) error {
+	ID, err := chapterID(c)
+	if err != nil {
+		return httpx.RestAbort(c, nil, err)
+	}
+
+	servicePublish, err := do.Invoke[*services.ServicePublish](gr.container)
+	if err != nil {
+		return httpx.RestAbort(c, nil, errorx.Wrap(err, errorx.Service))
+	}
+
+	chapter, err := servicePublish.Publish(c.Request().Context(), c.Get("user").(*models.User), ID)
+	if err != nil {
+		return httpx.RestAbort(c, nil, err)
+	}
+	return c.JSON(http.StatusOK, chapter)
+}
+
+func (gr *groupPublish) Revisions(c echo.Context) error {
+	ID, err := chapterID(c)
+	if err != nil {
+		return httpx.RestAbort(c, nil, err)
+	}
+
+	servicePublish, err := do.Invoke[*services.ServicePublish](gr.container)
+	if err != nil {
+		return httpx.RestAbort(c, nil, errorx.Wrap(err, errorx.Service))
+	}
+
+	revisions, err := servicePublish.Revisions(c.Request().Context(), c.Get("user").(*models.User), ID)
+	if err != nil {
+		return httpx.RestAbort(c, nil, err)
+	}
+	return c.JSON(http.StatusOK, map[string]interface{}{"revisions": revisions})
+}
+
+// Diff shows what a revision changed, ?against=N compares it to revision N instead of
+// the previous one.
+func (gr *groupPublish) Diff(c echo.Context) error {
+	ID, err := chapterID(c)
+	if err != nil {
+		return httpx.RestAbort(c, nil, err)
+	}
+	revision, err := strconv.Atoi(c.Param("revision"))
+	if err != nil || revision <= 0 {
+		return httpx.RestAbort(c, nil, errorx.Wrap(errors.New("invalid revision"), errorx.Invalid))
+	}
+	against := httpx.QueryParamInt(c, "against", 0)
+	if against < 0 {
+		return httpx.RestAbort(c, nil, errorx.Wrap(errors.New("invalid revision"), errorx.Invalid))
+	}
+
+	servicePublish, err := do.Invoke[*services.ServicePublish](gr.container)
+	if err != nil {
+		return httpx.RestAbort(c, nil, errorx.Wrap(err, errorx.Service))
+	}
+
+	result, err := servicePublish.Diff(c.Request().Context(), c.Get("user").(*models.User), ID, revision, against)
+	if err != nil {
+		return httpx.RestAbort(c, nil, err)
+	}
+	return c.JSON(http.StatusOK, result)
+}
+
+func (gr *groupPublish) Revert(c echo.Context) error {
+	ID, err := chapterID(c)
+	if err != nil {
+		return httpx.RestAbort(c, nil, err)
+	}
+	revision, err := strconv.Atoi(c.Param("revision"))
+	if err != nil || revision <= 0 {
+		return httpx.RestAbort(c, nil, errorx.Wrap(errors.New("invalid revision"), errorx.Invalid))
+	}
+
+	servicePublish, err := do.Invoke[*services.ServicePublish](gr.container)
+	if err != nil {
+		return httpx.RestAbort(c, nil, errorx.Wrap(err, errorx.Service))
+	}
+
+	chapter, err := servicePublish.Revert(c.Request().Context(), c.Get("user").(*models.User), ID, revision)
+	if err != nil {
+		return httpx.RestAbort(c, nil, err)
+	}
+	return c.JSON(http.StatusOK, chapter)
+}
+
+// ListUploads lists the chapters uploaded by the user, ?status= filters them.
+func (gr *groupPublish) ListUploads(c echo.Context) error {
+	servicePublish, err := do.Invoke[*services.ServicePublish](gr.container)
+	if err != nil {
+		return httpx.RestAbort(c, nil, errorx.Wrap(err, errorx.Service))
+	}
+
+	chapters, total, err := servicePublish.ListUploads(c.Request().Context(), c.Get("user").(*models.User),
+		c.QueryParam("status"),
+		httpx.QueryParamInt(c, "limit", services.UploadedChaptersDefaultLimit),
+		httpx.QueryParamInt(c, "offset", 0))
+	if err != nil {
+		return httpx.RestAbort(c, nil, err)
+	}
+	return c.JSON(http.StatusOK, map[string]interface{}{"chapters": chapters, "total": total})
+}
+
+func chapterID(c echo.Context) (int64, error) {
+	ID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		return 0, errorx.Wrap(errors.New("invalid chapter id"), errorx.Invalid)
+	}
+	return ID, nil
+}
@@ -0,0 +1,101 @@
+package handler
+
+import (
+	"demo-cosebase/internal/models"
+	"demo-cosebase/internal/services"
+	"github.com/hiendaovinh/toolkit/pkg/errorx"
+	"github.com/hiendaovinh/toolkit/pkg/httpx-echo"
+	"github.com/labstack/echo/v4"
+	"github.com/samber/do"
+	"net/http"
+)
+
+type groupReading struct {
+	container *do.Injector
+}
+
+func (gr *groupReading) SaveProgress(c echo.Context) error {
+	var req models.ReadingProgressRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request payload"})
+	}
+
+	if err := c.Validate(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Validation failed"})
+	}
+
+	serviceReading, err := do.Invoke[*services.ServiceReading](gr.container)
+	if err != nil {
+		return httpx.RestAbort(c, nil, errorx.Wrap(err, errorx.Service))
+	}
+
+	progress, err := serviceReading.SaveProgress(c.Request().Context(), c.Get("user").(*models.User), c.Param("slug"), req.Chapter)
+	if err != nil {
+		return httpx.RestAbort(c, nil, err)
+	}
+	return c.JSON(http.StatusOK, progress)
+}
+
+func (gr *groupReading) Rate(c echo.Context) error {
+	var req models.RatingRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request payload"})
+	}
+
+	if err := c.Validate(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Validation failed"})
+	}
+
+	serviceReading, err := do.Invoke[*services.ServiceReading](gr.container)
+	if err != nil {
+		return httpx.RestAbort(c, nil, errorx.Wrap(err, errorx.Service))
+	}
+
+	summary, err := serviceReading.Rate(c.Request().Context(), c.Get("user").(*models.User), c.Param("slug"), req.Score)
+	if err != nil {
+		return httpx.RestAbort(c, nil, err)
+	}
+	return c.JSON(http.StatusOK, summary)
+}
+
+func (gr *groupReading) History(c echo.Context) error {
+	serviceReading, err := do.Invoke[*services.ServiceReading](gr.container)
+	if err != nil {
+		return httpx.RestAbort(c, nil, errorx.Wrap(err, errorx.Service))
+	}
+
+	history, total, err := serviceReading.History(c.Request().Context(), c.Get("user").(*models.User),
+		httpx.QueryParamInt(c, "limit", services.HistoryDefaultLimit),
+		httpx.QueryParamInt(c, "offset", 0))
+	if err != nil {
+		return httpx.RestAbort(c, nil, err)
+	}
+	return c.JSON(http.StatusOK, map[string]interface{}{"history": history, "total": total})
+}
+
+func (gr *groupReading) Recommendations(c echo.Context) error {
+	serviceRecommendation, err := do.Invoke[*services.ServiceRecommendation](gr.container)
+	if err != nil {
+		return httpx.RestAbort(c, nil, errorx.Wrap(err, errorx.Service))
+	}
+
+	user := c.Get("user").(*models.User)
+	stories, err := serviceRecommendation.ForUser(c.Request().Context(), user.ID)
+	if err != nil {
+		return httpx.RestAbort(c, nil, err)
+	}
+	return c.JSON(http.StatusOK, map[string]interface{}{"stories": stories})
+}
+
+func (gr *groupReading) Similar(c echo.Context) error {
+	serviceRecommendation, err := do.Invoke[*services.ServiceRecommendation](gr.container)
+	if err != nil {
+		return httpx.RestAbort(c, nil, errorx.Wrap(err, errorx.Service))
+	}
+
+	stories, err := serviceRecommendation.Similar(c.Request().Context(), c.Param("slug"))
+	if err != nil {
+		return httpx.RestAbort(c, nil, err)
+	}
+	return c.JSON(http.StatusOK, map[string]interface{}{"stories": stories})
+}
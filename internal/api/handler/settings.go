@@ -0,0 +1,47 @@
+package handler
+
+import (
+	"demo-cosebase/internal/models"
+	"demo-cosebase/internal/services"
+	"github.com/hiendaovinh/toolkit/pkg/errorx"
+	"github.com/hiendaovinh/toolkit/pkg/httpx-echo"
+	"github.com/labstack/echo/v4"
+	"github.com/samber/do"
+	"net/http"
+)
+
+type groupSettings struct {
+	container *do.Injector
+}
+
+func (gr *groupSettings) Get(c echo.Context) error {
+	serviceSettings, err := do.Invoke[*services.ServiceSettings](gr.container)
+	if err != nil {
+		return httpx.RestAbort(c, nil, errorx.Wrap(err, errorx.Service))
+	}
+
+	return c.JSON(http.StatusOK, serviceSettings.Settings(c.Get("user").(*models.User)))
+}
+
+// Update changes the settings present in the body, the others are kept.
+func (gr *groupSettings) Update(c echo.Context) error {
+	var req models.UserSettingsPatch
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request payload"})
+	}
+
+	if err := c.Validate(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Validation failed"})
+	}
+
+	serviceSettings, err := do.Invoke[*services.ServiceSettings](gr.container)
+	if err != nil {
+		return httpx.RestAbort(c, nil, errorx.Wrap(err, errorx.Service))
+	}
+
+	settings, err := serviceSettings.Update(c.Request().Context(), c.Get("user").(*models.User), &req)
+	if err != nil {
+		return httpx.RestAbort(c, nil, err)
+	}
+	return c.JSON(http.StatusOK, settings)
+}
@@ -0,0 +1,71 @@
+package handler
+
+import (
+	"demo-cosebase/internal/export"
+	"demo-cosebase/internal/services"
+	"errors"
+	"fmt"
+	"github.com/hiendaovinh/toolkit/pkg/errorx"
+	"github.com/hiendaovinh/toolkit/pkg/httpx-echo"
+	"github.com/labstack/echo/v4"
+	"github.com/samber/do"
+	"log"
+	"net/http"
+)
+
+type groupStory struct {
+	container *do.Injector
+}
+
+func (gr *groupStory) ExportEPUB(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	r := export.Range{
+		From: httpx.QueryParamInt(c, "from", 0),
+		To:   httpx.QueryParamInt(c, "to", 0),
+	}
+	if r.From < 0 || r.To < 0 || (r.To > 0 && r.To < r.From) {
+		return httpx.RestAbort(c, nil, errorx.Wrap(errors.New("invalid chapter range"), errorx.Invalid))
+	}
+
+	serviceStory, err := do.Invoke[*services.ServiceStory](gr.container)
+	if err != nil {
+		return httpx.RestAbort(c, nil, errorx.Wrap(err, errorx.Service))
+	}
+
+	story, err := serviceStory.FindStoryBySlug(ctx, c.Param("slug"))
+	if err != nil {
+		return httpx.RestAbort(c, nil, err)
+	}
+
+	w := &attachmentWriter{c: c, contentType: "application/epub+zip", filename: story.Slug + ".epub"}
+	err = serviceStory.ExportEPUB(ctx, w, story, r)
+	if err != nil && !w.started {
+		return httpx.RestAbort(c, nil, err)
+	}
+	if err != nil {
+		// the status is sent already, the client gets a truncated file
+		log.Printf("export %s: %v\n", story.Slug, err)
+	}
+	return nil
+}
+
+// attachmentWriter sends the download headers on the first write, until then the
+// handler can still answer with an error.
+type attachmentWriter struct {
+	c           echo.Context
+	contentType string
+	filename    string
+	started     bool
+}
+
+func (w *attachmentWriter) Write(p []byte) (int, error) {
+	if !w.started {
+		w.started = true
+		header := w.c.Response().Header()
+		header.Set(echo.HeaderContentType, w.contentType)
+		header.Set(echo.HeaderContentDisposition, fmt.Sprintf("attachment; filename=%q", w.filename))
+		w.c.Response().WriteHeader(http.StatusOK)
+	}
+	return w.c.Response().Write(p)
+}
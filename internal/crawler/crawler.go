@@ -0,0 +1,373 @@
+package crawler
+
+import (
+	"context"
+	"database/sql"
+	"demo-cosebase/internal/datastore"
+	"demo-cosebase/internal/media"
+	"demo-cosebase/internal/models"
+	"demo-cosebase/pkg"
+	"demo-cosebase/pkg/textclean"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/uptrace/bun"
+)
+
+const DefaultConcurrency = 5
+
+// ErrIncompleteChapter is returned for chapters that look truncated or paywalled,
+// they are not stored so the next crawl tries again.
+var ErrIncompleteChapter = errors.New("crawler: incomplete chapter")
+
+// Crawler fetches stories from a Source, cleans them and upserts them into Postgres.
+type Crawler struct {
+	source      Source
+	db          *bun.DB
+	cleaner     *textclean.Cleaner
+	covers      *media.Covers
+	invalidate  func(ctx context.Context, story *models.Story)
+	concurrency int
+	stats       *stats
+}
+
+func NewCrawler(source Source, db *bun.DB) (*Crawler, error) {
+	c := &Crawler{source: source, db: db, concurrency: DefaultConcurrency, stats: &stats{}}
+	if err := c.SetRules(nil); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// SetCovers enables mirroring of story covers.
+func (c *Crawler) SetCovers(covers *media.Covers) {
+	c.covers = covers
+}
+
+// SetInvalidate registers fn to drop what is cached about a story once it was crawled.
+func (c *Crawler) SetInvalidate(fn func(ctx context.Context, story *models.Story)) {
+	c.invalidate = fn
+}
+
+// SetRules replaces the cleaning rules by the defaults, the source rules and extra.
+func (c *Crawler) SetRules(extra *textclean.Rules) error {
+	rules := textclean.DefaultRules()
+	if provider, ok := c.source.(CleaningRules); ok {
+		rules = rules.Merge(provider.CleaningRules())
+	}
+	if extra != nil {
+		rules = rules.Merge(extra)
+	}
+
+	cleaner, err := textclean.New(rules)
+	if err != nil {
+		return err
+	}
+	c.cleaner = cleaner
+	return nil
+}
+
+func (c *Crawler) Source() Source {
+	return c.source
+}
+
+func (c *Crawler) CrawlCategories(ctx context.Context) ([]*models.Category, error) {
+	categories, err := c.source.ListCategories(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]*models.Category, 0, len(categories))
+	for _, category := range categories {
+		if err := ctx.Err(); err != nil {
+			return result, err
+		}
+		saved, err := datastore.UpsertCategory(ctx, c.db, &models.Category{Slug: category.Slug, Name: category.Name})
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, saved)
+	}
+	return result, nil
+}
+
+// CrawlRanking crawls every story of a ranking page, errors of single stories are logged
+// and do not stop the others.
+func (c *Crawler) CrawlRanking(ctx context.Context, ranking string, page int, withChapters bool) ([]*models.Story, error) {
+	refs, err := c.source.ListRanking(ctx, ranking, page)
+	if err != nil {
+		return nil, err
+	}
+	return c.CrawlStories(ctx, refs, withChapters)
+}
+
+func (c *Crawler) CrawlStories(ctx context.Context, refs []StoryRef, withChapters bool) ([]*models.Story, error) {
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	limit := make(chan struct{}, c.concurrency)
+	stories := make([]*models.Story, 0, len(refs))
+
+	for _, ref := range refs {
+		wg.Add(1)
+		limit <- struct{}{}
+
+		go func(ref StoryRef) {
+			defer wg.Done()
+			defer func() { <-limit }()
+
+			story, err := c.CrawlStory(ctx, ref, withChapters)
+			if err != nil {
+				c.stats.fail(storyURL(ref), err)
+				return
+			}
+
+			mu.Lock()
+			stories = append(stories, story)
+			mu.Unlock()
+		}(ref)
+	}
+
+	wg.Wait()
+	return stories, ctx.Err()
+}
+
+// CrawlFollowed updates the stories followed by at least one user, with their chapters.
+func (c *Crawler) CrawlFollowed(ctx context.Context) ([]*models.Story, error) {
+	storySources, err := datastore.FindFollowedStorySources(ctx, c.db, c.source.Name())
+	if err != nil {
+		return nil, err
+	}
+
+	refs := make([]StoryRef, 0, len(storySources))
+	for _, storySource := range storySources {
+		refs = append(refs, StoryRef{SourceID: storySource.SourceID, Slug: storySource.Slug, URL: storySource.URL})
+	}
+	return c.CrawlStories(ctx, refs, true)
+}
+
+// CrawlStory fetches a story and links it to the stored story through its StorySource,
+// creating the story on first sight.
+func (c *Crawler) CrawlStory(ctx context.Context, ref StoryRef, withChapters bool) (*models.Story, error) {
+	fetched, err := c.source.FetchStory(ctx, ref)
+	if err != nil {
+		return nil, err
+	}
+
+	story, err := c.saveStory(ctx, fetched)
+	if err != nil {
+		return nil, err
+	}
+	c.stats.storyFetched()
+	if c.invalidate != nil {
+		defer c.invalidate(ctx, story)
+	}
+
+	if err := c.mirrorCover(ctx, story, fetched); err != nil {
+		c.stats.fail(fetched.ImageURL, err)
+	}
+
+	if withChapters {
+		if _, err := c.CrawlChapters(ctx, story, fetched); err != nil {
+			return story, err
+		}
+	}
+	return story, nil
+}
+
+// CrawlChapters fetches the chapters that are not stored yet and returns how many were saved.
+// A chapter that fails is recorded and skipped, the next crawl tries it again.
+func (c *Crawler) CrawlChapters(ctx context.Context, story *models.Story, fetched *Story) (int, error) {
+	refs, err := c.source.ListChapters(ctx, fetched)
+	if err != nil {
+		return 0, err
+	}
+
+	numbers, err := datastore.FindChapterNumbers(ctx, c.db, story.ID, c.source.Name())
+	if err != nil {
+		return 0, err
+	}
+	stored := make(map[int]bool, len(numbers))
+	for _, number := range numbers {
+		stored[number] = true
+	}
+
+	saved := 0
+	for _, ref := range refs {
+		if stored[ref.Number] {
+			continue
+		}
+
+		if err := ctx.Err(); err != nil {
+			return saved, err
+		}
+
+		chapter, err := c.source.FetchChapter(ctx, ref)
+		if err != nil {
+			c.stats.fail(ref.URL, fmt.Errorf("chapter %d: %w", ref.Number, err))
+			continue
+		}
+
+		content := c.cleaner.CleanHTML(chapter.Content)
+		if verdict := c.cleaner.Check(content); verdict != textclean.Complete {
+			c.stats.fail(ref.URL, fmt.Errorf("chapter %d: %w (%s)", ref.Number, ErrIncompleteChapter, verdict))
+			continue
+		}
+
+		now := time.Now().Unix()
+		_, err = datastore.UpsertChapter(ctx, c.db, &models.Chapter{
+			StoryID:   story.ID,
+			Source:    c.source.Name(),
+			Number:    chapter.Number,
+			Volume:    chapter.Volume,
+			Title:     chapter.Title,
+			Content:   content,
+			URL:       chapter.URL,
+			CreatedAt: now,
+			UpdatedAt: now,
+		})
+		if err != nil {
+			return saved, err
+		}
+		saved++
+		c.stats.chapterSaved()
+	}
+	return saved, nil
+}
+
+func (c *Crawler) saveStory(ctx context.Context, fetched *Story) (*models.Story, error) {
+	if fetched.SourceID == "" {
+		return nil, fmt.Errorf("crawler: %s story %s has no id", c.source.Name(), fetched.URL)
+	}
+
+	var story *models.Story
+	err := c.db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		now := time.Now().Unix()
+
+		storySource, err := datastore.FindStorySource(ctx, tx, c.source.Name(), fetched.SourceID)
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return err
+		}
+
+		if storySource != nil {
+			story, err = datastore.FindStoryByID(ctx, tx, storySource.StoryID)
+			if err != nil {
+				return err
+			}
+			applyStory(story, fetched, c.cleaner)
+			story.UpdatedAt = now
+			if _, err := datastore.UpdateStory(ctx, tx, story); err != nil {
+				return err
+			}
+			if crawledStatus(story.Status, fetched.Status) {
+				err := datastore.ChangeStoryStatus(ctx, tx, story, fetched.Status, &models.StoryStatusChange{
+					Source:    "crawl:" + c.source.Name(),
+					CreatedAt: now,
+				})
+				// a moderator changed it since it was read, the crawl does not overrule them
+				if errors.Is(err, datastore.ErrStoryStatusChanged) {
+					story, err = datastore.FindStoryByID(ctx, tx, story.ID)
+				}
+				if err != nil {
+					return err
+				}
+			}
+		} else {
+			slug, err := c.freeSlug(ctx, tx, fetched.Slug)
+			if err != nil {
+				return err
+			}
+			story = &models.Story{
+				ID:        pkg.GenerateRandomID(),
+				Slug:      slug,
+				Creator:   c.source.Name(),
+				CreatedAt: now,
+				UpdatedAt: now,
+			}
+			applyStory(story, fetched, c.cleaner)
+			story.Status = fetched.Status
+			if _, err := datastore.CreateStory(ctx, tx, story); err != nil {
+				return err
+			}
+		}
+
+		_, err = datastore.UpsertStorySource(ctx, tx, &models.StorySource{
+			StoryID:   story.ID,
+			Source:    c.source.Name(),
+			SourceID:  fetched.SourceID,
+			Slug:      fetched.Slug,
+			URL:       fetched.URL,
+			CrawledAt: now,
+		})
+		if err != nil {
+			return err
+		}
+
+		categoryIDs := make([]int64, 0, len(fetched.Categories))
+		for _, category := range fetched.Categories {
+			saved, err := datastore.UpsertCategory(ctx, tx, &models.Category{Slug: category.Slug, Name: category.Name})
+			if err != nil {
+				return err
+			}
+			categoryIDs = append(categoryIDs, saved.ID)
+		}
+		return datastore.LinkStoryCategories(ctx, tx, story.ID, categoryIDs)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return story, nil
+}
+
+// mirrorCover stores the cover when it is new or its url changed since the last crawl.
+func (c *Crawler) mirrorCover(ctx context.Context, story *models.Story, fetched *Story) error {
+	if c.covers == nil || fetched.ImageURL == "" {
+		return nil
+	}
+	if story.CoverHash != "" && story.CoverURL == fetched.ImageURL {
+		return nil
+	}
+
+	hash, err := c.covers.Mirror(ctx, fetched.ImageURL)
+	if err != nil {
+		return err
+	}
+
+	story.CoverHash = hash
+	story.CoverURL = fetched.ImageURL
+	return datastore.UpdateStoryCover(ctx, c.db, story)
+}
+
+// freeSlug keeps the source slug unless another story already owns it.
+func (c *Crawler) freeSlug(ctx context.Context, db bun.IDB, slug string) (string, error) {
+	candidate := slug
+	for i := 0; ; i++ {
+		if i > 0 {
+			candidate = fmt.Sprintf("%s-%s-%d", slug, c.source.Name(), i)
+		}
+		_, err := datastore.FindStoryBySlug(ctx, db, candidate)
+		if errors.Is(err, sql.ErrNoRows) {
+			return candidate, nil
+		}
+		if err != nil {
+			return "", err
+		}
+	}
+}
+
+func applyStory(story *models.Story, fetched *Story, cleaner *textclean.Cleaner) {
+	story.Tittle = fetched.Title
+	story.OriginalTitle = fetched.OriginalTitle
+	story.Author = fetched.Author
+	story.Description = cleaner.CleanHTML(fetched.Description)
+	story.Image = fetched.ImageURL
+}
+
+// crawledStatus reports whether the status read on the source replaces the stored one.
+// The source only moves a story between public statuses, never out of a moderator's
+// decision.
+func crawledStatus(stored, fetched string) bool {
+	return fetched != "" && fetched != stored && models.IsPublicStoryStatus(stored) &&
+		models.CanTransitionStory(stored, fetched)
+}
@@ -0,0 +1,331 @@
+package crawler
+
+import (
+	"context"
+	"crypto/rand"
+	"demo-cosebase/internal/datastore"
+	"demo-cosebase/internal/datastore/redis_store"
+	"demo-cosebase/internal/models"
+	"demo-cosebase/pkg/schedule"
+	"encoding/hex"
+	"fmt"
+	"log"
+	math_rand "math/rand"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/uptrace/bun"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	JobCategory = "category"
+	JobRanking  = "ranking"
+	JobFollowed = "followed"
+	JobStory    = "story"
+
+	DefaultJobTimeout = time.Hour
+
+	// the lock of a running job is extended while it runs, the ttl only bounds how long
+	// the job of a crashed daemon stays locked
+	jobLockTTL = 2 * time.Minute
+)
+
+// Job is a crawl run on a schedule, the fields after Command are its arguments.
+type Job struct {
+	Name     string `yaml:"name" json:"name,omitempty"`
+	Schedule string `yaml:"schedule" json:"schedule,omitempty"`
+	Command  string `yaml:"command" json:"command"`
+	Source   string `yaml:"source" json:"source,omitempty"`
+	Rank     string `yaml:"rank" json:"rank,omitempty"`
+	Pages    int    `yaml:"pages" json:"pages,omitempty"`
+	Chapters bool   `yaml:"chapters" json:"chapters,omitempty"`
+	// Stories are urls or slugs for the story command.
+	Stories []string `yaml:"stories" json:"stories,omitempty"`
+	// Jitter is the maximum random delay added to every run, it overrides the file default.
+	Jitter time.Duration `yaml:"jitter" json:"-"`
+	// Timeout cancels a run that takes longer, it overrides the file default.
+	Timeout time.Duration `yaml:"timeout" json:"-"`
+
+	schedule schedule.Schedule
+}
+
+// Validate checks the command and its arguments, not the schedule.
+func (j *Job) Validate() error {
+	switch j.Command {
+	case JobCategory, JobRanking, JobFollowed:
+	case JobStory:
+		if len(j.Stories) == 0 {
+			return fmt.Errorf("crawler: story job without stories")
+		}
+	default:
+		return fmt.Errorf("crawler: unknown job command %q", j.Command)
+	}
+	return nil
+}
+
+type ScheduleFile struct {
+	Jitter  time.Duration `yaml:"jitter"`
+	Timeout time.Duration `yaml:"timeout"`
+	Jobs    []*Job        `yaml:"jobs"`
+}
+
+func LoadSchedule(path string) (*ScheduleFile, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	file := &ScheduleFile{}
+	if err := yaml.Unmarshal(content, file); err != nil {
+		return nil, fmt.Errorf("crawler: %s: %w", path, err)
+	}
+	if file.Timeout <= 0 {
+		file.Timeout = DefaultJobTimeout
+	}
+
+	names := map[string]bool{}
+	for _, job := range file.Jobs {
+		if job.Name == "" {
+			return nil, fmt.Errorf("crawler: %s: job without name", path)
+		}
+		if names[job.Name] {
+			return nil, fmt.Errorf("crawler: %s: job %q defined twice", path, job.Name)
+		}
+		names[job.Name] = true
+
+		if err := job.Validate(); err != nil {
+			return nil, fmt.Errorf("%w: %s: job %q", err, path, job.Name)
+		}
+
+		job.schedule, err = schedule.Parse(job.Schedule)
+		if err != nil {
+			return nil, fmt.Errorf("crawler: %s: job %q: %w", path, job.Name, err)
+		}
+		if job.Jitter == 0 {
+			job.Jitter = file.Jitter
+		}
+		if job.Timeout <= 0 {
+			job.Timeout = file.Timeout
+		}
+	}
+	return file, nil
+}
+
+// CrawlerFactory returns a crawler for a source name, an empty name means the default source.
+type CrawlerFactory func(source string) (*Crawler, error)
+
+// Daemon runs the jobs of a ScheduleFile and the runs queued through the admin API.
+// A Redis lock per job keeps two daemons, or a run that outlives its interval, from
+// crawling the same job at the same time.
+type Daemon struct {
+	jobs       []*Job
+	db         *bun.DB
+	redis      redis.UniversalClient
+	newCrawler CrawlerFactory
+	grace      time.Duration
+	token      string
+}
+
+func NewDaemon(file *ScheduleFile, db *bun.DB, client redis.UniversalClient, newCrawler CrawlerFactory, grace time.Duration) (*Daemon, error) {
+	random := make([]byte, 8)
+	if _, err := rand.Read(random); err != nil {
+		return nil, err
+	}
+	hostname, _ := os.Hostname()
+
+	return &Daemon{
+		jobs:       file.Jobs,
+		db:         db,
+		redis:      client,
+		newCrawler: newCrawler,
+		grace:      grace,
+		token:      fmt.Sprintf("%s-%d-%s", hostname, os.Getpid(), hex.EncodeToString(random)),
+	}, nil
+}
+
+// Run schedules jobs until ctx is done, then waits for the running jobs. Jobs still
+// running after the grace period have their context canceled.
+func (d *Daemon) Run(ctx context.Context) error {
+	workCtx, cancelWork := context.WithCancel(context.Background())
+	defer cancelWork()
+
+	var wg sync.WaitGroup
+	for _, job := range d.jobs {
+		wg.Add(1)
+		go func(job *Job) {
+			defer wg.Done()
+			d.loop(ctx, workCtx, job)
+		}(job)
+	}
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		d.consumeQueue(ctx, workCtx)
+	}()
+
+	<-ctx.Done()
+	log.Println("daemon: shutting down, waiting for running jobs")
+
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	timer := time.NewTimer(d.grace)
+	defer timer.Stop()
+	select {
+	case <-done:
+	case <-timer.C:
+		log.Println("daemon: grace period over, canceling running jobs")
+		cancelWork()
+		<-done
+	}
+	return nil
+}
+
+func (d *Daemon) loop(ctx, workCtx context.Context, job *Job) {
+	for {
+		next := job.schedule.Next(time.Now())
+		if next.IsZero() {
+			log.Printf("daemon: job %s never runs again\n", job.Name)
+			return
+		}
+		if job.Jitter > 0 {
+			next = next.Add(time.Duration(math_rand.Int63n(int64(job.Jitter))))
+		}
+
+		timer := time.NewTimer(time.Until(next))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+
+		d.runOnce(workCtx, job)
+	}
+}
+
+func (d *Daemon) runOnce(ctx context.Context, job *Job) {
+	acquired, err := redis_store.AcquireLock(ctx, d.redis, "crawl-job:"+job.Name, d.token, jobLockTTL)
+	if err != nil {
+		log.Printf("daemon: job %s: lock: %v\n", job.Name, err)
+		return
+	}
+	if !acquired {
+		log.Printf("daemon: job %s is already running, skipped\n", job.Name)
+		return
+	}
+	defer func() {
+		// the run context may be canceled already, releasing must still happen
+		if err := redis_store.ReleaseLock(context.Background(), d.redis, "crawl-job:"+job.Name, d.token); err != nil {
+			log.Printf("daemon: job %s: release lock: %v\n", job.Name, err)
+		}
+	}()
+
+	runCtx, cancel := context.WithTimeout(ctx, job.Timeout)
+	defer cancel()
+
+	// extended until the run has unwound, not only until runCtx is done
+	stop := make(chan struct{})
+	defer close(stop)
+	go d.keepLock(job, cancel, stop)
+
+	started := time.Now()
+	log.Printf("daemon: job %s started\n", job.Name)
+	if err := d.record(runCtx, job, nil); err != nil {
+		log.Printf("daemon: job %s failed after %s: %v\n", job.Name, time.Since(started).Round(time.Second), err)
+		return
+	}
+	log.Printf("daemon: job %s done in %s\n", job.Name, time.Since(started).Round(time.Second))
+}
+
+// keepLock extends the lock of job every jobLockTTL/3 until stop is closed. A run whose
+// lock was taken over, e.g. after Redis lost the key, is canceled rather than left to
+// run next to the new owner.
+func (d *Daemon) keepLock(job *Job, cancel context.CancelFunc, stop <-chan struct{}) {
+	ticker := time.NewTicker(jobLockTTL / 3)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+
+		held, err := redis_store.ExtendLock(context.Background(), d.redis, "crawl-job:"+job.Name, d.token, jobLockTTL)
+		if err != nil {
+			// the lock survives until the next tick, which tries again
+			log.Printf("daemon: job %s: extend lock: %v\n", job.Name, err)
+			continue
+		}
+		if !held {
+			log.Printf("daemon: job %s lost its lock, canceled\n", job.Name)
+			cancel()
+			return
+		}
+	}
+}
+
+// consumeQueue runs the crawls triggered through the admin API one at a time.
+func (d *Daemon) consumeQueue(ctx, workCtx context.Context) {
+	for ctx.Err() == nil {
+		runID, err := redis_store.PopCrawlRun(ctx, d.redis, 5*time.Second)
+		if err != nil {
+			if ctx.Err() == nil {
+				log.Printf("daemon: crawl queue: %v\n", err)
+				time.Sleep(5 * time.Second)
+			}
+			continue
+		}
+		if runID == 0 {
+			continue
+		}
+
+		run, err := datastore.FindCrawlRunByID(workCtx, d.db, runID)
+		if err != nil {
+			log.Printf("daemon: queued run %d: %v\n", runID, err)
+			continue
+		}
+		job, err := JobFromRun(run)
+		if err != nil {
+			log.Println("daemon:", err)
+			continue
+		}
+
+		runCtx, cancel := context.WithTimeout(workCtx, DefaultJobTimeout)
+		log.Printf("daemon: queued run %d (%s) started\n", run.ID, run.Command)
+		if err := d.record(runCtx, job, run); err != nil {
+			log.Printf("daemon: queued run %d failed: %v\n", run.ID, err)
+		}
+		cancel()
+	}
+}
+
+// record runs job through a new crawler, run is nil for scheduled jobs.
+func (d *Daemon) record(ctx context.Context, job *Job, run *models.CrawlRun) error {
+	cr, err := d.newCrawler(job.Source)
+	if err != nil {
+		if run != nil {
+			run.Status = models.CrawlRunFailed
+			run.Error = err.Error()
+			//nolint:errcheck
+			datastore.UpdateCrawlRun(ctx, d.db, run)
+		}
+		return err
+	}
+
+	if run == nil {
+		run, err = NewRun(job, models.CrawlTriggerSchedule)
+		if err != nil {
+			return err
+		}
+	}
+	return cr.Record(ctx, run, job)
+}
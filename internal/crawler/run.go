@@ -0,0 +1,183 @@
+package crawler
+
+import (
+	"context"
+	"demo-cosebase/internal/datastore"
+	"demo-cosebase/internal/models"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxRunErrors bounds the errors stored for one run, a broken source can fail on
+// every chapter of every story.
+const maxRunErrors = 1000
+
+type stats struct {
+	mu       sync.Mutex
+	stories  int
+	chapters int
+	failures int
+	errors   []*models.CrawlError
+}
+
+func (s *stats) storyFetched() {
+	s.mu.Lock()
+	s.stories++
+	s.mu.Unlock()
+}
+
+func (s *stats) chapterSaved() {
+	s.mu.Lock()
+	s.chapters++
+	s.mu.Unlock()
+}
+
+func (s *stats) fail(url string, err error) {
+	log.Println(url, err)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failures++
+	if len(s.errors) < maxRunErrors {
+		s.errors = append(s.errors, &models.CrawlError{URL: url, Message: err.Error(), CreatedAt: time.Now().Unix()})
+	}
+}
+
+func (s *stats) reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.stories, s.chapters, s.failures, s.errors = 0, 0, 0, nil
+}
+
+func storyURL(ref StoryRef) string {
+	if ref.URL != "" {
+		return ref.URL
+	}
+	return ref.Slug
+}
+
+// NewRun returns an unsaved run of job.
+func NewRun(job *Job, trigger string) (*models.CrawlRun, error) {
+	args, err := json.Marshal(job)
+	if err != nil {
+		return nil, err
+	}
+	run := &models.CrawlRun{
+		Source:  job.Source,
+		Command: job.Command,
+		Args:    args,
+		Trigger: trigger,
+	}
+	if trigger == models.CrawlTriggerSchedule {
+		run.Job = job.Name
+	}
+	return run, nil
+}
+
+// JobFromRun reads back the job of a run created by NewRun.
+func JobFromRun(run *models.CrawlRun) (*Job, error) {
+	job := &Job{}
+	if err := json.Unmarshal(run.Args, job); err != nil {
+		return nil, fmt.Errorf("crawler: run %d: %w", run.ID, err)
+	}
+	job.Source = run.Source
+	job.Command = run.Command
+	return job, nil
+}
+
+// Record runs job and stores it as run: counts, errors with their url, and the outcome.
+// Queued runs are updated, others are created.
+func (c *Crawler) Record(ctx context.Context, run *models.CrawlRun, job *Job) error {
+	c.stats.reset()
+
+	run.Source = c.source.Name()
+	run.Status = models.CrawlRunRunning
+	run.StartedAt = time.Now().Unix()
+	var err error
+	if run.ID == 0 {
+		_, err = datastore.CreateCrawlRun(ctx, c.db, run)
+	} else {
+		_, err = datastore.UpdateCrawlRun(ctx, c.db, run)
+	}
+	if err != nil {
+		return err
+	}
+
+	runErr := c.RunJob(ctx, job)
+
+	c.stats.mu.Lock()
+	run.StoriesFetched = c.stats.stories
+	run.ChaptersSaved = c.stats.chapters
+	run.ErrorCount = c.stats.failures
+	crawlErrors := c.stats.errors
+	c.stats.mu.Unlock()
+
+	run.FinishedAt = time.Now().Unix()
+	run.Status = models.CrawlRunSucceeded
+	if runErr != nil {
+		run.Status = models.CrawlRunFailed
+		run.Error = runErr.Error()
+	}
+
+	// the run context may be canceled, the outcome must still be saved
+	saveCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	for _, crawlError := range crawlErrors {
+		crawlError.RunID = run.ID
+	}
+	if err := datastore.CreateCrawlErrors(saveCtx, c.db, crawlErrors); err != nil {
+		log.Printf("crawl run %d: save errors: %v\n", run.ID, err)
+	}
+	if _, err := datastore.UpdateCrawlRun(saveCtx, c.db, run); err != nil {
+		log.Printf("crawl run %d: save run: %v\n", run.ID, err)
+	}
+	return runErr
+}
+
+func (c *Crawler) RunJob(ctx context.Context, job *Job) error {
+	switch job.Command {
+	case JobCategory:
+		_, err := c.CrawlCategories(ctx)
+		return err
+	case JobRanking:
+		rank := job.Rank
+		if rank == "" {
+			rank = c.source.Rankings()[0]
+		}
+		pages := job.Pages
+		if pages <= 0 {
+			pages = 1
+		}
+		for page := 1; page <= pages; page++ {
+			if _, err := c.CrawlRanking(ctx, rank, page, job.Chapters); err != nil {
+				return err
+			}
+		}
+		return nil
+	case JobFollowed:
+		_, err := c.CrawlFollowed(ctx)
+		return err
+	case JobStory:
+		_, err := c.CrawlStories(ctx, StoryRefs(job.Stories), job.Chapters)
+		return err
+	}
+	return fmt.Errorf("crawler: unknown job command %q", job.Command)
+}
+
+// StoryRefs reads story urls or slugs.
+func StoryRefs(args []string) []StoryRef {
+	refs := make([]StoryRef, 0, len(args))
+	for _, arg := range args {
+		if strings.HasPrefix(arg, "http://") || strings.HasPrefix(arg, "https://") {
+			refs = append(refs, StoryRef{URL: arg})
+		} else {
+			refs = append(refs, StoryRef{Slug: arg})
+		}
+	}
+	return refs
+}
@@ -0,0 +1,116 @@
+package crawler
+
+import (
+	"context"
+	"demo-cosebase/pkg/fetcher"
+	"demo-cosebase/pkg/textclean"
+	"fmt"
+	"sort"
+	"sync"
+)
+
+type Category struct {
+	Slug string
+	Name string
+	URL  string
+}
+
+// StoryRef points to a story page on a source, it is what listings return.
+type StoryRef struct {
+	SourceID string
+	Slug     string
+	URL      string
+	Title    string
+}
+
+// Story is a story as read on its source, Description is raw HTML cleaned by the Crawler.
+type Story struct {
+	StoryRef
+	OriginalTitle string
+	Author        string
+	// Status is one of the public models.Story statuses, empty when the page does not
+	// tell.
+	Status      string
+	Description string
+	ImageURL    string
+	Categories  []Category
+}
+
+type ChapterRef struct {
+	Number int
+	Volume string
+	Title  string
+	URL    string
+}
+
+// Chapter is a chapter as read on its source, Content is raw HTML cleaned by the Crawler.
+type Chapter struct {
+	ChapterRef
+	Content string
+}
+
+// Source is a site we crawl stories from.
+type Source interface {
+	// Name is the registry name, it is also stored as the source of stories and chapters.
+	Name() string
+	// Rankings lists the ranking names accepted by ListRanking.
+	Rankings() []string
+	ListRanking(ctx context.Context, ranking string, page int) ([]StoryRef, error)
+	ListCategories(ctx context.Context) ([]Category, error)
+	FetchStory(ctx context.Context, ref StoryRef) (*Story, error)
+	ListChapters(ctx context.Context, story *Story) ([]ChapterRef, error)
+	FetchChapter(ctx context.Context, ref ChapterRef) (*Chapter, error)
+}
+
+// CleaningRules is implemented by sources that need their own text cleaning rules,
+// they are merged into textclean.DefaultRules.
+type CleaningRules interface {
+	CleaningRules() *textclean.Rules
+}
+
+// StoryURLs is implemented by sources that can build the page url of a story from its
+// slug, it is used to seed replay archives with saved story pages.
+type StoryURLs interface {
+	StoryURL(slug string) string
+}
+
+type Factory func(f *fetcher.Fetcher) Source
+
+var (
+	registryMu sync.RWMutex
+	registry   = map[string]Factory{}
+)
+
+// Register makes a source available by name, adapters call it from init.
+func Register(name string, factory Factory) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	if _, ok := registry[name]; ok {
+		panic(fmt.Sprintf("crawler: source %q registered twice", name))
+	}
+	registry[name] = factory
+}
+
+func New(name string, f *fetcher.Fetcher) (Source, error) {
+	registryMu.RLock()
+	factory, ok := registry[name]
+	registryMu.RUnlock()
+
+	if !ok {
+		return nil, fmt.Errorf("crawler: unknown source %q", name)
+	}
+	return factory(f), nil
+}
+
+func Sources() []string {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,25 @@
+package tangthuvien
+
+import "demo-cosebase/pkg/textclean"
+
+// CleaningRules removes the decorative banners translators put around intros and
+// chapters, e.g. "✪☫▬▬▬AS▬▬✡ĐÃ KỊP TÁC✡▬▬ꙄA▬▬☫✪", and the site watermarks.
+func (s *Source) CleaningRules() *textclean.Rules {
+	return &textclean.Rules{
+		DropLines: []string{
+			`▬{3,}`,
+			`^\s*[-=_*~]{5,}\s*$`,
+			`(?i)^\s*(nguồn|source)\s*:?\s*(truyện\s*)?tàng\s*thư\s*viện\s*$`,
+		},
+		Remove: []string{
+			`[✪☫✡★☆]{2,}`,
+			`(?i)\(?\s*(nguồn\s*:?\s*)?(https?://)?(www\.)?tangthuvien\.(net|vn|com)\S*\s*\)?`,
+			`(?i)\bbạn đang đọc truyện (mới )?tại\b.*$`,
+		},
+		PaywallMarkers: []string{
+			"chương này là chương vip",
+			"vui lòng đăng nhập để đọc",
+			"mua chương để đọc tiếp",
+		},
+	}
+}
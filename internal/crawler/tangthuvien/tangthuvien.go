@@ -0,0 +1,301 @@
+package tangthuvien
+
+import (
+	"bytes"
+	"context"
+	"demo-cosebase/internal/crawler"
+	"demo-cosebase/internal/models"
+	"demo-cosebase/pkg"
+	"demo-cosebase/pkg/fetcher"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+const (
+	Name            = "tangthuvien"
+	BaseURL         = "https://tangthuvien.net/"
+	chaptersPerPage = 75
+)
+
+var (
+	chapterNumberRegex = regexp.MustCompile(`/chuong-(\d+)`)
+	storyIDRegex       = regexp.MustCompile(`followStory\((?:'|&#39;)(\d+)`)
+)
+
+func init() {
+	crawler.Register(Name, func(f *fetcher.Fetcher) crawler.Source {
+		return New(f, BaseURL)
+	})
+}
+
+type Source struct {
+	fetcher *fetcher.Fetcher
+	baseURL string
+}
+
+func New(f *fetcher.Fetcher, baseURL string) *Source {
+	if !strings.HasSuffix(baseURL, "/") {
+		baseURL += "/"
+	}
+	return &Source{fetcher: f, baseURL: baseURL}
+}
+
+func (s *Source) Name() string {
+	return Name
+}
+
+// Rankings are the values of the rank query parameter on /tong-hop:
+// nm is nominations, vw is views.
+func (s *Source) Rankings() []string {
+	return []string{"nm", "vw"}
+}
+
+func (s *Source) ListRanking(ctx context.Context, ranking string, page int) ([]crawler.StoryRef, error) {
+	query := url.Values{"rank": {ranking}}
+	if page > 1 {
+		query.Set("page", strconv.Itoa(page))
+	}
+
+	doc, err := s.document(ctx, s.baseURL+"tong-hop?"+query.Encode())
+	if err != nil {
+		return nil, err
+	}
+
+	var refs []crawler.StoryRef
+	doc.Find("div.book-mid-info h4 a").Each(func(_ int, a *goquery.Selection) {
+		href, ok := a.Attr("href")
+		if !ok {
+			return
+		}
+		refs = append(refs, crawler.StoryRef{
+			Slug:  slugFromURL(href),
+			URL:   strings.TrimSpace(href),
+			Title: strings.TrimSpace(a.Text()),
+		})
+	})
+	return refs, nil
+}
+
+func (s *Source) ListCategories(ctx context.Context) ([]crawler.Category, error) {
+	doc, err := s.document(ctx, s.baseURL+"tong-hop")
+	if err != nil {
+		return nil, err
+	}
+
+	seen := map[string]bool{}
+	var categories []crawler.Category
+	doc.Find(`a[data-name="ctg"]`).Each(func(_ int, a *goquery.Selection) {
+		href, _ := a.Attr("href")
+		slug := slugFromURL(href)
+		if value, ok := a.Attr("data-value"); ok && slug == "" {
+			slug = value
+		}
+		if slug == "" || seen[slug] {
+			return
+		}
+		seen[slug] = true
+		categories = append(categories, crawler.Category{
+			Slug: slug,
+			Name: strings.TrimSpace(a.Text()),
+			URL:  href,
+		})
+	})
+	return categories, nil
+}
+
+// StoryURL returns the page of the story with slug.
+func (s *Source) StoryURL(slug string) string {
+	return s.baseURL + "doc-truyen/" + slug
+}
+
+func (s *Source) FetchStory(ctx context.Context, ref crawler.StoryRef) (*crawler.Story, error) {
+	storyURL := ref.URL
+	if storyURL == "" {
+		storyURL = s.StoryURL(ref.Slug)
+	}
+	storyURL, err := pkg.NormalizeURL(storyURL)
+	if err != nil {
+		return nil, err
+	}
+
+	content, err := s.fetcher.FetchContent(ctx, storyURL)
+	if err != nil {
+		return nil, err
+	}
+
+	story, err := ParseStory(content)
+	if err != nil {
+		return nil, err
+	}
+	story.URL = storyURL
+	story.Slug = slugFromURL(storyURL)
+	return story, nil
+}
+
+// ParseStory reads the story page, or the div.book-information fragment of it.
+func ParseStory(content string) (*crawler.Story, error) {
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(content))
+	if err != nil {
+		return nil, err
+	}
+
+	story := &crawler.Story{}
+	story.SourceID = strconv.Itoa(pkg.GetStoryID(content))
+	if story.SourceID == "0" {
+		story.SourceID = ""
+		if match := storyIDRegex.FindStringSubmatch(content); len(match) == 2 {
+			story.SourceID = match[1]
+		}
+	}
+
+	info := doc.Find("div.book-info").First()
+	story.Title, story.OriginalTitle = splitTitle(info.Find("h1").First().Text())
+
+	tag := info.Find("p.tag").First()
+	tag.Find("a").Each(func(_ int, a *goquery.Selection) {
+		href, _ := a.Attr("href")
+		switch {
+		case strings.Contains(href, "tac-gia"):
+			story.Author = strings.TrimSpace(a.Text())
+		case strings.Contains(href, "the-loai"):
+			story.Categories = append(story.Categories, crawler.Category{
+				Slug: slugFromURL(href),
+				Name: strings.TrimSpace(a.Text()),
+				URL:  href,
+			})
+		}
+	})
+	story.Status = storyStatus(tag.Find("span.blue").First().Text())
+
+	intro := doc.Find("div.book-intro").First()
+	if intro.Length() == 0 {
+		intro = info.Find("p.intro").First()
+	}
+	story.Description, err = intro.Html()
+	if err != nil {
+		return nil, err
+	}
+
+	story.ImageURL, _ = doc.Find("div.book-img img").First().Attr("src")
+	story.ImageURL = strings.TrimSpace(story.ImageURL)
+	return story, nil
+}
+
+func (s *Source) ListChapters(ctx context.Context, story *crawler.Story) ([]crawler.ChapterRef, error) {
+	if story.SourceID == "" {
+		return nil, fmt.Errorf("tangthuvien: story %s has no id", story.URL)
+	}
+
+	var refs []crawler.ChapterRef
+	volume := ""
+	for page := 0; ; page++ {
+		pageURL := fmt.Sprintf("%sdoc-truyen/page/%s?page=%d&limit=%d&web=1", s.baseURL, story.SourceID, page, chaptersPerPage)
+		doc, err := s.document(ctx, pageURL)
+		if err != nil {
+			return nil, err
+		}
+
+		found := 0
+		doc.Find("li").Each(func(_ int, li *goquery.Selection) {
+			if li.HasClass("divider-chap") {
+				volume = strings.TrimSpace(li.Text())
+				return
+			}
+			a := li.Find("a").First()
+			href, _ := a.Attr("href")
+			match := chapterNumberRegex.FindStringSubmatch(href)
+			if len(match) != 2 {
+				return
+			}
+			number, err := strconv.Atoi(match[1])
+			if err != nil {
+				return
+			}
+
+			title, ok := a.Attr("title")
+			if !ok || strings.TrimSpace(title) == "" {
+				title = a.Text()
+			}
+			found++
+			refs = append(refs, crawler.ChapterRef{
+				Number: number,
+				Volume: volume,
+				Title:  strings.TrimSpace(title),
+				URL:    strings.TrimSpace(href),
+			})
+		})
+
+		if found < chaptersPerPage {
+			break
+		}
+	}
+	return refs, nil
+}
+
+func (s *Source) FetchChapter(ctx context.Context, ref crawler.ChapterRef) (*crawler.Chapter, error) {
+	doc, err := s.document(ctx, ref.URL)
+	if err != nil {
+		return nil, err
+	}
+
+	chapter := &crawler.Chapter{ChapterRef: ref}
+	if title := strings.TrimSpace(doc.Find("div.chapter h2").First().Text()); title != "" {
+		chapter.Title = title
+	}
+
+	box := doc.Find("div.chapter-c-content div.box-chap").Not(".hidden").First()
+	if box.Length() == 0 {
+		return nil, fmt.Errorf("tangthuvien: no content in %s", ref.URL)
+	}
+	chapter.Content, err = box.Html()
+	if err != nil {
+		return nil, err
+	}
+	return chapter, nil
+}
+
+func (s *Source) document(ctx context.Context, rawURL string) (*goquery.Document, error) {
+	resp, err := s.fetcher.Fetch(ctx, rawURL)
+	if err != nil {
+		return nil, err
+	}
+	return goquery.NewDocumentFromReader(bytes.NewReader(resp.Body))
+}
+
+// splitTitle splits "Đạo Quân  - 道君" into the Vietnamese and the original title.
+func splitTitle(title string) (string, string) {
+	title = strings.TrimSpace(title)
+	i := strings.LastIndex(title, " - ")
+	if i < 0 {
+		return title, ""
+	}
+	return strings.TrimSpace(title[:i]), strings.TrimSpace(title[i+3:])
+}
+
+func slugFromURL(rawURL string) string {
+	u, err := url.Parse(strings.TrimSpace(rawURL))
+	if err != nil {
+		return ""
+	}
+	parts := strings.Split(strings.Trim(u.Path, "/"), "/")
+	return parts[len(parts)-1]
+}
+
+// storyStatus reads the status label of a story page, e.g. "Đang ra" or "Đã hoàn thành".
+func storyStatus(label string) string {
+	label = strings.ToLower(strings.TrimSpace(label))
+	switch {
+	case strings.Contains(label, "hoàn"), label == "full":
+		return models.StoryCompleted
+	case strings.Contains(label, "tạm dừng"):
+		return models.StoryPaused
+	case strings.Contains(label, "đang"):
+		return models.StoryOngoing
+	}
+	return ""
+}
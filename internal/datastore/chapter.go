@@ -0,0 +1,305 @@
+package datastore
+
+import (
+	"context"
+	"demo-cosebase/internal/models"
+	"github.com/uptrace/bun"
+)
+
+func CreateTableChapter(ctx context.Context, db *bun.DB) error {
+	_, err := db.NewCreateTable().Model((*models.Chapter)(nil)).IfNotExists().Exec(ctx)
+	if err != nil {
+		return err
+	}
+
+	// the table predates the publishing workflow, stored chapters are published
+	for _, column := range []string{
+		`status VARCHAR NOT NULL DEFAULT 'published'`,
+		`publish_at BIGINT`,
+		`published_at BIGINT`,
+		`uploader_id BIGINT`,
+	} {
+		if _, err := db.ExecContext(ctx, "ALTER TABLE chapter ADD COLUMN IF NOT EXISTS "+column); err != nil {
+			return err
+		}
+	}
+	_, err = db.NewCreateIndex().Model((*models.Chapter)(nil)).IfNotExists().
+		Index("chapter_scheduled_idx").Column("publish_at").Where("status = 'scheduled'").Exec(ctx)
+	if err != nil {
+		return err
+	}
+	_, err = db.NewCreateIndex().Model((*models.Chapter)(nil)).IfNotExists().
+		Index("chapter_uploader_id_idx").Column("uploader_id").Where("uploader_id IS NOT NULL").Exec(ctx)
+	if err != nil {
+		return err
+	}
+
+	_, err = db.NewCreateTable().Model((*models.ChapterRevision)(nil)).IfNotExists().Exec(ctx)
+	return err
+}
+
+func UpsertChapter(ctx context.Context, db bun.IDB, chapter *models.Chapter) (*models.Chapter, error) {
+	_, err := db.NewInsert().Model(chapter).
+		On("CONFLICT (story_id, source, number) DO UPDATE").
+		Set("volume = EXCLUDED.volume").
+		Set("title = EXCLUDED.title").
+		Set("content = EXCLUDED.content").
+		Set("url = EXCLUDED.url").
+		Set("update_at = EXCLUDED.update_at").
+		Returning("*").
+		Exec(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return chapter, nil
+}
+
+// FindChapterNumbers returns the chapter numbers of a story already stored for source.
+func FindChapterNumbers(ctx context.Context, db bun.IDB, storyID int64, source string) ([]int, error) {
+	var numbers []int
+	err := db.NewSelect().Model((*models.Chapter)(nil)).
+		Column("number").
+		Where("story_id = ?", storyID).
+		Where("source = ?", source).
+		Order("number").
+		Scan(ctx, &numbers)
+	if err != nil {
+		return nil, err
+	}
+	return numbers, nil
+}
+
+// FindChapterRange returns up to limit published chapters of a story numbered after
+// `after` and within from and to, a zero bound is open. When several sources have the
+// same number the most recently updated one is returned.
+func FindChapterRange(ctx context.Context, db bun.IDB, storyID int64, from, to, after, limit int) ([]*models.Chapter, error) {
+	var chapters []*models.Chapter
+	q := db.NewSelect().Model(&chapters).
+		DistinctOn("number").
+		Where("story_id = ?", storyID).
+		Where("status = ?", models.ChapterPublished).
+		Where("number > ?", after).
+		OrderExpr("number, update_at DESC").
+		Limit(limit)
+	if from > 0 {
+		q = q.Where("number >= ?", from)
+	}
+	if to > 0 {
+		q = q.Where("number <= ?", to)
+	}
+	if err := q.Scan(ctx); err != nil {
+		return nil, err
+	}
+	return chapters, nil
+}
+
+// FindChaptersByNumbers returns the chapters of a story from source with one of numbers.
+func FindChaptersByNumbers(ctx context.Context, db bun.IDB, storyID int64, source string, numbers []int) ([]*models.Chapter, error) {
+	var chapters []*models.Chapter
+	if len(numbers) == 0 {
+		return chapters, nil
+	}
+	err := db.NewSelect().Model(&chapters).
+		Where("story_id = ?", storyID).
+		Where("source = ?", source).
+		Where("number IN (?)", bun.In(numbers)).
+		Order("number").
+		Scan(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return chapters, nil
+}
+
+// RecentChaptersFilter selects the stories of FindRecentChapters, by story, category or
+// follower. Only one field is expected to be set.
+type RecentChaptersFilter struct {
+	StoryID    int64
+	CategoryID int64
+	FollowerID int64
+}
+
+// FindRecentChapters returns the latest published chapters with their story and the
+// first excerptLength characters of their content. A number published by several
+// sources appears once, when it first appeared. Uploaded chapters date from their
+// publication, not from their draft. Chapters of hidden stories are left out.
+func FindRecentChapters(ctx context.Context, db bun.IDB, filter *RecentChaptersFilter, excerptLength, limit int) ([]*models.Chapter, error) {
+	var chapters []*models.Chapter
+	q := db.NewSelect().Model(&chapters).
+		Column("chapter.id", "chapter.story_id", "chapter.source", "chapter.number", "chapter.volume",
+			"chapter.title", "chapter.url", "chapter.publisher", "chapter.create_at", "chapter.update_at",
+			"chapter.published_at").
+		ColumnExpr("left(chapter.content, ?) AS content", excerptLength).
+		Relation("Story", func(q *bun.SelectQuery) *bun.SelectQuery {
+			return q.Column("id", "slug", "tiltle", "author")
+		}).
+		Where("chapter.status = ?", models.ChapterPublished).
+		Where("story.status IN (?)", bun.In(models.PublicStoryStatuses)).
+		Where("NOT EXISTS (SELECT 1 FROM chapter AS o WHERE o.story_id = chapter.story_id AND o.number = chapter.number AND o.status = ? AND o.id < chapter.id)", models.ChapterPublished).
+		OrderExpr("GREATEST(chapter.published_at, chapter.create_at) DESC, chapter.id DESC").
+		Limit(limit)
+	switch {
+	case filter.StoryID != 0:
+		q = q.Where("chapter.story_id = ?", filter.StoryID)
+	case filter.CategoryID != 0:
+		q = q.Where("chapter.story_id IN (SELECT story_id FROM story_category WHERE category_id = ?)", filter.CategoryID)
+	case filter.FollowerID != 0:
+		q = q.Where("chapter.story_id IN (SELECT story_id FROM story_follow WHERE user_id = ?)", filter.FollowerID)
+	}
+
+	if err := q.Scan(ctx); err != nil {
+		return nil, err
+	}
+	return chapters, nil
+}
+
+func CreateChapter(ctx context.Context, db bun.IDB, chapter *models.Chapter) (*models.Chapter, error) {
+	_, err := db.NewInsert().Model(chapter).Exec(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return chapter, nil
+}
+
+func FindChapterByID(ctx context.Context, db bun.IDB, ID int64) (*models.Chapter, error) {
+	chapter := &models.Chapter{}
+	err := db.NewSelect().Model(chapter).Where("id = ?", ID).Scan(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return chapter, nil
+}
+
+// UpdateChapterText stores the volume, title and content of chapter and reloads the
+// rest, so a status changed meanwhile is neither overwritten nor lost.
+func UpdateChapterText(ctx context.Context, db bun.IDB, chapter *models.Chapter) (*models.Chapter, error) {
+	err := db.NewUpdate().Model(chapter).
+		Column("volume", "title", "content", "update_at").
+		WherePK().
+		Returning("*").
+		Scan(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return chapter, nil
+}
+
+// ScheduleChapter schedules a chapter unless it is published, in which case it returns
+// sql.ErrNoRows.
+func ScheduleChapter(ctx context.Context, db bun.IDB, ID int64, publishAt, now int64) (*models.Chapter, error) {
+	chapter := &models.Chapter{}
+	err := db.NewUpdate().Model(chapter).
+		Set("status = ?", models.ChapterScheduled).
+		Set("publish_at = ?", publishAt).
+		Set("update_at = ?", now).
+		Where("id = ?", ID).
+		Where("status <> ?", models.ChapterPublished).
+		Returning("*").
+		Scan(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return chapter, nil
+}
+
+// PublishChapter publishes a chapter unless it already is, in which case it returns
+// sql.ErrNoRows. Like PublishDueChapters the check is part of the update, so a chapter
+// is published, and its followers notified, once.
+func PublishChapter(ctx context.Context, db bun.IDB, ID int64, now int64) (*models.Chapter, error) {
+	chapter := &models.Chapter{}
+	err := db.NewUpdate().Model(chapter).
+		Set("status = ?", models.ChapterPublished).
+		Set("publish_at = NULL").
+		Set("published_at = ?", now).
+		Set("update_at = ?", now).
+		Where("id = ?", ID).
+		Where("status <> ?", models.ChapterPublished).
+		Returning("*").
+		Scan(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return chapter, nil
+}
+
+// FindUploaderChapters returns the chapters uploaded by a user, newest first, without
+// their content. An empty status returns them all.
+func FindUploaderChapters(ctx context.Context, db bun.IDB, uploaderID int64, status string, limit, offset int) ([]*models.Chapter, int, error) {
+	var chapters []*models.Chapter
+	q := db.NewSelect().Model(&chapters).
+		ExcludeColumn("content").
+		Where("uploader_id = ?", uploaderID).
+		Order("update_at DESC", "id DESC").
+		Limit(limit).
+		Offset(offset)
+	if status != "" {
+		q = q.Where("status = ?", status)
+	}
+
+	total, err := q.ScanAndCount(ctx)
+	if err != nil {
+		return nil, 0, err
+	}
+	return chapters, total, nil
+}
+
+// PublishDueChapters publishes the scheduled chapters whose time has come and returns
+// them. The update is a single statement so two instances never publish, and notify
+// about, the same chapter. Chapters of stories hidden from readers stay scheduled until
+// the story is public again.
+func PublishDueChapters(ctx context.Context, db bun.IDB, now int64) ([]*models.Chapter, error) {
+	var chapters []*models.Chapter
+	_, err := db.NewUpdate().Model((*models.Chapter)(nil)).
+		Set("status = ?", models.ChapterPublished).
+		Set("published_at = ?", now).
+		Set("update_at = ?", now).
+		Where("status = ?", models.ChapterScheduled).
+		Where("publish_at <= ?", now).
+		Where("story_id IN (SELECT id FROM story WHERE status IN (?))", bun.In(models.PublicStoryStatuses)).
+		Returning("id, story_id, number, title").
+		Exec(ctx, &chapters)
+	if err != nil {
+		return nil, err
+	}
+	return chapters, nil
+}
+
+func CreateChapterRevision(ctx context.Context, db bun.IDB, revision *models.ChapterRevision) (*models.ChapterRevision, error) {
+	// numbered in the insert, two concurrent edits fail on the unique index instead of
+	// sharing a number
+	_, err := db.NewInsert().Model(revision).
+		Value("revision", "(SELECT coalesce(max(revision), 0) + 1 FROM chapter_revision WHERE chapter_id = ?)", revision.ChapterID).
+		Returning("id, revision").
+		Exec(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return revision, nil
+}
+
+// FindChapterRevisions returns the revisions of a chapter, newest first, without their
+// content.
+func FindChapterRevisions(ctx context.Context, db bun.IDB, chapterID int64) ([]*models.ChapterRevision, error) {
+	var revisions []*models.ChapterRevision
+	err := db.NewSelect().Model(&revisions).
+		ExcludeColumn("content").
+		Where("chapter_id = ?", chapterID).
+		Order("revision DESC").
+		Scan(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return revisions, nil
+}
+
+func FindChapterRevision(ctx context.Context, db bun.IDB, chapterID int64, revision int) (*models.ChapterRevision, error) {
+	rev := &models.ChapterRevision{}
+	err := db.NewSelect().Model(rev).
+		Where("chapter_id = ?", chapterID).
+		Where("revision = ?", revision).
+		Scan(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return rev, nil
+}
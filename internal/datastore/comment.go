@@ -0,0 +1,18 @@
+package datastore
+
+import (
+	"context"
+	"demo-cosebase/internal/models"
+	"github.com/uptrace/bun"
+)
+
+func CreateTableComment(ctx context.Context, db *bun.DB) error {
+	_, err := db.NewCreateTable().Model((*models.Comment)(nil)).IfNotExists().Exec(ctx)
+	if err != nil {
+		return err
+	}
+
+	_, err = db.NewCreateIndex().Model((*models.Comment)(nil)).IfNotExists().
+		Index("comment_story_id_idx").Column("story_id").Exec(ctx)
+	return err
+}
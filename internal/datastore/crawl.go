@@ -0,0 +1,85 @@
+package datastore
+
+import (
+	"context"
+	"demo-cosebase/internal/models"
+	"github.com/uptrace/bun"
+)
+
+type CrawlRunFilter struct {
+	Source string
+	Status string
+	Limit  int
+	Offset int
+}
+
+func CreateTableCrawlRun(ctx context.Context, db *bun.DB) error {
+	_, err := db.NewCreateTable().Model((*models.CrawlRun)(nil)).IfNotExists().Exec(ctx)
+	if err != nil {
+		return err
+	}
+
+	_, err = db.NewCreateTable().Model((*models.CrawlError)(nil)).IfNotExists().Exec(ctx)
+	if err != nil {
+		return err
+	}
+
+	_, err = db.NewCreateIndex().Model((*models.CrawlError)(nil)).IfNotExists().
+		Index("crawl_error_run_id_idx").Column("run_id").Exec(ctx)
+	return err
+}
+
+func CreateCrawlRun(ctx context.Context, db bun.IDB, run *models.CrawlRun) (*models.CrawlRun, error) {
+	_, err := db.NewInsert().Model(run).Exec(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return run, nil
+}
+
+func UpdateCrawlRun(ctx context.Context, db bun.IDB, run *models.CrawlRun) (*models.CrawlRun, error) {
+	_, err := db.NewUpdate().Model(run).WherePK().Exec(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return run, nil
+}
+
+func CreateCrawlErrors(ctx context.Context, db bun.IDB, crawlErrors []*models.CrawlError) error {
+	if len(crawlErrors) == 0 {
+		return nil
+	}
+	_, err := db.NewInsert().Model(&crawlErrors).Exec(ctx)
+	return err
+}
+
+func FindCrawlRunByID(ctx context.Context, db bun.IDB, ID int64) (*models.CrawlRun, error) {
+	run := &models.CrawlRun{}
+	err := db.NewSelect().Model(run).
+		Relation("Errors", func(q *bun.SelectQuery) *bun.SelectQuery {
+			return q.Order("id")
+		}).
+		Where("crawl_run.id = ?", ID).
+		Scan(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return run, nil
+}
+
+func FindCrawlRuns(ctx context.Context, db bun.IDB, filter *CrawlRunFilter) ([]*models.CrawlRun, int, error) {
+	var runs []*models.CrawlRun
+	q := db.NewSelect().Model(&runs).Order("id DESC").Limit(filter.Limit).Offset(filter.Offset)
+	if filter.Source != "" {
+		q = q.Where("source = ?", filter.Source)
+	}
+	if filter.Status != "" {
+		q = q.Where("status = ?", filter.Status)
+	}
+
+	total, err := q.ScanAndCount(ctx)
+	if err != nil {
+		return nil, 0, err
+	}
+	return runs, total, nil
+}
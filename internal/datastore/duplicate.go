@@ -0,0 +1,228 @@
+package datastore
+
+import (
+	"context"
+	"demo-cosebase/internal/models"
+	"github.com/uptrace/bun"
+)
+
+func CreateTableStoryDuplicate(ctx context.Context, db *bun.DB) error {
+	_, err := db.NewCreateTable().Model((*models.StoryDuplicate)(nil)).IfNotExists().Exec(ctx)
+	return err
+}
+
+// FindStorySummaries returns every story with only the columns the duplicate matcher needs.
+func FindStorySummaries(ctx context.Context, db bun.IDB) ([]*models.Story, error) {
+	var stories []*models.Story
+	err := db.NewSelect().Model(&stories).
+		Column("id", "slug", "tiltle", "original_title", "author").
+		Order("id").
+		Scan(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return stories, nil
+}
+
+// CountStoryChapters returns the number of distinct published chapter numbers of every
+// story.
+func CountStoryChapters(ctx context.Context, db bun.IDB, storyIDs ...int64) (map[int64]int, error) {
+	var rows []struct {
+		StoryID int64 `bun:"story_id"`
+		Count   int   `bun:"count"`
+	}
+	q := db.NewSelect().Model((*models.Chapter)(nil)).
+		Column("story_id").
+		ColumnExpr("count(DISTINCT number) AS count").
+		Where("status = ?", models.ChapterPublished).
+		Group("story_id")
+	if len(storyIDs) > 0 {
+		q = q.Where("story_id IN (?)", bun.In(storyIDs))
+	}
+	if err := q.Scan(ctx, &rows); err != nil {
+		return nil, err
+	}
+
+	counts := make(map[int64]int, len(rows))
+	for _, row := range rows {
+		counts[row.StoryID] = row.Count
+	}
+	return counts, nil
+}
+
+// UpsertStoryDuplicates queues new pairs and refreshes the score of pending ones, pairs
+// already reviewed are left alone.
+func UpsertStoryDuplicates(ctx context.Context, db bun.IDB, duplicates []*models.StoryDuplicate) error {
+	if len(duplicates) == 0 {
+		return nil
+	}
+	_, err := db.NewInsert().Model(&duplicates).
+		On("CONFLICT (story_id, duplicate_id) DO UPDATE").
+		Set("score = EXCLUDED.score").
+		Set("reasons = EXCLUDED.reasons").
+		Where("story_duplicate.status = ?", models.DuplicatePending).
+		Exec(ctx)
+	return err
+}
+
+func FindStoryDuplicates(ctx context.Context, db bun.IDB, status string, limit, offset int) ([]*models.StoryDuplicate, int, error) {
+	var duplicates []*models.StoryDuplicate
+	withoutDescription := func(q *bun.SelectQuery) *bun.SelectQuery {
+		return q.ExcludeColumn("description")
+	}
+	q := db.NewSelect().Model(&duplicates).
+		Relation("Story", withoutDescription).
+		Relation("Duplicate", withoutDescription).
+		Order("score DESC", "story_duplicate.id").
+		Limit(limit).
+		Offset(offset)
+	if status != "" {
+		q = q.Where("story_duplicate.status = ?", status)
+	}
+
+	total, err := q.ScanAndCount(ctx)
+	if err != nil {
+		return nil, 0, err
+	}
+	return duplicates, total, nil
+}
+
+// FindStoryDuplicateForUpdate locks the pair until the transaction ends, two reviewers
+// cannot merge or dismiss it at once.
+func FindStoryDuplicateForUpdate(ctx context.Context, db bun.IDB, ID int64) (*models.StoryDuplicate, error) {
+	duplicate := &models.StoryDuplicate{}
+	err := db.NewSelect().Model(duplicate).Where("id = ?", ID).For("UPDATE").Scan(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return duplicate, nil
+}
+
+func UpdateStoryDuplicate(ctx context.Context, db bun.IDB, duplicate *models.StoryDuplicate) error {
+	_, err := db.NewUpdate().Model(duplicate).
+		Column("status", "reviewed_at", "reviewed_by").
+		WherePK().
+		Exec(ctx)
+	return err
+}
+
+// MergeStories moves everything attached to drop onto keep then deletes drop. Chapters
+// keep already has for the same source and number are dropped with their revisions,
+// comments on them move to the chapter of keep. The status history of drop moves to keep
+// and change, filled with the ids and statuses, records the merge. It must run in a
+// transaction.
+func MergeStories(ctx context.Context, tx bun.Tx, keep, drop *models.Story, change *models.StoryStatusChange) error {
+	if keep.OriginalTitle == "" {
+		keep.OriginalTitle = drop.OriginalTitle
+	}
+	if keep.Author == "" {
+		keep.Author = drop.Author
+	}
+	if keep.Description == "" {
+		keep.Description = drop.Description
+	}
+	if keep.CoverHash == "" {
+		keep.Image, keep.CoverHash, keep.CoverURL = drop.Image, drop.CoverHash, drop.CoverURL
+	}
+	if _, err := UpdateStory(ctx, tx, keep); err != nil {
+		return err
+	}
+
+	_, err := tx.NewRaw(`UPDATE comment AS c SET chapter_id = k.id
+		FROM chapter AS d JOIN chapter AS k ON k.story_id = ? AND k.source = d.source AND k.number = d.number
+		WHERE c.chapter_id = d.id AND d.story_id = ?`, keep.ID, drop.ID).Exec(ctx)
+	if err != nil {
+		return err
+	}
+	_, err = tx.NewUpdate().Model((*models.Comment)(nil)).
+		Set("story_id = ?", keep.ID).
+		Where("story_id = ?", drop.ID).
+		Exec(ctx)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.NewRaw(`DELETE FROM chapter_revision AS r USING chapter AS d, chapter AS k
+		WHERE r.chapter_id = d.id AND d.story_id = ?
+			AND k.story_id = ? AND k.source = d.source AND k.number = d.number`, drop.ID, keep.ID).Exec(ctx)
+	if err != nil {
+		return err
+	}
+	_, err = tx.NewDelete().Model((*models.Chapter)(nil)).
+		Where("story_id = ?", drop.ID).
+		Where("EXISTS (SELECT 1 FROM chapter AS k WHERE k.story_id = ? AND k.source = chapter.source AND k.number = chapter.number)", keep.ID).
+		Exec(ctx)
+	if err != nil {
+		return err
+	}
+	_, err = tx.NewUpdate().Model((*models.Chapter)(nil)).
+		Set("story_id = ?", keep.ID).
+		Where("story_id = ?", drop.ID).
+		Exec(ctx)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.NewUpdate().Model((*models.StorySource)(nil)).
+		Set("story_id = ?", keep.ID).
+		Where("story_id = ?", drop.ID).
+		Exec(ctx)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.NewRaw(`INSERT INTO story_follow (user_id, story_id, create_at)
+		SELECT user_id, ?, create_at FROM story_follow WHERE story_id = ?
+		ON CONFLICT DO NOTHING`, keep.ID, drop.ID).Exec(ctx)
+	if err != nil {
+		return err
+	}
+	_, err = tx.NewDelete().Model((*models.Follow)(nil)).Where("story_id = ?", drop.ID).Exec(ctx)
+	if err != nil {
+		return err
+	}
+
+	if err := mergeReading(ctx, tx, keep.ID, drop.ID); err != nil {
+		return err
+	}
+	if err := mergeStoryReports(ctx, tx, keep.ID, drop.ID); err != nil {
+		return err
+	}
+
+	_, err = tx.NewRaw(`INSERT INTO story_category (story_id, category_id)
+		SELECT ?, category_id FROM story_category WHERE story_id = ?
+		ON CONFLICT DO NOTHING`, keep.ID, drop.ID).Exec(ctx)
+	if err != nil {
+		return err
+	}
+	_, err = tx.NewDelete().Model((*models.StoryCategory)(nil)).Where("story_id = ?", drop.ID).Exec(ctx)
+	if err != nil {
+		return err
+	}
+
+	// other pairs of the dropped story are found again by the next scan if still relevant
+	_, err = tx.NewDelete().Model((*models.StoryDuplicate)(nil)).
+		Where("status = ?", models.DuplicatePending).
+		Where("(story_id = ? OR duplicate_id = ?)", drop.ID, drop.ID).
+		Exec(ctx)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.NewUpdate().Model((*models.StoryStatusChange)(nil)).
+		Set("story_id = ?", keep.ID).
+		Where("story_id = ?", drop.ID).
+		Exec(ctx)
+	if err != nil {
+		return err
+	}
+	change.StoryID = keep.ID
+	change.From = drop.Status
+	change.To = keep.Status
+	if _, err := tx.NewInsert().Model(change).Exec(ctx); err != nil {
+		return err
+	}
+
+	_, err = tx.NewDelete().Model((*models.Story)(nil)).Where("id = ?", drop.ID).Exec(ctx)
+	return err
+}
@@ -0,0 +1,32 @@
+package datastore
+
+import (
+	"context"
+	"demo-cosebase/internal/models"
+	"github.com/uptrace/bun"
+)
+
+func CreateTableFollow(ctx context.Context, db *bun.DB) error {
+	_, err := db.NewCreateTable().Model((*models.Follow)(nil)).IfNotExists().Exec(ctx)
+	if err != nil {
+		return err
+	}
+
+	_, err = db.NewCreateIndex().Model((*models.Follow)(nil)).IfNotExists().
+		Index("story_follow_story_id_idx").Column("story_id").Exec(ctx)
+	return err
+}
+
+// FindFollowedStorySources returns the pages on source of the stories followed by at least one user.
+func FindFollowedStorySources(ctx context.Context, db bun.IDB, source string) ([]*models.StorySource, error) {
+	var storySources []*models.StorySource
+	err := db.NewSelect().Model(&storySources).
+		Where("source = ?", source).
+		Where("EXISTS (SELECT 1 FROM story_follow AS f WHERE f.story_id = story_source.story_id)").
+		Order("id").
+		Scan(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return storySources, nil
+}
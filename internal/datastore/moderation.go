@@ -0,0 +1,184 @@
+package datastore
+
+import (
+	"context"
+	"demo-cosebase/internal/models"
+	"errors"
+	"github.com/uptrace/bun"
+)
+
+// ErrStoryStatusChanged is returned by ChangeStoryStatus when the story is no longer in
+// the status it was read with.
+var ErrStoryStatusChanged = errors.New("story status changed meanwhile")
+
+type StoryReportFilter struct {
+	Status string
+	Kind   string
+	Limit  int
+	Offset int
+}
+
+func CreateTableModeration(ctx context.Context, db *bun.DB) error {
+	_, err := db.NewCreateTable().Model((*models.StoryStatusChange)(nil)).IfNotExists().Exec(ctx)
+	if err != nil {
+		return err
+	}
+
+	_, err = db.NewCreateIndex().Model((*models.StoryStatusChange)(nil)).IfNotExists().
+		Index("story_status_change_story_id_idx").Column("story_id").Exec(ctx)
+	if err != nil {
+		return err
+	}
+
+	_, err = db.NewCreateTable().Model((*models.StoryReport)(nil)).IfNotExists().Exec(ctx)
+	if err != nil {
+		return err
+	}
+
+	_, err = db.NewCreateIndex().Model((*models.StoryReport)(nil)).IfNotExists().
+		Index("story_report_status_idx").Column("status", "id").Exec(ctx)
+	if err != nil {
+		return err
+	}
+
+	_, err = db.NewCreateIndex().Model((*models.StoryReport)(nil)).IfNotExists().
+		Index("story_report_story_id_idx").Column("story_id").Exec(ctx)
+	return err
+}
+
+// ChangeStoryStatus stores the new status of story and its audit record, change.From
+// and change.To are filled from the story. The transition is checked by the caller
+// against story.Status, so the update only applies while the story still has it and
+// returns ErrStoryStatusChanged otherwise.
+func ChangeStoryStatus(ctx context.Context, db bun.IDB, story *models.Story, to string, change *models.StoryStatusChange) error {
+	change.StoryID = story.ID
+	change.From = story.Status
+	change.To = to
+
+	res, err := db.NewUpdate().Model((*models.Story)(nil)).
+		Set("status = ?", to).
+		Set("update_at = ?", change.CreatedAt).
+		Where("id = ?", story.ID).
+		Where("status = ?", story.Status).
+		Exec(ctx)
+	if err != nil {
+		return err
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return ErrStoryStatusChanged
+	}
+	story.Status = to
+	story.UpdatedAt = change.CreatedAt
+
+	_, err = db.NewInsert().Model(change).Exec(ctx)
+	return err
+}
+
+// FindStoryStatusChanges returns the status history of a story, newest first.
+func FindStoryStatusChanges(ctx context.Context, db bun.IDB, storyID int64) ([]*models.StoryStatusChange, error) {
+	var changes []*models.StoryStatusChange
+	err := db.NewSelect().Model(&changes).
+		Where("story_id = ?", storyID).
+		Order("id DESC").
+		Scan(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return changes, nil
+}
+
+func CreateStoryReport(ctx context.Context, db bun.IDB, report *models.StoryReport) (*models.StoryReport, error) {
+	_, err := db.NewInsert().Model(report).Exec(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+// HasOpenStoryReport reports whether a user already has an open report on a story.
+func HasOpenStoryReport(ctx context.Context, db bun.IDB, storyID, reporterID int64) (bool, error) {
+	return db.NewSelect().Model((*models.StoryReport)(nil)).
+		Where("story_id = ?", storyID).
+		Where("reporter_id = ?", reporterID).
+		Where("status = ?", models.ReportOpen).
+		Exists(ctx)
+}
+
+// FindStoryReportForUpdate returns a report with its story and locks the report until the
+// transaction ends, so two moderators cannot both resolve it.
+func FindStoryReportForUpdate(ctx context.Context, db bun.IDB, ID int64) (*models.StoryReport, error) {
+	report := &models.StoryReport{}
+	err := db.NewSelect().Model(report).Relation("Story").
+		Where("story_report.id = ?", ID).
+		For("UPDATE OF story_report").
+		Scan(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+// FindStoryReports returns the moderation queue, DMCA notices first then oldest first so
+// nothing waits forever.
+func FindStoryReports(ctx context.Context, db bun.IDB, filter *StoryReportFilter) ([]*models.StoryReport, int, error) {
+	var reports []*models.StoryReport
+	q := db.NewSelect().Model(&reports).
+		Relation("Story", func(q *bun.SelectQuery) *bun.SelectQuery {
+			return q.Column("id", "slug", "tiltle", "author", "status")
+		}).
+		OrderExpr("story_report.kind = ? DESC, story_report.id", models.ReportDMCA).
+		Limit(filter.Limit).
+		Offset(filter.Offset)
+	if filter.Status != "" {
+		q = q.Where("story_report.status = ?", filter.Status)
+	}
+	if filter.Kind != "" {
+		q = q.Where("story_report.kind = ?", filter.Kind)
+	}
+
+	total, err := q.ScanAndCount(ctx)
+	if err != nil {
+		return nil, 0, err
+	}
+	return reports, total, nil
+}
+
+func UpdateStoryReport(ctx context.Context, db bun.IDB, report *models.StoryReport) (*models.StoryReport, error) {
+	_, err := db.NewUpdate().Model(report).WherePK().Exec(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+// ResolveOpenStoryReports closes the open reports of a story with the resolution of the
+// one a moderator acted on.
+func ResolveOpenStoryReports(ctx context.Context, db bun.IDB, resolved *models.StoryReport) (int, error) {
+	res, err := db.NewUpdate().Model((*models.StoryReport)(nil)).
+		Set("status = ?", resolved.Status).
+		Set("resolver_id = ?", resolved.ResolverID).
+		Set("resolution = ?", resolved.Resolution).
+		Set("note = ?", resolved.Note).
+		Set("resolved_at = ?", resolved.ResolvedAt).
+		Where("story_id = ?", resolved.StoryID).
+		Where("status = ?", models.ReportOpen).
+		Exec(ctx)
+	if err != nil {
+		return 0, err
+	}
+	affected, err := res.RowsAffected()
+	return int(affected), err
+}
+
+// mergeStoryReports moves the reports of the story dropID onto keepID, for MergeStories.
+func mergeStoryReports(ctx context.Context, tx bun.Tx, keepID, dropID int64) error {
+	_, err := tx.NewUpdate().Model((*models.StoryReport)(nil)).
+		Set("story_id = ?", keepID).
+		Where("story_id = ?", dropID).
+		Exec(ctx)
+	return err
+}
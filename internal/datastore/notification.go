@@ -0,0 +1,76 @@
+package datastore
+
+import (
+	"context"
+	"demo-cosebase/internal/models"
+	"github.com/uptrace/bun"
+)
+
+func CreateTableNotification(ctx context.Context, db *bun.DB) error {
+	_, err := db.NewCreateTable().Model((*models.Notification)(nil)).IfNotExists().Exec(ctx)
+	if err != nil {
+		return err
+	}
+
+	_, err = db.NewCreateIndex().Model((*models.Notification)(nil)).IfNotExists().
+		Index("notification_user_id_idx").Column("user_id", "id").Exec(ctx)
+	return err
+}
+
+// NotifyFollowers notifies every follower of the chapter's story that it was published
+// and returns how many were notified.
+func NotifyFollowers(ctx context.Context, db bun.IDB, chapter *models.Chapter, message string, now int64) (int, error) {
+	res, err := db.NewRaw(`INSERT INTO notification (user_id, kind, story_id, chapter_id, message, create_at)
+		SELECT user_id, ?, story_id, ?, ?, ? FROM story_follow WHERE story_id = ?`,
+		models.NotificationNewChapter, chapter.ID, message, now, chapter.StoryID).
+		Exec(ctx)
+	if err != nil {
+		return 0, err
+	}
+	affected, err := res.RowsAffected()
+	return int(affected), err
+}
+
+// FindNotifications returns the notifications of a user, newest first, and how many are
+// unread.
+func FindNotifications(ctx context.Context, db bun.IDB, userID int64, unreadOnly bool, limit, offset int) ([]*models.Notification, int, error) {
+	var notifications []*models.Notification
+	q := db.NewSelect().Model(&notifications).
+		Where("user_id = ?", userID).
+		Order("id DESC").
+		Limit(limit).
+		Offset(offset)
+	if unreadOnly {
+		q = q.Where("coalesce(read_at, 0) = 0")
+	}
+	if err := q.Scan(ctx); err != nil {
+		return nil, 0, err
+	}
+
+	unread, err := db.NewSelect().Model((*models.Notification)(nil)).
+		Where("user_id = ?", userID).
+		Where("coalesce(read_at, 0) = 0").
+		Count(ctx)
+	if err != nil {
+		return nil, 0, err
+	}
+	return notifications, unread, nil
+}
+
+// MarkNotificationsRead marks the given notifications of a user as read, all of them
+// when IDs is empty.
+func MarkNotificationsRead(ctx context.Context, db bun.IDB, userID int64, IDs []int64, now int64) (int, error) {
+	q := db.NewUpdate().Model((*models.Notification)(nil)).
+		Set("read_at = ?", now).
+		Where("user_id = ?", userID).
+		Where("coalesce(read_at, 0) = 0")
+	if len(IDs) > 0 {
+		q = q.Where("id IN (?)", bun.In(IDs))
+	}
+	res, err := q.Exec(ctx)
+	if err != nil {
+		return 0, err
+	}
+	affected, err := res.RowsAffected()
+	return int(affected), err
+}
@@ -0,0 +1,198 @@
+package datastore
+
+import (
+	"context"
+	"demo-cosebase/internal/models"
+	"github.com/uptrace/bun"
+)
+
+// Interaction sums up what a user did with a story: follow, reading and rating.
+type Interaction struct {
+	UserID   int64 `bun:"user_id"`
+	StoryID  int64 `bun:"story_id"`
+	Followed bool  `bun:"followed"`
+	Chapters int   `bun:"chapters"`
+	Score    int   `bun:"score"`
+}
+
+func CreateTableReading(ctx context.Context, db *bun.DB) error {
+	_, err := db.NewCreateTable().Model((*models.ReadingProgress)(nil)).IfNotExists().Exec(ctx)
+	if err != nil {
+		return err
+	}
+
+	_, err = db.NewCreateIndex().Model((*models.ReadingProgress)(nil)).IfNotExists().
+		Index("reading_progress_user_update_idx").Column("user_id", "update_at").Exec(ctx)
+	if err != nil {
+		return err
+	}
+
+	_, err = db.NewCreateTable().Model((*models.StoryRating)(nil)).IfNotExists().Exec(ctx)
+	if err != nil {
+		return err
+	}
+
+	_, err = db.NewCreateIndex().Model((*models.StoryRating)(nil)).IfNotExists().
+		Index("story_rating_story_id_idx").Column("story_id").Exec(ctx)
+	return err
+}
+
+func UpsertReadingProgress(ctx context.Context, db bun.IDB, progress *models.ReadingProgress) (*models.ReadingProgress, error) {
+	_, err := db.NewInsert().Model(progress).
+		On("CONFLICT (user_id, story_id) DO UPDATE").
+		Set("chapter_number = EXCLUDED.chapter_number").
+		Set("max_chapter = GREATEST(reading_progress.max_chapter, EXCLUDED.max_chapter)").
+		Set("update_at = EXCLUDED.update_at").
+		Returning("max_chapter").
+		Exec(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return progress, nil
+}
+
+// FindReadingHistory returns the stories a user read, last read first. Hidden stories
+// are left out.
+func FindReadingHistory(ctx context.Context, db bun.IDB, userID int64, limit, offset int) ([]*models.ReadingProgress, int, error) {
+	var history []*models.ReadingProgress
+	total, err := db.NewSelect().Model(&history).
+		Relation("Story", func(q *bun.SelectQuery) *bun.SelectQuery {
+			return q.Column("id", "slug", "tiltle", "author", "status", "cover_hash")
+		}).
+		Where("reading_progress.user_id = ?", userID).
+		Where("story.status IN (?)", bun.In(models.PublicStoryStatuses)).
+		Order("reading_progress.update_at DESC").
+		Limit(limit).
+		Offset(offset).
+		ScanAndCount(ctx)
+	if err != nil {
+		return nil, 0, err
+	}
+	return history, total, nil
+}
+
+func UpsertStoryRating(ctx context.Context, db bun.IDB, rating *models.StoryRating) (*models.StoryRating, error) {
+	_, err := db.NewInsert().Model(rating).
+		On("CONFLICT (user_id, story_id) DO UPDATE").
+		Set("score = EXCLUDED.score").
+		Set("update_at = EXCLUDED.update_at").
+		Returning("create_at").
+		Exec(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return rating, nil
+}
+
+func FindRatingSummary(ctx context.Context, db bun.IDB, storyID int64) (*models.RatingSummary, error) {
+	summary := &models.RatingSummary{}
+	err := db.NewSelect().Model((*models.StoryRating)(nil)).
+		ColumnExpr("coalesce(avg(score), 0) AS average").
+		ColumnExpr("count(*) AS count").
+		Where("story_id = ?", storyID).
+		Scan(ctx, summary)
+	if err != nil {
+		return nil, err
+	}
+	return summary, nil
+}
+
+// FindInteractions returns every user and story pair with a follow, a reading progress
+// or a rating.
+func FindInteractions(ctx context.Context, db bun.IDB) ([]*Interaction, error) {
+	var interactions []*Interaction
+	err := db.NewRaw(`SELECT user_id, story_id, bool_or(followed) AS followed, max(chapters) AS chapters, max(score) AS score
+		FROM (
+			SELECT user_id, story_id, true AS followed, 0 AS chapters, 0 AS score FROM story_follow
+			UNION ALL
+			SELECT user_id, story_id, false, max_chapter, 0 FROM reading_progress
+			UNION ALL
+			SELECT user_id, story_id, false, 0, score FROM story_rating
+		) AS i
+		GROUP BY user_id, story_id`).
+		Scan(ctx, &interactions)
+	if err != nil {
+		return nil, err
+	}
+	return interactions, nil
+}
+
+// FindPublicStories returns the stories visible to readers with what a recommendation
+// shows of them.
+func FindPublicStories(ctx context.Context, db bun.IDB) ([]*models.Story, error) {
+	var stories []*models.Story
+	err := db.NewSelect().Model(&stories).
+		Column("id", "slug", "tiltle", "author", "cover_hash").
+		Where("status IN (?)", bun.In(models.PublicStoryStatuses)).
+		Order("id").
+		Scan(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return stories, nil
+}
+
+// FindPublicStoryIDs returns which of IDs are stories visible to readers.
+func FindPublicStoryIDs(ctx context.Context, db bun.IDB, IDs []int64) (map[int64]bool, error) {
+	public := map[int64]bool{}
+	if len(IDs) == 0 {
+		return public, nil
+	}
+
+	var found []int64
+	err := db.NewSelect().Model((*models.Story)(nil)).
+		Column("id").
+		Where("id IN (?)", bun.In(IDs)).
+		Where("status IN (?)", bun.In(models.PublicStoryStatuses)).
+		Scan(ctx, &found)
+	if err != nil {
+		return nil, err
+	}
+	for _, ID := range found {
+		public[ID] = true
+	}
+	return public, nil
+}
+
+// FindStoryCategoryLinks returns every story and category pair.
+func FindStoryCategoryLinks(ctx context.Context, db bun.IDB) ([]*models.StoryCategory, error) {
+	var links []*models.StoryCategory
+	err := db.NewSelect().Model(&links).Scan(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return links, nil
+}
+
+// mergeReading moves the reading progress and ratings of the story dropID onto keepID, for
+// MergeStories. A user who read both keeps the most recent position and the furthest
+// chapter, and their latest rating.
+func mergeReading(ctx context.Context, tx bun.Tx, keepID, dropID int64) error {
+	_, err := tx.NewRaw(`INSERT INTO reading_progress (user_id, story_id, chapter_number, max_chapter, update_at)
+		SELECT user_id, ?, chapter_number, max_chapter, update_at FROM reading_progress WHERE story_id = ?
+		ON CONFLICT (user_id, story_id) DO UPDATE SET
+			chapter_number = CASE WHEN EXCLUDED.update_at > reading_progress.update_at
+				THEN EXCLUDED.chapter_number ELSE reading_progress.chapter_number END,
+			max_chapter = GREATEST(reading_progress.max_chapter, EXCLUDED.max_chapter),
+			update_at = GREATEST(reading_progress.update_at, EXCLUDED.update_at)`, keepID, dropID).Exec(ctx)
+	if err != nil {
+		return err
+	}
+	_, err = tx.NewDelete().Model((*models.ReadingProgress)(nil)).Where("story_id = ?", dropID).Exec(ctx)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.NewRaw(`INSERT INTO story_rating (user_id, story_id, score, create_at, update_at)
+		SELECT user_id, ?, score, create_at, update_at FROM story_rating WHERE story_id = ?
+		ON CONFLICT (user_id, story_id) DO UPDATE SET
+			score = EXCLUDED.score,
+			create_at = LEAST(story_rating.create_at, EXCLUDED.create_at),
+			update_at = EXCLUDED.update_at
+		WHERE EXCLUDED.update_at > story_rating.update_at`, keepID, dropID).Exec(ctx)
+	if err != nil {
+		return err
+	}
+	_, err = tx.NewDelete().Model((*models.StoryRating)(nil)).Where("story_id = ?", dropID).Exec(ctx)
+	return err
+}
@@ -0,0 +1,29 @@
+package redis_store
+
+import (
+	"context"
+	"errors"
+	"github.com/redis/go-redis/v9"
+	"strconv"
+	"time"
+)
+
+const dbKeyCrawlQueue = "crawl-queue"
+
+// PushCrawlRun queues a run for the crawl daemon. Both sides use the redis-db client of
+// the container, a run pushed to another instance is never popped.
+func PushCrawlRun(ctx context.Context, cmd redis.Cmdable, runID int64) error {
+	return cmd.RPush(ctx, dbKeyCrawlQueue, runID).Err()
+}
+
+// PopCrawlRun waits up to timeout for a queued run, it returns 0 when none came.
+func PopCrawlRun(ctx context.Context, cmd redis.Cmdable, timeout time.Duration) (int64, error) {
+	values, err := cmd.BLPop(ctx, timeout, dbKeyCrawlQueue).Result()
+	if errors.Is(err, redis.Nil) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseInt(values[1], 10, 64)
+}
@@ -0,0 +1,45 @@
+package redis_store
+
+import (
+	"context"
+	"fmt"
+	"github.com/redis/go-redis/v9"
+	"time"
+)
+
+// the token check keeps an instance whose lock expired from releasing or extending
+// the lock another instance acquired since
+var (
+	releaseLockScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0`)
+	extendLockScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("PEXPIRE", KEYS[1], ARGV[2])
+end
+return 0`)
+)
+
+func dbKeyLock(name string) string {
+	return fmt.Sprintf("lock:%s", name)
+}
+
+// AcquireLock returns false when the lock is held by another token.
+func AcquireLock(ctx context.Context, cmd redis.Cmdable, name, token string, ttl time.Duration) (bool, error) {
+	return cmd.SetNX(ctx, dbKeyLock(name), token, ttl).Result()
+}
+
+// ExtendLock returns false when the lock is no longer held by token.
+func ExtendLock(ctx context.Context, cmd redis.Scripter, name, token string, ttl time.Duration) (bool, error) {
+	n, err := extendLockScript.Run(ctx, cmd, []string{dbKeyLock(name)}, token, ttl.Milliseconds()).Int()
+	if err != nil {
+		return false, err
+	}
+	return n == 1, nil
+}
+
+func ReleaseLock(ctx context.Context, cmd redis.Scripter, name, token string) error {
+	return releaseLockScript.Run(ctx, cmd, []string{dbKeyLock(name)}, token).Err()
+}
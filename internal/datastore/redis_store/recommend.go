@@ -0,0 +1,107 @@
+package redis_store
+
+import (
+	"context"
+	"demo-cosebase/internal/models"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/redis/go-redis/v9"
+	"time"
+)
+
+// recommendationBatch is the number of lists written per pipeline.
+const recommendationBatch = 500
+
+const dbKeyPopularRecommendations = "recs:popular"
+
+func dbKeyUserRecommendations(userID int64) string {
+	return fmt.Sprintf("recs:user:%d", userID)
+}
+
+// the similar stories are keyed by slug, the page asking for them only knows the slug
+func dbKeySimilarStories(slug string) string {
+	return fmt.Sprintf("recs:story:%s", slug)
+}
+
+// RecommendationLists are the lists written by one build, keyed by user id or story
+// slug.
+type RecommendationLists struct {
+	Users   map[int64][]*models.Recommendation
+	Similar map[string][]*models.Recommendation
+	Popular []*models.Recommendation
+}
+
+// SaveRecommendations writes every list with ttl, in pipelines of recommendationBatch
+// commands. Lists missing from a build are left to expire.
+func SaveRecommendations(ctx context.Context, client redis.UniversalClient, lists *RecommendationLists, ttl time.Duration) error {
+	pipe := client.Pipeline()
+	queued := 0
+	set := func(key string, list []*models.Recommendation) error {
+		value, err := json.Marshal(list)
+		if err != nil {
+			return err
+		}
+		pipe.Set(ctx, key, value, ttl)
+		queued++
+		if queued < recommendationBatch {
+			return nil
+		}
+		queued = 0
+		_, err = pipe.Exec(ctx)
+		return err
+	}
+
+	for userID, list := range lists.Users {
+		if err := set(dbKeyUserRecommendations(userID), list); err != nil {
+			return err
+		}
+	}
+	for slug, list := range lists.Similar {
+		if err := set(dbKeySimilarStories(slug), list); err != nil {
+			return err
+		}
+	}
+	if err := set(dbKeyPopularRecommendations, lists.Popular); err != nil {
+		return err
+	}
+	if queued > 0 {
+		_, err := pipe.Exec(ctx)
+		return err
+	}
+	return nil
+}
+
+// FindUserRecommendations returns nil when the user has no list, e.g. without history.
+func FindUserRecommendations(ctx context.Context, cmd redis.Cmdable, userID int64) ([]*models.Recommendation, error) {
+	return findRecommendations(ctx, cmd, dbKeyUserRecommendations(userID))
+}
+
+func FindSimilarStories(ctx context.Context, cmd redis.Cmdable, slug string) ([]*models.Recommendation, error) {
+	return findRecommendations(ctx, cmd, dbKeySimilarStories(slug))
+}
+
+func FindPopularRecommendations(ctx context.Context, cmd redis.Cmdable) ([]*models.Recommendation, error) {
+	return findRecommendations(ctx, cmd, dbKeyPopularRecommendations)
+}
+
+// DeleteSimilarStories drops the list of a story, when it is hidden.
+func DeleteSimilarStories(ctx context.Context, cmd redis.Cmdable, slug string) error {
+	return cmd.Del(ctx, dbKeySimilarStories(slug)).Err()
+}
+
+func findRecommendations(ctx context.Context, cmd redis.Cmdable, key string) ([]*models.Recommendation, error) {
+	value, err := cmd.Get(ctx, key).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var list []*models.Recommendation
+	if err := json.Unmarshal(value, &list); err != nil {
+		return nil, err
+	}
+	return list, nil
+}
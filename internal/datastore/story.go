@@ -0,0 +1,198 @@
+package datastore
+
+import (
+	"context"
+	"demo-cosebase/internal/models"
+	"github.com/uptrace/bun"
+)
+
+func CreateTableStory(ctx context.Context, db *bun.DB) error {
+	_, err := db.NewCreateTable().Model((*models.Story)(nil)).IfNotExists().Exec(ctx)
+	if err != nil {
+		return err
+	}
+
+	// the table predates mirrored covers
+	_, err = db.ExecContext(ctx, `ALTER TABLE story ADD COLUMN IF NOT EXISTS cover_hash VARCHAR, ADD COLUMN IF NOT EXISTS cover_url VARCHAR`)
+	if err != nil {
+		return err
+	}
+
+	// the status used to be the text of the crawled page
+	_, err = db.NewUpdate().Model((*models.Story)(nil)).
+		Set(`status = CASE
+			WHEN lower(status) LIKE '%hoàn%' OR lower(status) = 'full' THEN ?
+			WHEN lower(status) LIKE '%tạm dừng%' THEN ?
+			ELSE ? END`, models.StoryCompleted, models.StoryPaused, models.StoryOngoing).
+		Where("status IS NULL OR status NOT IN (?)", bun.In([]string{
+			models.StoryDraft, models.StoryOngoing, models.StoryCompleted, models.StoryPaused,
+			models.StoryDropped, models.StoryHidden, models.StoryTakenDown,
+		})).
+		Exec(ctx)
+	if err != nil {
+		return err
+	}
+	_, err = db.ExecContext(ctx, `ALTER TABLE story ALTER COLUMN status SET DEFAULT 'ongoing', ALTER COLUMN status SET NOT NULL`)
+	if err != nil {
+		return err
+	}
+
+	_, err = db.NewCreateIndex().Model((*models.Story)(nil)).IfNotExists().
+		Index("story_status_idx").Column("status").Exec(ctx)
+	return err
+}
+
+func CreateTableStorySource(ctx context.Context, db *bun.DB) error {
+	_, err := db.NewCreateTable().Model((*models.StorySource)(nil)).IfNotExists().Exec(ctx)
+	if err != nil {
+		return err
+	}
+
+	_, err = db.NewCreateIndex().Model((*models.StorySource)(nil)).IfNotExists().
+		Index("story_source_story_id_idx").Column("story_id").Exec(ctx)
+	return err
+}
+
+func CreateTableCategory(ctx context.Context, db *bun.DB) error {
+	_, err := db.NewCreateTable().Model((*models.Category)(nil)).IfNotExists().Exec(ctx)
+	if err != nil {
+		return err
+	}
+
+	_, err = db.NewCreateTable().Model((*models.StoryCategory)(nil)).IfNotExists().Exec(ctx)
+	return err
+}
+
+func FindStoryByID(ctx context.Context, db bun.IDB, ID int64) (*models.Story, error) {
+	story := &models.Story{}
+	err := db.NewSelect().Model(story).Relation("Sources").Where("story.id = ?", ID).Scan(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return story, nil
+}
+
+func FindStoryBySlug(ctx context.Context, db bun.IDB, slug string) (*models.Story, error) {
+	story := &models.Story{}
+	err := db.NewSelect().Model(story).Relation("Sources").Where("story.slug = ?", slug).Scan(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return story, nil
+}
+
+func FindStorySource(ctx context.Context, db bun.IDB, source, sourceID string) (*models.StorySource, error) {
+	storySource := &models.StorySource{}
+	err := db.NewSelect().Model(storySource).
+		Where("source = ?", source).
+		Where("source_id = ?", sourceID).
+		Scan(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return storySource, nil
+}
+
+func FindStorySources(ctx context.Context, db bun.IDB, source string) ([]*models.StorySource, error) {
+	var storySources []*models.StorySource
+	err := db.NewSelect().Model(&storySources).Where("source = ?", source).Order("id").Scan(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return storySources, nil
+}
+
+func CreateStory(ctx context.Context, db bun.IDB, story *models.Story) (*models.Story, error) {
+	_, err := db.NewInsert().Model(story).Exec(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return story, nil
+}
+
+// UpdateStory saves every field but the status, which only changes through
+// ChangeStoryStatus so it always has an audit record.
+func UpdateStory(ctx context.Context, db bun.IDB, story *models.Story) (*models.Story, error) {
+	_, err := db.NewUpdate().Model(story).ExcludeColumn("status").WherePK().Exec(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return story, nil
+}
+
+func UpdateStoryCover(ctx context.Context, db bun.IDB, story *models.Story) error {
+	_, err := db.NewUpdate().Model(story).Column("cover_hash", "cover_url").WherePK().Exec(ctx)
+	return err
+}
+
+func UpsertStorySource(ctx context.Context, db bun.IDB, storySource *models.StorySource) (*models.StorySource, error) {
+	_, err := db.NewInsert().Model(storySource).
+		On("CONFLICT (source, source_id) DO UPDATE").
+		Set("slug = EXCLUDED.slug").
+		Set("url = EXCLUDED.url").
+		Set("crawled_at = EXCLUDED.crawled_at").
+		Returning("*").
+		Exec(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return storySource, nil
+}
+
+func UpsertCategory(ctx context.Context, db bun.IDB, category *models.Category) (*models.Category, error) {
+	_, err := db.NewInsert().Model(category).
+		On("CONFLICT (slug) DO UPDATE").
+		Set("name = EXCLUDED.name").
+		Returning("*").
+		Exec(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return category, nil
+}
+
+func LinkStoryCategories(ctx context.Context, db bun.IDB, storyID int64, categoryIDs []int64) error {
+	if len(categoryIDs) == 0 {
+		return nil
+	}
+
+	links := make([]*models.StoryCategory, 0, len(categoryIDs))
+	for _, categoryID := range categoryIDs {
+		links = append(links, &models.StoryCategory{StoryID: storyID, CategoryID: categoryID})
+	}
+	_, err := db.NewInsert().Model(&links).On("CONFLICT DO NOTHING").Exec(ctx)
+	return err
+}
+
+func FindStoryCategories(ctx context.Context, db bun.IDB, storyID int64) ([]*models.Category, error) {
+	var categories []*models.Category
+	err := db.NewSelect().Model(&categories).
+		Where("EXISTS (SELECT 1 FROM story_category AS sc WHERE sc.category_id = category.id AND sc.story_id = ?)", storyID).
+		Order("slug").
+		Scan(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return categories, nil
+}
+
+func FindCategoriesBySlugs(ctx context.Context, db bun.IDB, slugs []string) ([]*models.Category, error) {
+	var categories []*models.Category
+	if len(slugs) == 0 {
+		return categories, nil
+	}
+	err := db.NewSelect().Model(&categories).Where("slug IN (?)", bun.In(slugs)).Scan(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return categories, nil
+}
+
+func FindCategoryBySlug(ctx context.Context, db bun.IDB, slug string) (*models.Category, error) {
+	category := &models.Category{}
+	err := db.NewSelect().Model(category).Where("slug = ?", slug).Scan(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return category, nil
+}
@@ -12,7 +12,21 @@ func CreateTableUser(ctx context.Context, db *bun.DB) error {
 		return err
 	}
 
-	return nil
+	// the table predates these columns
+	_, err = db.ExecContext(ctx, `ALTER TABLE "user" ADD COLUMN IF NOT EXISTS role VARCHAR NOT NULL DEFAULT 'user'`)
+	if err != nil {
+		return err
+	}
+	_, err = db.ExecContext(ctx, `ALTER TABLE "user" ADD COLUMN IF NOT EXISTS feed_token VARCHAR`)
+	if err != nil {
+		return err
+	}
+	_, err = db.ExecContext(ctx, `CREATE UNIQUE INDEX IF NOT EXISTS user_feed_token_idx ON "user" (feed_token)`)
+	if err != nil {
+		return err
+	}
+	_, err = db.ExecContext(ctx, `ALTER TABLE "user" ADD COLUMN IF NOT EXISTS settings JSONB`)
+	return err
 }
 
 func FindUserByUsername(ctx context.Context, db *bun.DB, username string) (*models.User, error) {
@@ -67,3 +81,33 @@ func UpdateUser(ctx context.Context, db *bun.DB, user *models.User) (*models.Use
 	}
 	return user, nil
 }
+
+func FindUserByFeedToken(ctx context.Context, db *bun.DB, tokenHash string) (*models.User, error) {
+	user := &models.User{}
+	err := db.NewSelect().Model(user).Where("feed_token = ?", tokenHash).Scan(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+func UpdateUserFeedToken(ctx context.Context, db *bun.DB, user *models.User) error {
+	_, err := db.NewUpdate().Model(user).Column("feed_token").WherePK().Exec(ctx)
+	return err
+}
+
+// FindUserSettingsForUpdate returns the settings of a user, nil when never saved, and
+// locks the user until the end of the transaction.
+func FindUserSettingsForUpdate(ctx context.Context, tx bun.Tx, userID int64) (*models.UserSettings, error) {
+	user := &models.User{}
+	err := tx.NewSelect().Model(user).Column("settings").Where("id = ?", userID).For("UPDATE").Scan(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return user.Settings, nil
+}
+
+func UpdateUserSettings(ctx context.Context, db bun.IDB, user *models.User) error {
+	_, err := db.NewUpdate().Model(user).Column("settings").WherePK().Exec(ctx)
+	return err
+}
@@ -0,0 +1,303 @@
+// Package dedupe finds stories crawled more than once, from different sources or under
+// different transliterations of the same title.
+package dedupe
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/mozillazg/go-unidecode"
+	"golang.org/x/text/unicode/norm"
+)
+
+const (
+	// DefaultThreshold is the score from which a pair is worth a review.
+	DefaultThreshold = 0.6
+
+	weightOriginalTitle = 0.4
+	weightTitle         = 0.3
+	weightAuthor        = 0.2
+	weightChapters      = 0.1
+
+	// maxBlockSize skips title words so common that pairing every story sharing them
+	// would be quadratic, e.g. "chi" or "thien".
+	maxBlockSize = 50
+)
+
+// Story is what the matcher knows about a story.
+type Story struct {
+	ID            int64
+	Title         string
+	OriginalTitle string
+	Author        string
+	Chapters      int
+}
+
+type Match struct {
+	StoryID     int64
+	DuplicateID int64
+	Score       float64
+	Reasons     []string
+}
+
+// Compare scores how likely a and b are the same novel, from 0 to 1. Signals missing on
+// either side do not count, their weight is spread over the others.
+func Compare(a, b *Story) Match {
+	match := Match{StoryID: a.ID, DuplicateID: b.ID}
+	if match.StoryID > match.DuplicateID {
+		match.StoryID, match.DuplicateID = match.DuplicateID, match.StoryID
+	}
+
+	var total, weights float64
+	add := func(weight, score float64, reason string) {
+		total += weight * score
+		weights += weight
+		if reason != "" {
+			match.Reasons = append(match.Reasons, reason)
+		}
+	}
+
+	originalsA, originalsB := variants(a.OriginalTitle, compact), variants(b.OriginalTitle, compact)
+	if len(originalsA) > 0 && len(originalsB) > 0 {
+		score := bestSimilarity(originalsA, originalsB, bigramDice)
+		reason := ""
+		if score == 1 {
+			reason = "same original title"
+		} else if score >= 0.5 {
+			reason = fmt.Sprintf("original title %.2f", score)
+		}
+		add(weightOriginalTitle, score, reason)
+	}
+
+	// a story is sometimes listed under its Hán-Việt reading on one site and its
+	// translated title on another, any variant matching is enough
+	titlesA, titlesB := variants(a.Title, normalize), variants(b.Title, normalize)
+	if len(titlesA) > 0 && len(titlesB) > 0 {
+		score := bestSimilarity(titlesA, titlesB, tokenDice)
+		reason := ""
+		if score == 1 {
+			reason = "same title"
+		} else if score >= 0.5 {
+			reason = fmt.Sprintf("title %.2f", score)
+		}
+		add(weightTitle, score, reason)
+	}
+
+	authorA, authorB := normalize(a.Author), normalize(b.Author)
+	if authorA != "" && authorB != "" {
+		score := tokenDice(authorA, authorB)
+		reason := ""
+		if score == 1 {
+			reason = "same author"
+		} else if score >= 0.5 {
+			reason = fmt.Sprintf("author %.2f", score)
+		}
+		add(weightAuthor, score, reason)
+	}
+
+	if a.Chapters > 0 && b.Chapters > 0 {
+		lo, hi := a.Chapters, b.Chapters
+		if lo > hi {
+			lo, hi = hi, lo
+		}
+		score := float64(lo) / float64(hi)
+		reason := ""
+		if score >= 0.8 {
+			reason = fmt.Sprintf("chapters %d/%d", a.Chapters, b.Chapters)
+		}
+		add(weightChapters, score, reason)
+	}
+
+	if weights > 0 {
+		match.Score = total / weights
+	}
+	return match
+}
+
+// Find compares the stories sharing an original title, an author or a title word and
+// returns the pairs scoring at least threshold, best first.
+func Find(stories []*Story, threshold float64) []Match {
+	blocks := map[string][]int{}
+	for i, story := range stories {
+		keys := map[string]bool{}
+		for _, original := range variants(story.OriginalTitle, compact) {
+			keys["o:"+original] = true
+		}
+		if author := normalize(story.Author); author != "" {
+			keys["a:"+author] = true
+		}
+		for _, title := range variants(story.Title, normalize) {
+			for _, word := range strings.Fields(title) {
+				keys["w:"+word] = true
+			}
+		}
+		for key := range keys {
+			blocks[key] = append(blocks[key], i)
+		}
+	}
+
+	seen := map[[2]int]bool{}
+	var matches []Match
+	for key, block := range blocks {
+		if strings.HasPrefix(key, "w:") && len(block) > maxBlockSize {
+			continue
+		}
+		for x := 0; x < len(block); x++ {
+			for y := x + 1; y < len(block); y++ {
+				pair := [2]int{block[x], block[y]}
+				if seen[pair] {
+					continue
+				}
+				seen[pair] = true
+
+				match := Compare(stories[pair[0]], stories[pair[1]])
+				if match.Score >= threshold {
+					matches = append(matches, match)
+				}
+			}
+		}
+	}
+
+	sort.Slice(matches, func(i, j int) bool {
+		if matches[i].Score != matches[j].Score {
+			return matches[i].Score > matches[j].Score
+		}
+		if matches[i].StoryID != matches[j].StoryID {
+			return matches[i].StoryID < matches[j].StoryID
+		}
+		return matches[i].DuplicateID < matches[j].DuplicateID
+	})
+	return matches
+}
+
+// variants splits "Ta Có Một Tòa Nhà Ma (Ngã Hữu Nhất Tọa Khủng Bố Ốc)" or "Chư Giới Mạt
+// Nhật Tại Tuyến - Chư Giới Tận Thế Online" into the main title and its alternatives,
+// normalized by fn.
+func variants(title string, fn func(string) string) []string {
+	var parts []string
+	var current strings.Builder
+	depth := 0
+	flush := func() {
+		if part := fn(current.String()); part != "" {
+			parts = append(parts, part)
+		}
+		current.Reset()
+	}
+	for _, r := range strings.ReplaceAll(title, " - ", "|") {
+		switch r {
+		case '(', '（', '[', '【':
+			if depth == 0 {
+				flush()
+			}
+			depth++
+		case '|':
+			if depth == 0 {
+				flush()
+			} else {
+				current.WriteRune(' ')
+			}
+		case ')', '）', ']', '】':
+			if depth > 0 {
+				depth--
+			}
+			if depth == 0 {
+				flush()
+			}
+		default:
+			current.WriteRune(r)
+		}
+	}
+	flush()
+
+	var result []string
+	for _, part := range parts {
+		if !noise[part] {
+			result = append(result, part)
+		}
+	}
+	return result
+}
+
+// noise are the bracketed notes sites add to titles.
+var noise = map[string]bool{
+	"re convert": true, "reconvert": true, "convert": true, "dich": true, "full": true, "hoan thanh": true,
+}
+
+// normalize lowercases, strips accents and keeps words of letters and digits.
+func normalize(s string) string {
+	s = strings.ToLower(unidecode.Unidecode(norm.NFC.String(s)))
+	return strings.Join(strings.FieldsFunc(s, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}), " ")
+}
+
+// compact keeps the letters and digits of an original title, Chinese titles have no spaces.
+func compact(s string) string {
+	var b strings.Builder
+	for _, r := range norm.NFKC.String(s) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			b.WriteRune(unicode.ToLower(r))
+		}
+	}
+	return b.String()
+}
+
+func bestSimilarity(as, bs []string, similarity func(a, b string) float64) float64 {
+	best := 0.0
+	for _, a := range as {
+		for _, b := range bs {
+			if score := similarity(a, b); score > best {
+				best = score
+			}
+		}
+	}
+	return best
+}
+
+// tokenDice is the Dice coefficient of the words of a and b.
+func tokenDice(a, b string) float64 {
+	if a == b {
+		return 1
+	}
+	return dice(strings.Fields(a), strings.Fields(b))
+}
+
+// bigramDice is the Dice coefficient of the rune bigrams of a and b.
+func bigramDice(a, b string) float64 {
+	if a == b {
+		return 1
+	}
+	return dice(bigrams(a), bigrams(b))
+}
+
+func bigrams(s string) []string {
+	runes := []rune(s)
+	if len(runes) < 2 {
+		return []string{s}
+	}
+	grams := make([]string, 0, len(runes)-1)
+	for i := 0; i+1 < len(runes); i++ {
+		grams = append(grams, string(runes[i:i+2]))
+	}
+	return grams
+}
+
+func dice(as, bs []string) float64 {
+	if len(as) == 0 || len(bs) == 0 {
+		return 0
+	}
+	counts := map[string]int{}
+	for _, a := range as {
+		counts[a]++
+	}
+	common := 0
+	for _, b := range bs {
+		if counts[b] > 0 {
+			counts[b]--
+			common++
+		}
+	}
+	return 2 * float64(common) / float64(len(as)+len(bs))
+}
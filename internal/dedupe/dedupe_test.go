@@ -0,0 +1,100 @@
+package dedupe_test
+
+import (
+	"testing"
+
+	"demo-cosebase/internal/dedupe"
+)
+
+func TestCompare(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b *dedupe.Story
+		// the score is at least min and under max
+		min, max float64
+	}{
+		{
+			name: "accents and case",
+			a:    &dedupe.Story{ID: 1, Title: "Đấu Phá Thương Khung", Author: "Thiên Tằm Thổ Đậu"},
+			b:    &dedupe.Story{ID: 2, Title: "dau pha thuong khung", Author: "THIEN TAM THO DAU"},
+			min:  1, max: 1.01,
+		},
+		{
+			name: "site notes in brackets",
+			a:    &dedupe.Story{ID: 1, Title: "Đấu Phá Thương Khung [Dịch]", Author: "Thiên Tằm Thổ Đậu"},
+			b:    &dedupe.Story{ID: 2, Title: "Đấu Phá Thương Khung (Full)", Author: "Thiên Tằm Thổ Đậu"},
+			min:  1, max: 1.01,
+		},
+		{
+			name: "translated and Hán-Việt titles",
+			a:    &dedupe.Story{ID: 1, Title: "Ta Có Một Tòa Nhà Ma (Ngã Hữu Nhất Tọa Khủng Bố Ốc)", Author: "Thạch Chương Ngư"},
+			b:    &dedupe.Story{ID: 2, Title: "Ngã Hữu Nhất Tọa Khủng Bố Ốc", Author: "Thạch Chương Ngư"},
+			min:  1, max: 1.01,
+		},
+		{
+			name: "alternative title after a dash",
+			a:    &dedupe.Story{ID: 1, Title: "Chư Giới Mạt Nhật Tại Tuyến - Chư Giới Tận Thế Online"},
+			b:    &dedupe.Story{ID: 2, Title: "Chư Giới Tận Thế Online"},
+			min:  1, max: 1.01,
+		},
+		{
+			name: "original title spacing",
+			a:    &dedupe.Story{ID: 1, Title: "Luân Hồi Lạc Viên", OriginalTitle: "轮回 乐园"},
+			b:    &dedupe.Story{ID: 2, Title: "Luân Hồi Nhạc Viên", OriginalTitle: "轮回乐园"},
+			min:  dedupe.DefaultThreshold, max: 1,
+		},
+		{
+			name: "same author, other novel",
+			a:    &dedupe.Story{ID: 1, Title: "Đấu Phá Thương Khung", Author: "Thiên Tằm Thổ Đậu"},
+			b:    &dedupe.Story{ID: 2, Title: "Vũ Động Càn Khôn", Author: "Thiên Tằm Thổ Đậu"},
+			min:  0, max: dedupe.DefaultThreshold,
+		},
+		{
+			name: "unrelated",
+			a:    &dedupe.Story{ID: 1, Title: "Luân Hồi Lạc Viên", Author: "Na Nhất Chích Văn Tử", Chapters: 1600},
+			b:    &dedupe.Story{ID: 2, Title: "Đấu Phá Thương Khung", Author: "Thiên Tằm Thổ Đậu", Chapters: 1648},
+			min:  0, max: dedupe.DefaultThreshold,
+		},
+		{
+			name: "nothing to compare",
+			a:    &dedupe.Story{ID: 1},
+			b:    &dedupe.Story{ID: 2},
+			min:  0, max: 0.01,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			match := dedupe.Compare(test.a, test.b)
+			if match.Score < test.min || match.Score >= test.max {
+				t.Fatalf("score %.3f (%v), want [%.2f, %.2f)", match.Score, match.Reasons, test.min, test.max)
+			}
+			// the order of the arguments does not matter
+			if reversed := dedupe.Compare(test.b, test.a); reversed.Score != match.Score ||
+				reversed.StoryID != 1 || reversed.DuplicateID != 2 {
+				t.Fatalf("reversed: got %+v, want %+v", reversed, match)
+			}
+		})
+	}
+}
+
+func TestFind(t *testing.T) {
+	stories := []*dedupe.Story{
+		{ID: 1, Title: "Đấu Phá Thương Khung", Author: "Thiên Tằm Thổ Đậu", Chapters: 1648},
+		{ID: 2, Title: "Luân Hồi Lạc Viên", Author: "Na Nhất Chích Văn Tử"},
+		{ID: 3, Title: "Dau Pha Thuong Khung [Convert]", Author: "Thiên Tằm Thổ Đậu", Chapters: 1640},
+		{ID: 4, Title: "Vũ Động Càn Khôn", Author: "Thiên Tằm Thổ Đậu"},
+		{ID: 5, Title: "Luân Hồi Lạc Viên", Author: "Na Nhất Chích Văn Tử"},
+	}
+
+	matches := dedupe.Find(stories, dedupe.DefaultThreshold)
+	if len(matches) != 2 {
+		t.Fatalf("got %+v, want the pairs 2/5 and 1/3", matches)
+	}
+	// best first, then by id
+	if matches[0].StoryID != 2 || matches[0].DuplicateID != 5 || matches[1].StoryID != 1 || matches[1].DuplicateID != 3 {
+		t.Fatalf("got %+v, want 2/5 then 1/3", matches)
+	}
+	if matches[0].Score < matches[1].Score {
+		t.Fatalf("got %+v, want the best score first", matches)
+	}
+}
@@ -0,0 +1,56 @@
+package dedupe
+
+import (
+	"context"
+	"demo-cosebase/internal/datastore"
+	"demo-cosebase/internal/models"
+	"github.com/uptrace/bun"
+	"time"
+)
+
+// Scan compares every story and queues the pairs scoring at least threshold for review.
+// It returns the number of pairs found, reviewed pairs are not queued again.
+func Scan(ctx context.Context, db bun.IDB, threshold float64) (int, error) {
+	summaries, err := datastore.FindStorySummaries(ctx, db)
+	if err != nil {
+		return 0, err
+	}
+	chapters, err := datastore.CountStoryChapters(ctx, db)
+	if err != nil {
+		return 0, err
+	}
+
+	stories := make([]*Story, 0, len(summaries))
+	for _, summary := range summaries {
+		stories = append(stories, &Story{
+			ID:            summary.ID,
+			Title:         summary.Tittle,
+			OriginalTitle: summary.OriginalTitle,
+			Author:        summary.Author,
+			Chapters:      chapters[summary.ID],
+		})
+	}
+
+	matches := Find(stories, threshold)
+	now := time.Now().Unix()
+	duplicates := make([]*models.StoryDuplicate, 0, len(matches))
+	for _, match := range matches {
+		duplicates = append(duplicates, &models.StoryDuplicate{
+			StoryID:     match.StoryID,
+			DuplicateID: match.DuplicateID,
+			Score:       match.Score,
+			Reasons:     match.Reasons,
+			Status:      models.DuplicatePending,
+			CreatedAt:   now,
+		})
+	}
+
+	// one insert per batch keeps the statement under the parameter limit
+	for start := 0; start < len(duplicates); start += 500 {
+		end := min(start+500, len(duplicates))
+		if err := datastore.UpsertStoryDuplicates(ctx, db, duplicates[start:end]); err != nil {
+			return 0, err
+		}
+	}
+	return len(duplicates), nil
+}
@@ -0,0 +1,89 @@
+// Package export writes stories to files readers and editors can use outside the site,
+// and imports stories translators prepared in the Markdown layout.
+package export
+
+import (
+	"context"
+	"demo-cosebase/internal/datastore"
+	"demo-cosebase/internal/media"
+	"demo-cosebase/internal/models"
+	"demo-cosebase/pkg/epub"
+	"demo-cosebase/pkg/storage"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/uptrace/bun"
+)
+
+// chapterBatch is the number of chapters read at once, a book is never held in memory.
+const chapterBatch = 50
+
+var ErrNoChapters = errors.New("export: no chapters in range")
+
+// Range selects chapters by number, a zero bound is open.
+type Range struct {
+	From int
+	To   int
+}
+
+// EPUB writes story as an EPUB 3 book to w, with the chapters of r and the large
+// mirrored cover when there is one. ErrNoChapters is returned before anything is
+// written, so callers can still answer with an error.
+func EPUB(ctx context.Context, w io.Writer, db bun.IDB, covers *media.Covers, story *models.Story, r Range) error {
+	chapters, err := datastore.FindChapterRange(ctx, db, story.ID, r.From, r.To, 0, chapterBatch)
+	if err != nil {
+		return err
+	}
+	if len(chapters) == 0 {
+		return ErrNoChapters
+	}
+
+	book, err := epub.NewWriter(w, epub.Metadata{
+		Identifier:  fmt.Sprintf("urn:story:%d", story.ID),
+		Title:       story.Tittle,
+		Author:      story.Author,
+		Description: story.Description,
+		Modified:    time.Unix(story.UpdatedAt, 0),
+	})
+	if err != nil {
+		return err
+	}
+
+	if story.CoverHash != "" && covers != nil {
+		reader, _, err := covers.Open(ctx, story.CoverHash, "large")
+		if err != nil && !errors.Is(err, storage.ErrNotFound) {
+			return err
+		}
+		if err == nil {
+			err = book.SetCover(reader, "image/jpeg")
+			reader.Close()
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	for len(chapters) > 0 {
+		for _, chapter := range chapters {
+			title := chapter.Title
+			if title == "" {
+				title = fmt.Sprintf("Chương %d", chapter.Number)
+			}
+			if err := book.AddChapter(chapter.Volume, title, chapter.Content); err != nil {
+				return err
+			}
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		last := chapters[len(chapters)-1].Number
+		chapters, err = datastore.FindChapterRange(ctx, db, story.ID, r.From, r.To, last, chapterBatch)
+		if err != nil {
+			return err
+		}
+	}
+	return book.Close()
+}
@@ -0,0 +1,400 @@
+package export
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"demo-cosebase/internal/datastore"
+	"demo-cosebase/internal/models"
+	"demo-cosebase/pkg"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/uptrace/bun"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	// StoryFile holds the story metadata as front matter and the description as body.
+	StoryFile = "story.md"
+	// ChaptersDir holds one file per chapter, named after its number.
+	ChaptersDir = "chapters"
+
+	// DefaultImportSource is the chapter source of imported stories without one.
+	DefaultImportSource = "import"
+)
+
+// StoryMeta is the front matter of StoryFile.
+type StoryMeta struct {
+	Slug          string   `yaml:"slug"`
+	Title         string   `yaml:"title"`
+	OriginalTitle string   `yaml:"original_title,omitempty"`
+	Author        string   `yaml:"author,omitempty"`
+	Status        string   `yaml:"status,omitempty"`
+	Categories    []string `yaml:"categories,omitempty"`
+	// Source names the chapters of the import, translators use their team name.
+	Source     string `yaml:"source,omitempty"`
+	Translator string `yaml:"translator,omitempty"`
+}
+
+// ChapterMeta is the front matter of a chapter file.
+type ChapterMeta struct {
+	Number int    `yaml:"number"`
+	Volume string `yaml:"volume,omitempty"`
+	Title  string `yaml:"title,omitempty"`
+}
+
+type MarkdownChapter struct {
+	ChapterMeta
+	File    string
+	Content string
+}
+
+// MarkdownStory is a story read from a directory written by Markdown.
+type MarkdownStory struct {
+	StoryMeta
+	Description string
+	Chapters    []*MarkdownChapter
+}
+
+// Markdown writes story into dir, StoryFile and a file per chapter of r.
+func Markdown(ctx context.Context, dir string, db bun.IDB, story *models.Story, r Range) (int, error) {
+	if err := os.MkdirAll(filepath.Join(dir, ChaptersDir), 0755); err != nil {
+		return 0, err
+	}
+
+	categories, err := datastore.FindStoryCategories(ctx, db, story.ID)
+	if err != nil {
+		return 0, err
+	}
+	meta := StoryMeta{
+		Slug:          story.Slug,
+		Title:         story.Tittle,
+		OriginalTitle: story.OriginalTitle,
+		Author:        story.Author,
+		Status:        story.Status,
+	}
+	for _, category := range categories {
+		meta.Categories = append(meta.Categories, category.Slug)
+	}
+	if err := writeMarkdown(filepath.Join(dir, StoryFile), meta, story.Description); err != nil {
+		return 0, err
+	}
+
+	written := 0
+	for after := 0; ; {
+		chapters, err := datastore.FindChapterRange(ctx, db, story.ID, r.From, r.To, after, chapterBatch)
+		if err != nil {
+			return written, err
+		}
+		if len(chapters) == 0 {
+			return written, nil
+		}
+
+		for _, chapter := range chapters {
+			meta := ChapterMeta{Number: chapter.Number, Volume: chapter.Volume, Title: chapter.Title}
+			if err := writeMarkdown(filepath.Join(dir, ChaptersDir, chapterFile(chapter.Number)), meta, chapter.Content); err != nil {
+				return written, err
+			}
+			written++
+		}
+		after = chapters[len(chapters)-1].Number
+	}
+}
+
+func chapterFile(number int) string {
+	return fmt.Sprintf("%05d.md", number)
+}
+
+func writeMarkdown(name string, meta any, body string) error {
+	front, err := yaml.Marshal(meta)
+	if err != nil {
+		return err
+	}
+
+	var b bytes.Buffer
+	b.WriteString("---\n")
+	b.Write(front)
+	b.WriteString("---\n\n")
+	b.WriteString(strings.TrimSpace(body))
+	b.WriteString("\n")
+	return os.WriteFile(name, b.Bytes(), 0644)
+}
+
+// ValidationError lists every problem found in an import directory.
+type ValidationError struct {
+	Problems []string
+}
+
+func (e *ValidationError) Error() string {
+	return "export: invalid import:\n  " + strings.Join(e.Problems, "\n  ")
+}
+
+// ReadMarkdown reads and validates a directory written by Markdown or by hand. Chapter
+// numbers must be positive, unique, match their file name and, unless allowGaps, follow
+// each other without holes.
+func ReadMarkdown(dir string, allowGaps bool) (*MarkdownStory, error) {
+	story := &MarkdownStory{}
+	if err := readMarkdown(filepath.Join(dir, StoryFile), &story.StoryMeta, &story.Description); err != nil {
+		return nil, err
+	}
+
+	var problems []string
+	if story.Slug == "" {
+		problems = append(problems, StoryFile+": missing slug")
+	}
+	if story.Title == "" {
+		problems = append(problems, StoryFile+": missing title")
+	}
+	if story.Status != "" && !models.IsStoryStatus(story.Status) {
+		problems = append(problems, fmt.Sprintf("%s: unknown status %q", StoryFile, story.Status))
+	}
+
+	names, err := filepath.Glob(filepath.Join(dir, ChaptersDir, "*.md"))
+	if err != nil {
+		return nil, err
+	}
+	files := map[int]string{}
+	for _, name := range names {
+		chapter := &MarkdownChapter{File: filepath.Base(name)}
+		if err := readMarkdown(name, &chapter.ChapterMeta, &chapter.Content); err != nil {
+			problems = append(problems, err.Error())
+			continue
+		}
+
+		switch {
+		case chapter.Number <= 0:
+			problems = append(problems, fmt.Sprintf("%s: chapter number must be positive", chapter.File))
+		case files[chapter.Number] != "":
+			problems = append(problems, fmt.Sprintf("%s: chapter %d already in %s", chapter.File, chapter.Number, files[chapter.Number]))
+		case chapter.File != chapterFile(chapter.Number):
+			problems = append(problems, fmt.Sprintf("%s: chapter %d should be in %s", chapter.File, chapter.Number, chapterFile(chapter.Number)))
+		}
+		if strings.TrimSpace(chapter.Content) == "" {
+			problems = append(problems, fmt.Sprintf("%s: empty chapter", chapter.File))
+		}
+		if chapter.Number > 0 && files[chapter.Number] == "" {
+			files[chapter.Number] = chapter.File
+		}
+		story.Chapters = append(story.Chapters, chapter)
+	}
+	if len(story.Chapters) == 0 {
+		problems = append(problems, ChaptersDir+": no chapters")
+	}
+
+	sort.Slice(story.Chapters, func(i, j int) bool {
+		return story.Chapters[i].Number < story.Chapters[j].Number
+	})
+	if !allowGaps {
+		for i := 1; i < len(story.Chapters); i++ {
+			previous, current := story.Chapters[i-1].Number, story.Chapters[i].Number
+			if current > previous+1 {
+				problems = append(problems, fmt.Sprintf("chapters %d to %d are missing", previous+1, current-1))
+			}
+		}
+	}
+
+	if len(problems) > 0 {
+		return nil, &ValidationError{problems}
+	}
+	return story, nil
+}
+
+func readMarkdown(name string, meta any, body *string) error {
+	content, err := os.ReadFile(name)
+	if err != nil {
+		return err
+	}
+	text := strings.ReplaceAll(string(content), "\r\n", "\n")
+
+	rest, ok := strings.CutPrefix(text, "---\n")
+	if !ok {
+		return fmt.Errorf("%s: missing front matter", filepath.Base(name))
+	}
+	front, rest, ok := strings.Cut(rest, "\n---\n")
+	if !ok {
+		return fmt.Errorf("%s: unterminated front matter", filepath.Base(name))
+	}
+	if err := yaml.Unmarshal([]byte(front), meta); err != nil {
+		return fmt.Errorf("%s: %w", filepath.Base(name), err)
+	}
+	*body = strings.TrimSpace(rest)
+	return nil
+}
+
+type ImportOptions struct {
+	// Overwrite replaces existing chapters of the same source that differ instead of
+	// failing on them.
+	Overwrite bool
+	// DryRun checks the import against the database then rolls it back.
+	DryRun bool
+}
+
+type ImportResult struct {
+	Story     *models.Story
+	Created   bool
+	Inserted  int
+	Updated   int
+	Unchanged int
+	// Conflicts are chapters already stored with a different content.
+	Conflicts []int
+}
+
+// ErrConflicts is returned when chapters already exist with a different content and
+// ImportOptions.Overwrite is false, the result lists them.
+var ErrConflicts = errors.New("export: chapters conflict with stored ones")
+
+// ImportMarkdown stores a story read by ReadMarkdown in one transaction, creating it when
+// no story has its slug. Chapters already stored with the same content are skipped.
+func ImportMarkdown(ctx context.Context, db *bun.DB, imported *MarkdownStory, opts ImportOptions) (*ImportResult, error) {
+	source := imported.Source
+	if source == "" {
+		source = DefaultImportSource
+	}
+
+	result := &ImportResult{}
+	err := db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		now := time.Now().Unix()
+
+		story, err := datastore.FindStoryBySlug(ctx, tx, imported.Slug)
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return err
+		}
+		if story == nil {
+			story = &models.Story{
+				ID:        pkg.GenerateRandomID(),
+				Slug:      imported.Slug,
+				Creator:   source,
+				CreatedAt: now,
+			}
+			result.Created = true
+		}
+		// fields left empty keep what the crawler or a previous import stored
+		for field, value := range map[*string]string{
+			&story.Tittle:        imported.Title,
+			&story.OriginalTitle: imported.OriginalTitle,
+			&story.Author:        imported.Author,
+			&story.Description:   imported.Description,
+		} {
+			if value != "" {
+				*field = value
+			}
+		}
+		story.UpdatedAt = now
+		if result.Created {
+			story.Status = imported.Status
+			_, err = datastore.CreateStory(ctx, tx, story)
+		} else {
+			_, err = datastore.UpdateStory(ctx, tx, story)
+		}
+		if err != nil {
+			return err
+		}
+		if !result.Created && imported.Status != "" && imported.Status != story.Status {
+			if !models.CanTransitionStory(story.Status, imported.Status) {
+				return &ValidationError{[]string{fmt.Sprintf("%s: story cannot go from %s to %s", StoryFile, story.Status, imported.Status)}}
+			}
+			err := datastore.ChangeStoryStatus(ctx, tx, story, imported.Status, &models.StoryStatusChange{
+				Source:    "import:" + source,
+				CreatedAt: now,
+			})
+			if err != nil {
+				return err
+			}
+		}
+		result.Story = story
+
+		categories, err := datastore.FindCategoriesBySlugs(ctx, tx, imported.Categories)
+		if err != nil {
+			return err
+		}
+		if len(categories) != len(imported.Categories) {
+			known := map[string]bool{}
+			for _, category := range categories {
+				known[category.Slug] = true
+			}
+			var unknown []string
+			for _, slug := range imported.Categories {
+				if !known[slug] {
+					unknown = append(unknown, slug)
+				}
+			}
+			return &ValidationError{[]string{fmt.Sprintf("%s: unknown categories %s", StoryFile, strings.Join(unknown, ", "))}}
+		}
+		categoryIDs := make([]int64, 0, len(categories))
+		for _, category := range categories {
+			categoryIDs = append(categoryIDs, category.ID)
+		}
+		if err := datastore.LinkStoryCategories(ctx, tx, story.ID, categoryIDs); err != nil {
+			return err
+		}
+
+		numbers := make([]int, 0, len(imported.Chapters))
+		for _, chapter := range imported.Chapters {
+			numbers = append(numbers, chapter.Number)
+		}
+		existing, err := datastore.FindChaptersByNumbers(ctx, tx, story.ID, source, numbers)
+		if err != nil {
+			return err
+		}
+		stored := make(map[int]*models.Chapter, len(existing))
+		for _, chapter := range existing {
+			stored[chapter.Number] = chapter
+		}
+
+		for _, chapter := range imported.Chapters {
+			previous := stored[chapter.Number]
+			if previous != nil && previous.Content == chapter.Content && previous.Title == chapter.Title && previous.Volume == chapter.Volume {
+				result.Unchanged++
+				continue
+			}
+			if previous != nil && !opts.Overwrite {
+				result.Conflicts = append(result.Conflicts, chapter.Number)
+				continue
+			}
+
+			_, err := datastore.UpsertChapter(ctx, tx, &models.Chapter{
+				StoryID:   story.ID,
+				Source:    source,
+				Number:    chapter.Number,
+				Volume:    chapter.Volume,
+				Title:     chapter.Title,
+				Content:   chapter.Content,
+				Publisher: imported.Translator,
+				CreatedAt: now,
+				UpdatedAt: now,
+			})
+			if err != nil {
+				return err
+			}
+			if previous != nil {
+				result.Updated++
+			} else {
+				result.Inserted++
+			}
+		}
+
+		if len(result.Conflicts) > 0 {
+			return ErrConflicts
+		}
+		if opts.DryRun {
+			return errDryRun
+		}
+		return nil
+	})
+	if errors.Is(err, errDryRun) {
+		return result, nil
+	}
+	if err != nil {
+		return result, err
+	}
+	return result, nil
+}
+
+// errDryRun rolls back a dry run transaction.
+var errDryRun = errors.New("export: dry run")
@@ -0,0 +1,139 @@
+package media
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"demo-cosebase/pkg/fetcher"
+	"demo-cosebase/pkg/storage"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"image"
+	_ "image/gif"
+	"image/jpeg"
+	_ "image/png"
+	"io"
+	"regexp"
+)
+
+const SizeOriginal = "original"
+
+// CoverSizes are the thumbnail widths generated for every cover, heights keep the ratio.
+var CoverSizes = map[string]int{
+	"small":  150,
+	"medium": 300,
+	"large":  600,
+}
+
+// MaxCoverPixels bounds the size of a decoded cover, a small file can claim huge dimensions.
+const MaxCoverPixels = 25_000_000
+
+var (
+	ErrInvalidCover = errors.New("media: invalid cover")
+	hashRegex       = regexp.MustCompile(`^[0-9a-f]{64}$`)
+	originalFormats = []string{"jpeg", "png", "gif"}
+)
+
+// Covers mirrors story covers into a Storage, deduplicated by the sha256 of the image.
+type Covers struct {
+	storage storage.Storage
+	fetcher *fetcher.Fetcher
+}
+
+// NewCovers returns Covers, the fetcher is only needed to Mirror.
+func NewCovers(s storage.Storage, f *fetcher.Fetcher) *Covers {
+	return &Covers{storage: s, fetcher: f}
+}
+
+// Mirror downloads the cover at rawURL, stores it with its thumbnails and returns its hash.
+func (c *Covers) Mirror(ctx context.Context, rawURL string) (string, error) {
+	if c.fetcher == nil {
+		return "", errors.New("media: covers have no fetcher")
+	}
+
+	resp, err := c.fetcher.Fetch(ctx, rawURL)
+	if err != nil {
+		return "", err
+	}
+	return c.Store(ctx, resp.Body)
+}
+
+// Store saves the original image and its thumbnails unless the same image is already stored.
+func (c *Covers) Store(ctx context.Context, content []byte) (string, error) {
+	sum := sha256.Sum256(content)
+	hash := hex.EncodeToString(sum[:])
+
+	// covers come from remote hosts, only the header is trusted to be read before the checks
+	config, format, err := image.DecodeConfig(bytes.NewReader(content))
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", ErrInvalidCover, err)
+	}
+	if config.Width <= 0 || config.Height <= 0 || int64(config.Width)*int64(config.Height) > MaxCoverPixels {
+		return "", fmt.Errorf("%w: %dx%d pixels", ErrInvalidCover, config.Width, config.Height)
+	}
+
+	if _, err := c.storage.Stat(ctx, originalKey(hash, format)); err == nil {
+		return hash, nil
+	} else if !errors.Is(err, storage.ErrNotFound) {
+		return "", err
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(content))
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", ErrInvalidCover, err)
+	}
+	// converted once for every thumbnail
+	rgba := toRGBA(img)
+
+	for size, width := range CoverSizes {
+		var buf bytes.Buffer
+		if err := jpeg.Encode(&buf, Thumbnail(rgba, width), &jpeg.Options{Quality: 85}); err != nil {
+			return "", err
+		}
+		if err := c.storage.Put(ctx, thumbnailKey(hash, size), &buf, "image/jpeg"); err != nil {
+			return "", err
+		}
+	}
+
+	// the original goes last, its presence marks a complete set
+	if err := c.storage.Put(ctx, originalKey(hash, format), bytes.NewReader(content), "image/"+format); err != nil {
+		return "", err
+	}
+	return hash, nil
+}
+
+// Open returns a stored cover, size is SizeOriginal or a key of CoverSizes.
+func (c *Covers) Open(ctx context.Context, hash, size string) (io.ReadCloser, *storage.ObjectInfo, error) {
+	if !hashRegex.MatchString(hash) {
+		return nil, nil, storage.ErrNotFound
+	}
+
+	if size != SizeOriginal {
+		if _, ok := CoverSizes[size]; !ok {
+			return nil, nil, storage.ErrNotFound
+		}
+		return c.storage.Get(ctx, thumbnailKey(hash, size))
+	}
+
+	for _, format := range originalFormats {
+		reader, info, err := c.storage.Get(ctx, originalKey(hash, format))
+		if errors.Is(err, storage.ErrNotFound) {
+			continue
+		}
+		return reader, info, err
+	}
+	return nil, nil, storage.ErrNotFound
+}
+
+func originalKey(hash, format string) string {
+	ext := format
+	if format == "jpeg" {
+		ext = "jpg"
+	}
+	return fmt.Sprintf("covers/%s/%s.%s", hash, SizeOriginal, ext)
+}
+
+func thumbnailKey(hash, size string) string {
+	return fmt.Sprintf("covers/%s/%s.jpg", hash, size)
+}
@@ -0,0 +1,16 @@
+package models
+
+import "github.com/uptrace/bun"
+
+type Category struct {
+	bun.BaseModel `bun:"table:category"`
+	ID            int64  `bun:"id,pk,autoincrement" json:"id"`
+	Slug          string `bun:"slug,unique" json:"slug"`
+	Name          string `bun:"name" json:"name"`
+}
+
+type StoryCategory struct {
+	bun.BaseModel `bun:"table:story_category"`
+	StoryID       int64 `bun:"story_id,pk" json:"story_id"`
+	CategoryID    int64 `bun:"category_id,pk" json:"category_id"`
+}
@@ -2,11 +2,64 @@ package models
 
 import "github.com/uptrace/bun"
 
+const (
+	ChapterDraft     = "draft"
+	ChapterScheduled = "scheduled"
+	ChapterPublished = "published"
+)
+
 type Chapter struct {
 	bun.BaseModel   `bun:"table:chapter"`
 	ID              int64  `bun:"id,pk,autoincrement" json:"id"`
+	StoryID         int64  `bun:"story_id,notnull,unique:chapter_story_source_number" json:"story_id"`
+	Source          string `bun:"source,notnull,unique:chapter_story_source_number" json:"source"`
+	Number          int    `bun:"number,notnull,unique:chapter_story_source_number" json:"number"`
+	Volume          string `bun:"volume" json:"volume"`
 	Title           string `bun:"title" json:"title"`
+	Content         string `bun:"content" json:"content,omitempty"`
+	URL             string `bun:"url" json:"url,omitempty"`
 	PreviousChapter int64  `bun:"previous_chapter" json:"previous_chapter"`
 	AfterChapter    int64  `bun:"after_chapter" json:"after_chapter"`
 	Publisher       string `bun:"publisher" json:"publisher"`
+	CreatedAt       int64  `bun:"create_at" json:"created_at"`
+	UpdatedAt       int64  `bun:"update_at" json:"updated_at"`
+	// Status is ChapterPublished for crawled and imported chapters, uploaded ones start
+	// as ChapterDraft.
+	Status      string `bun:"status,notnull,nullzero,default:'published'" json:"status"`
+	PublishAt   int64  `bun:"publish_at,nullzero" json:"publish_at,omitempty"`
+	PublishedAt int64  `bun:"published_at,nullzero" json:"published_at,omitempty"`
+	UploaderID  int64  `bun:"uploader_id,nullzero" json:"uploader_id,omitempty"`
+	Story       *Story `bun:"rel:belongs-to,join:story_id=id" json:"story,omitempty"`
+}
+
+// ChapterRevision is the state of a chapter after an upload, an edit or a revert.
+type ChapterRevision struct {
+	bun.BaseModel `bun:"table:chapter_revision"`
+	ID            int64  `bun:"id,pk,autoincrement" json:"id"`
+	ChapterID     int64  `bun:"chapter_id,notnull,unique:chapter_revision_number" json:"chapter_id"`
+	Revision      int    `bun:"revision,notnull,unique:chapter_revision_number" json:"revision"`
+	Volume        string `bun:"volume" json:"volume"`
+	Title         string `bun:"title" json:"title"`
+	Content       string `bun:"content" json:"content,omitempty"`
+	EditorID      int64  `bun:"editor_id" json:"editor_id"`
+	Note          string `bun:"note" json:"note,omitempty"`
+	CreatedAt     int64  `bun:"create_at" json:"created_at"`
+}
+
+type ChapterDraftRequest struct {
+	Number  int    `json:"number" validate:"required,min=1"`
+	Volume  string `json:"volume" validate:"max=255"`
+	Title   string `json:"title" validate:"required,max=255"`
+	Content string `json:"content" validate:"required"`
+}
+
+// ChapterEditRequest changes the fields that are set.
+type ChapterEditRequest struct {
+	Volume  *string `json:"volume" validate:"omitempty,max=255"`
+	Title   *string `json:"title" validate:"omitempty,min=1,max=255"`
+	Content *string `json:"content" validate:"omitempty,min=1"`
+}
+
+type ChapterScheduleRequest struct {
+	PublishAt int64 `json:"publish_at" validate:"required"`
 }
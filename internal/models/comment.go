@@ -0,0 +1,14 @@
+package models
+
+import "github.com/uptrace/bun"
+
+type Comment struct {
+	bun.BaseModel `bun:"table:comment"`
+	ID            int64  `bun:"id,pk,autoincrement" json:"id"`
+	StoryID       int64  `bun:"story_id,notnull" json:"story_id"`
+	ChapterID     int64  `bun:"chapter_id" json:"chapter_id,omitempty"`
+	UserID        int64  `bun:"user_id,notnull" json:"user_id"`
+	Content       string `bun:"content" json:"content"`
+	CreatedAt     int64  `bun:"create_at" json:"created_at"`
+	UpdatedAt     int64  `bun:"update_at" json:"updated_at"`
+}
@@ -0,0 +1,54 @@
+package models
+
+import (
+	"encoding/json"
+	"github.com/uptrace/bun"
+)
+
+const (
+	CrawlRunQueued    = "queued"
+	CrawlRunRunning   = "running"
+	CrawlRunSucceeded = "succeeded"
+	CrawlRunFailed    = "failed"
+
+	CrawlTriggerCLI      = "cli"
+	CrawlTriggerSchedule = "schedule"
+	CrawlTriggerAPI      = "api"
+)
+
+type CrawlRun struct {
+	bun.BaseModel  `bun:"table:crawl_run"`
+	ID             int64           `bun:"id,pk,autoincrement" json:"id"`
+	Source         string          `bun:"source" json:"source"`
+	Command        string          `bun:"command" json:"command"`
+	Args           json.RawMessage `bun:"args,type:jsonb" json:"args"`
+	Trigger        string          `bun:"trigger" json:"trigger"`
+	Job            string          `bun:"job" json:"job,omitempty"`
+	Status         string          `bun:"status" json:"status"`
+	QueuedAt       int64           `bun:"queued_at" json:"queued_at,omitempty"`
+	StartedAt      int64           `bun:"started_at" json:"started_at,omitempty"`
+	FinishedAt     int64           `bun:"finished_at" json:"finished_at,omitempty"`
+	StoriesFetched int             `bun:"stories_fetched" json:"stories_fetched"`
+	ChaptersSaved  int             `bun:"chapters_saved" json:"chapters_saved"`
+	ErrorCount     int             `bun:"error_count" json:"error_count"`
+	Error          string          `bun:"error" json:"error,omitempty"`
+	Errors         []*CrawlError   `bun:"rel:has-many,join:id=run_id" json:"errors,omitempty"`
+}
+
+type CrawlError struct {
+	bun.BaseModel `bun:"table:crawl_error"`
+	ID            int64  `bun:"id,pk,autoincrement" json:"id"`
+	RunID         int64  `bun:"run_id,notnull" json:"run_id"`
+	URL           string `bun:"url" json:"url"`
+	Message       string `bun:"message" json:"message"`
+	CreatedAt     int64  `bun:"create_at" json:"created_at"`
+}
+
+type CrawlRequest struct {
+	Source   string   `json:"source"`
+	Command  string   `json:"command" validate:"required,oneof=category ranking followed story"`
+	Rank     string   `json:"rank"`
+	Pages    int      `json:"pages" validate:"min=0,max=50"`
+	Chapters bool     `json:"chapters"`
+	Stories  []string `json:"stories" validate:"required_if=Command story"`
+}
@@ -0,0 +1,31 @@
+package models
+
+import "github.com/uptrace/bun"
+
+const (
+	DuplicatePending   = "pending"
+	DuplicateMerged    = "merged"
+	DuplicateDismissed = "dismissed"
+)
+
+// StoryDuplicate is a pair of stories the matcher thinks are the same novel, waiting
+// for an admin. StoryID is always the smaller id of the pair.
+type StoryDuplicate struct {
+	bun.BaseModel `bun:"table:story_duplicate"`
+	ID            int64    `bun:"id,pk,autoincrement" json:"id"`
+	StoryID       int64    `bun:"story_id,notnull,unique:story_duplicate_pair" json:"story_id"`
+	DuplicateID   int64    `bun:"duplicate_id,notnull,unique:story_duplicate_pair" json:"duplicate_id"`
+	Score         float64  `bun:"score" json:"score"`
+	Reasons       []string `bun:"reasons,type:jsonb" json:"reasons"`
+	Status        string   `bun:"status,notnull,default:'pending'" json:"status"`
+	CreatedAt     int64    `bun:"create_at" json:"created_at"`
+	ReviewedAt    int64    `bun:"reviewed_at" json:"reviewed_at,omitempty"`
+	ReviewedBy    int64    `bun:"reviewed_by" json:"reviewed_by,omitempty"`
+	Story         *Story   `bun:"rel:belongs-to,join:story_id=id" json:"story,omitempty"`
+	Duplicate     *Story   `bun:"rel:belongs-to,join:duplicate_id=id" json:"duplicate,omitempty"`
+}
+
+type MergeDuplicateRequest struct {
+	// KeepID is the surviving story, by default the one with more chapters.
+	KeepID int64 `json:"keep_id"`
+}
@@ -0,0 +1,10 @@
+package models
+
+import "github.com/uptrace/bun"
+
+type Follow struct {
+	bun.BaseModel `bun:"table:story_follow"`
+	UserID        int64 `bun:"user_id,pk" json:"user_id"`
+	StoryID       int64 `bun:"story_id,pk" json:"story_id"`
+	CreatedAt     int64 `bun:"create_at" json:"created_at"`
+}
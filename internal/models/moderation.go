@@ -0,0 +1,73 @@
+package models
+
+import "github.com/uptrace/bun"
+
+const (
+	ReportAbuse = "report"
+	ReportDMCA  = "dmca"
+
+	ReportOpen     = "open"
+	ReportResolved = "resolved"
+	ReportRejected = "rejected"
+
+	// ModerationDismiss closes a report without touching the story, the other actions
+	// change its status.
+	ModerationDismiss  = "dismiss"
+	ModerationHide     = "hide"
+	ModerationTakeDown = "take-down"
+)
+
+// StoryStatusChange is the audit record of a status change. ActorID is zero for
+// changes made by the crawler, Source tells where it came from.
+type StoryStatusChange struct {
+	bun.BaseModel `bun:"table:story_status_change"`
+	ID            int64  `bun:"id,pk,autoincrement" json:"id"`
+	StoryID       int64  `bun:"story_id,notnull" json:"story_id"`
+	From          string `bun:"from_status" json:"from"`
+	To            string `bun:"to_status,notnull" json:"to"`
+	ActorID       int64  `bun:"actor_id,nullzero" json:"actor_id,omitempty"`
+	Source        string `bun:"source" json:"source"`
+	Reason        string `bun:"reason" json:"reason,omitempty"`
+	ReportID      int64  `bun:"report_id,nullzero" json:"report_id,omitempty"`
+	CreatedAt     int64  `bun:"create_at" json:"created_at"`
+}
+
+// StoryReport is a report of a story waiting in the moderation queue.
+type StoryReport struct {
+	bun.BaseModel `bun:"table:story_report"`
+	ID            int64  `bun:"id,pk,autoincrement" json:"id"`
+	StoryID       int64  `bun:"story_id,notnull" json:"story_id"`
+	Kind          string `bun:"kind,notnull" json:"kind"`
+	ReporterID    int64  `bun:"reporter_id,nullzero" json:"reporter_id,omitempty"`
+	Reason        string `bun:"reason" json:"reason"`
+	// Contact is how to reach a rights holder about a DMCA notice.
+	Contact    string `bun:"contact" json:"contact,omitempty"`
+	Status     string `bun:"status,notnull" json:"status"`
+	ResolverID int64  `bun:"resolver_id,nullzero" json:"resolver_id,omitempty"`
+	Resolution string `bun:"resolution" json:"resolution,omitempty"`
+	Note       string `bun:"note" json:"note,omitempty"`
+	CreatedAt  int64  `bun:"create_at" json:"created_at"`
+	ResolvedAt int64  `bun:"resolved_at,nullzero" json:"resolved_at,omitempty"`
+	Story      *Story `bun:"rel:belongs-to,join:story_id=id" json:"story,omitempty"`
+}
+
+type StoryStatusRequest struct {
+	Status string `json:"status" validate:"required,oneof=draft ongoing completed paused dropped hidden taken-down"`
+	Reason string `json:"reason" validate:"max=1000"`
+}
+
+type StoryReportRequest struct {
+	Reason string `json:"reason" validate:"required,max=2000"`
+}
+
+// DMCAReportRequest flags a story for a DMCA notice received by the admins.
+type DMCAReportRequest struct {
+	Slug    string `json:"slug" validate:"required"`
+	Reason  string `json:"reason" validate:"required,max=2000"`
+	Contact string `json:"contact" validate:"max=255"`
+}
+
+type ModerationRequest struct {
+	Action string `json:"action" validate:"required,oneof=dismiss hide take-down"`
+	Note   string `json:"note" validate:"max=1000"`
+}
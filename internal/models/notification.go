@@ -0,0 +1,25 @@
+package models
+
+import "github.com/uptrace/bun"
+
+const (
+	NotificationNewChapter = "new_chapter"
+)
+
+type Notification struct {
+	bun.BaseModel `bun:"table:notification"`
+	ID            int64  `bun:"id,pk,autoincrement" json:"id"`
+	UserID        int64  `bun:"user_id,notnull" json:"user_id"`
+	Kind          string `bun:"kind" json:"kind"`
+	StoryID       int64  `bun:"story_id" json:"story_id,omitempty"`
+	ChapterID     int64  `bun:"chapter_id" json:"chapter_id,omitempty"`
+	Message       string `bun:"message" json:"message"`
+	CreatedAt     int64  `bun:"create_at" json:"created_at"`
+	ReadAt        int64  `bun:"read_at" json:"read_at,omitempty"`
+}
+
+// NotificationReadRequest marks the listed notifications as read, all of them when IDs
+// is empty.
+type NotificationReadRequest struct {
+	IDs []int64 `json:"ids"`
+}
@@ -0,0 +1,51 @@
+package models
+
+import "github.com/uptrace/bun"
+
+// ReadingProgress is where a user is in a story, MaxChapter is the furthest chapter read
+// even after going back.
+type ReadingProgress struct {
+	bun.BaseModel `bun:"table:reading_progress"`
+	UserID        int64  `bun:"user_id,pk" json:"user_id"`
+	StoryID       int64  `bun:"story_id,pk" json:"story_id"`
+	ChapterNumber int    `bun:"chapter_number,notnull" json:"chapter_number"`
+	MaxChapter    int    `bun:"max_chapter,notnull" json:"max_chapter"`
+	UpdatedAt     int64  `bun:"update_at" json:"updated_at"`
+	Story         *Story `bun:"rel:belongs-to,join:story_id=id" json:"story,omitempty"`
+}
+
+type StoryRating struct {
+	bun.BaseModel `bun:"table:story_rating"`
+	UserID        int64 `bun:"user_id,pk" json:"user_id"`
+	StoryID       int64 `bun:"story_id,pk" json:"story_id"`
+	Score         int   `bun:"score,notnull" json:"score"`
+	CreatedAt     int64 `bun:"create_at" json:"created_at"`
+	UpdatedAt     int64 `bun:"update_at" json:"updated_at"`
+}
+
+type RatingSummary struct {
+	Average float64 `bun:"average" json:"average"`
+	Count   int     `bun:"count" json:"count"`
+}
+
+type ReadingProgressRequest struct {
+	Chapter int `json:"chapter" validate:"required,min=1"`
+}
+
+type RatingRequest struct {
+	Score int `json:"score" validate:"required,min=1,max=5"`
+}
+
+// Recommendation is a story recommended to a user or similar to another one. It carries
+// what a list needs so serving it does not touch the database.
+type Recommendation struct {
+	StoryID   int64   `json:"story_id"`
+	Slug      string  `json:"slug"`
+	Title     string  `json:"title"`
+	Author    string  `json:"author,omitempty"`
+	CoverHash string  `json:"cover_hash,omitempty"`
+	Score     float64 `json:"score"`
+	// Reason is the id of the story the recommendation comes from, zero when it comes
+	// from the user's favourite genres or from popularity.
+	Reason int64 `json:"reason,omitempty"`
+}
@@ -0,0 +1,156 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+)
+
+// UserSettingsVersion is the version of the settings document written today. Documents
+// of older versions are upgraded by userSettingsMigrations when read.
+const UserSettingsVersion = 1
+
+// UserSettings are the reader preferences of a user, stored as a JSON document on the
+// user and synced between their devices.
+type UserSettings struct {
+	Version     int     `json:"version"`
+	FontSize    int     `json:"font_size"`
+	Theme       string  `json:"theme"`
+	LineSpacing float64 `json:"line_spacing"`
+	AutoScroll  bool    `json:"auto_scroll"`
+	// AutoScrollSpeed is in lines per minute.
+	AutoScrollSpeed int `json:"auto_scroll_speed"`
+	// PreferredSource is the source read first when several have the same chapter,
+	// empty for the most recently updated one.
+	PreferredSource     string        `json:"preferred_source"`
+	HideSpoilerComments bool          `json:"hide_spoiler_comments"`
+	Email               EmailSettings `json:"email"`
+}
+
+type EmailSettings struct {
+	NewChapters bool `json:"new_chapters"`
+	Replies     bool `json:"replies"`
+	Newsletter  bool `json:"newsletter"`
+}
+
+func DefaultUserSettings() *UserSettings {
+	return &UserSettings{
+		Version:         UserSettingsVersion,
+		FontSize:        18,
+		Theme:           "system",
+		LineSpacing:     1.5,
+		AutoScrollSpeed: 30,
+		Email: EmailSettings{
+			NewChapters: true,
+			Replies:     true,
+		},
+	}
+}
+
+// userSettingsMigrations[v] upgrades a document of version v to v+1. The document is
+// decoded over the defaults first, a migration only handles what changed meaning.
+var userSettingsMigrations = []func(settings map[string]any){
+	// 0: documents written before versioning, the fields were the same
+	func(settings map[string]any) {},
+}
+
+// ParseUserSettings reads a stored document of any version. Fields it lacks keep their
+// default value.
+func ParseUserSettings(raw []byte) (*UserSettings, error) {
+	document := map[string]any{}
+	if err := json.Unmarshal(raw, &document); err != nil {
+		return nil, fmt.Errorf("models: user settings: %w", err)
+	}
+
+	version := 0
+	if v, ok := document["version"].(float64); ok {
+		version = int(v)
+	}
+	if version > UserSettingsVersion {
+		return nil, fmt.Errorf("models: user settings version %d is newer than %d", version, UserSettingsVersion)
+	}
+	for ; version < UserSettingsVersion; version++ {
+		userSettingsMigrations[version](document)
+	}
+	document["version"] = UserSettingsVersion
+
+	upgraded, err := json.Marshal(document)
+	if err != nil {
+		return nil, err
+	}
+	settings := DefaultUserSettings()
+	if err := json.Unmarshal(upgraded, settings); err != nil {
+		return nil, fmt.Errorf("models: user settings: %w", err)
+	}
+	return settings, nil
+}
+
+// Scan lets bun read the settings column through ParseUserSettings.
+func (s *UserSettings) Scan(src any) error {
+	var raw []byte
+	switch v := src.(type) {
+	case []byte:
+		raw = v
+	case string:
+		raw = []byte(v)
+	default:
+		return fmt.Errorf("models: cannot scan %T into user settings", src)
+	}
+
+	settings, err := ParseUserSettings(raw)
+	if err != nil {
+		return err
+	}
+	*s = *settings
+	return nil
+}
+
+// Value writes the document as text, bytes would be sent as bytea.
+func (s *UserSettings) Value() (driver.Value, error) {
+	s.Version = UserSettingsVersion
+	raw, err := json.Marshal(s)
+	if err != nil {
+		return nil, err
+	}
+	return string(raw), nil
+}
+
+// UserSettingsPatch changes the settings that are set, the rest keep their value.
+type UserSettingsPatch struct {
+	FontSize            *int                `json:"font_size" validate:"omitempty,min=10,max=40"`
+	Theme               *string             `json:"theme" validate:"omitempty,oneof=system light dark sepia"`
+	LineSpacing         *float64            `json:"line_spacing" validate:"omitempty,min=1,max=3"`
+	AutoScroll          *bool               `json:"auto_scroll"`
+	AutoScrollSpeed     *int                `json:"auto_scroll_speed" validate:"omitempty,min=1,max=300"`
+	PreferredSource     *string             `json:"preferred_source" validate:"omitempty,max=64"`
+	HideSpoilerComments *bool               `json:"hide_spoiler_comments"`
+	Email               *EmailSettingsPatch `json:"email"`
+}
+
+type EmailSettingsPatch struct {
+	NewChapters *bool `json:"new_chapters"`
+	Replies     *bool `json:"replies"`
+	Newsletter  *bool `json:"newsletter"`
+}
+
+// Apply copies the fields set in p onto settings.
+func (p *UserSettingsPatch) Apply(settings *UserSettings) {
+	set(&settings.FontSize, p.FontSize)
+	set(&settings.Theme, p.Theme)
+	set(&settings.LineSpacing, p.LineSpacing)
+	set(&settings.AutoScroll, p.AutoScroll)
+	set(&settings.AutoScrollSpeed, p.AutoScrollSpeed)
+	set(&settings.PreferredSource, p.PreferredSource)
+	set(&settings.HideSpoilerComments, p.HideSpoilerComments)
+	if p.Email != nil {
+		set(&settings.Email.NewChapters, p.Email.NewChapters)
+		set(&settings.Email.Replies, p.Email.Replies)
+		set(&settings.Email.Newsletter, p.Email.Newsletter)
+	}
+}
+
+func set[T any](field *T, value *T) {
+	if value != nil {
+		*field = *value
+	}
+}
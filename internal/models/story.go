@@ -4,15 +4,98 @@ import "github.com/uptrace/bun"
 
 type Story struct {
 	bun.BaseModel `bun:"table:story"`
-	ID            int64  `bun:"id,pk" json:"id"`
-	Slug          string `bun:"slug" json:"slug"`
-	Tittle        string `bun:"tiltle" json:"tile"`
-	Description   string `bun:"description" json:"description"`
-	Creator       string `bun:"creator" json:"creator"`
-	CreatedAt     int64  `bun:"create_at" json:"created_at"`
-	UpdatedAt     int64  `bun:"update_at" json:"updated_at"`
-	Status        string `bun:"status" json:"status"`
-	Image         string `bun:"image" json:"image"`
+	ID            int64          `bun:"id,pk" json:"id"`
+	Slug          string         `bun:"slug,unique" json:"slug"`
+	Tittle        string         `bun:"tiltle" json:"tile"`
+	OriginalTitle string         `bun:"original_title" json:"original_title"`
+	Author        string         `bun:"author" json:"author"`
+	Description   string         `bun:"description" json:"description"`
+	Creator       string         `bun:"creator" json:"creator"`
+	CreatedAt     int64          `bun:"create_at" json:"created_at"`
+	UpdatedAt     int64          `bun:"update_at" json:"updated_at"`
+	Status        string         `bun:"status,notnull,nullzero,default:'ongoing'" json:"status"`
+	Image         string         `bun:"image" json:"image"`
+	CoverHash     string         `bun:"cover_hash" json:"cover_hash"`
+	CoverURL      string         `bun:"cover_url" json:"-"`
+	Sources       []*StorySource `bun:"rel:has-many,join:id=story_id" json:"sources,omitempty"`
 }
 
 type Stories struct{}
+
+const (
+	StoryDraft     = "draft"
+	StoryOngoing   = "ongoing"
+	StoryCompleted = "completed"
+	StoryPaused    = "paused"
+	StoryDropped   = "dropped"
+	// StoryHidden and StoryTakenDown are set by moderators, such stories are only
+	// visible to admins.
+	StoryHidden    = "hidden"
+	StoryTakenDown = "taken-down"
+)
+
+// PublicStoryStatuses are the statuses of the stories listed and served to readers.
+var PublicStoryStatuses = []string{StoryOngoing, StoryCompleted, StoryPaused, StoryDropped}
+
+// storyTransitions lists the statuses a story can move to from each status. Any story
+// can be hidden or taken down, a taken down story only comes back through hidden once
+// the claim is settled.
+var storyTransitions = map[string][]string{
+	StoryDraft:     {StoryOngoing, StoryCompleted, StoryHidden, StoryTakenDown},
+	StoryOngoing:   {StoryCompleted, StoryPaused, StoryDropped, StoryHidden, StoryTakenDown},
+	StoryCompleted: {StoryOngoing, StoryHidden, StoryTakenDown},
+	StoryPaused:    {StoryOngoing, StoryCompleted, StoryDropped, StoryHidden, StoryTakenDown},
+	StoryDropped:   {StoryOngoing, StoryHidden, StoryTakenDown},
+	StoryHidden:    {StoryDraft, StoryOngoing, StoryCompleted, StoryPaused, StoryDropped, StoryTakenDown},
+	StoryTakenDown: {StoryHidden},
+}
+
+func IsStoryStatus(status string) bool {
+	_, ok := storyTransitions[status]
+	return ok
+}
+
+// IsPublicStoryStatus reports whether a story with status is visible to readers.
+func IsPublicStoryStatus(status string) bool {
+	for _, public := range PublicStoryStatuses {
+		if status == public {
+			return true
+		}
+	}
+	return false
+}
+
+// CanTransitionStory reports whether a story can move from one status to another. A new
+// story, without status, can start in any status.
+func CanTransitionStory(from, to string) bool {
+	if !IsStoryStatus(to) {
+		return false
+	}
+	if from == "" {
+		return true
+	}
+	for _, next := range storyTransitions[from] {
+		if next == to {
+			return true
+		}
+	}
+	return false
+}
+
+// StorySource links a story to its page on a crawled site. The same novel found on
+// several sites has one StorySource per site, all pointing to the same story.
+type StorySource struct {
+	bun.BaseModel `bun:"table:story_source"`
+	ID            int64  `bun:"id,pk,autoincrement" json:"id"`
+	StoryID       int64  `bun:"story_id,notnull" json:"story_id"`
+	Source        string `bun:"source,notnull,unique:story_source_source_id" json:"source"`
+	SourceID      string `bun:"source_id,notnull,unique:story_source_source_id" json:"source_id"`
+	Slug          string `bun:"slug" json:"slug"`
+	URL           string `bun:"url" json:"url"`
+	CrawledAt     int64  `bun:"crawled_at" json:"crawled_at"`
+}
+
+// QualifiedID identifies the story on its site, e.g. "tangthuvien:21918".
+func (s *StorySource) QualifiedID() string {
+	return s.Source + ":" + s.SourceID
+}
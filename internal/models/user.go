@@ -2,6 +2,12 @@ package models
 
 import "github.com/uptrace/bun"
 
+const (
+	RoleUser     = "user"
+	RoleUploader = "uploader"
+	RoleAdmin    = "admin"
+)
+
 type User struct {
 	bun.BaseModel `bun:"table:user"`
 	ID            int64  `bun:"id,pk" json:"id"`
@@ -11,6 +17,17 @@ type User struct {
 	Password      string `bun:"password" json:"password"`
 	Email         string `bun:"email" json:"email"`
 	IsActive      bool   `bun:"is_active" json:"is_active"`
+	Role          string `bun:"role,notnull,nullzero,default:'user'" json:"role"`
+	// FeedToken is the sha256 of the token of the private feed, the token itself is only
+	// shown when created.
+	FeedToken string `bun:"feed_token" json:"-"`
+	// Settings is nil until the user changes one, DefaultUserSettings applies.
+	Settings *UserSettings `bun:"settings,type:jsonb" json:"-"`
+}
+
+type FeedTokenResponse struct {
+	Token   string `json:"token"`
+	FeedURL string `json:"feed_url"`
 }
 
 type LoginRequest struct {
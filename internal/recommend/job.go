@@ -0,0 +1,94 @@
+package recommend
+
+import (
+	"context"
+	"demo-cosebase/internal/datastore"
+	"demo-cosebase/internal/datastore/redis_store"
+	"demo-cosebase/internal/models"
+	"github.com/redis/go-redis/v9"
+	"github.com/uptrace/bun"
+	"time"
+)
+
+// DefaultTTL keeps the lists of a build long enough for a daily build to replace them.
+const DefaultTTL = 48 * time.Hour
+
+type Stats struct {
+	Interactions int
+	Users        int
+	Stories      int
+	Duration     time.Duration
+}
+
+// Run builds the recommendations from the database and stores them in Redis.
+func Run(ctx context.Context, db bun.IDB, client redis.UniversalClient, opts Options, ttl time.Duration) (*Stats, error) {
+	started := time.Now()
+
+	interactions, err := datastore.FindInteractions(ctx, db)
+	if err != nil {
+		return nil, err
+	}
+	stories, err := datastore.FindPublicStories(ctx, db)
+	if err != nil {
+		return nil, err
+	}
+	links, err := datastore.FindStoryCategoryLinks(ctx, db)
+	if err != nil {
+		return nil, err
+	}
+
+	public := make(map[int64]*models.Story, len(stories))
+	isPublic := make(map[int64]bool, len(stories))
+	for _, story := range stories {
+		public[story.ID] = story
+		isPublic[story.ID] = true
+	}
+	categories := map[int64][]int64{}
+	for _, link := range links {
+		categories[link.StoryID] = append(categories[link.StoryID], link.CategoryID)
+	}
+
+	result := Build(interactions, isPublic, categories, opts)
+
+	lists := &redis_store.RecommendationLists{
+		Users:   make(map[int64][]*models.Recommendation, len(result.Users)),
+		Similar: make(map[string][]*models.Recommendation, len(result.Similar)),
+		Popular: recommendations(result.Popular, public),
+	}
+	for userID, scored := range result.Users {
+		lists.Users[userID] = recommendations(scored, public)
+	}
+	for storyID, scored := range result.Similar {
+		lists.Similar[public[storyID].Slug] = recommendations(scored, public)
+	}
+	if err := redis_store.SaveRecommendations(ctx, client, lists, ttl); err != nil {
+		return nil, err
+	}
+
+	return &Stats{
+		Interactions: len(interactions),
+		Users:        len(lists.Users),
+		Stories:      len(lists.Similar),
+		Duration:     time.Since(started),
+	}, nil
+}
+
+func recommendations(scored []Scored, stories map[int64]*models.Story) []*models.Recommendation {
+	list := make([]*models.Recommendation, 0, len(scored))
+	for _, s := range scored {
+		story := stories[s.StoryID]
+		if story == nil {
+			continue
+		}
+		list = append(list, &models.Recommendation{
+			StoryID:   story.ID,
+			Slug:      story.Slug,
+			Title:     story.Tittle,
+			Author:    story.Author,
+			CoverHash: story.CoverHash,
+			Score:     s.Score,
+			Reason:    s.Reason,
+		})
+	}
+	return list
+}
@@ -0,0 +1,267 @@
+// Package recommend computes story recommendations from what readers follow, read and
+// rate. Stories are similar when the same readers like them (item to item
+// co-occurrence), a reader gets the stories similar to the ones they like, boosted by
+// their favourite genres.
+package recommend
+
+import (
+	"demo-cosebase/internal/datastore"
+	"math"
+	"sort"
+)
+
+type Options struct {
+	// SimilarLimit is the number of similar stories kept per story.
+	SimilarLimit int
+	// UserLimit is the number of recommendations kept per user.
+	UserLimit int
+	// PopularLimit is the size of the list served to users without history.
+	PopularLimit int
+	// MinCoReaders is the number of readers two stories must share to be similar.
+	MinCoReaders int
+	// MaxItemsPerUser bounds the stories of one user counted in co-occurrences, the
+	// most liked first, so a few heavy readers do not make everything similar.
+	MaxItemsPerUser int
+	// GenreWeight is the weight of the genre affinity against the co-occurrence score.
+	GenreWeight float64
+	// GenreCandidates is the number of popular stories of each top genre of a user
+	// considered besides the similar ones.
+	GenreCandidates int
+}
+
+var DefaultOptions = Options{
+	SimilarLimit:    20,
+	UserLimit:       30,
+	PopularLimit:    30,
+	MinCoReaders:    2,
+	MaxItemsPerUser: 300,
+	GenreWeight:     0.3,
+	GenreCandidates: 50,
+}
+
+// Scored is a recommended story, Reason is the story it comes from or zero.
+type Scored struct {
+	StoryID int64
+	Score   float64
+	Reason  int64
+}
+
+// Result holds the similar stories by story id and the recommendations by user id.
+type Result struct {
+	Similar map[int64][]Scored
+	Users   map[int64][]Scored
+	Popular []Scored
+}
+
+// Weight is how much an interaction says a user likes a story: a follow counts for one,
+// reading up to one more the further they read, and a rating adds or removes up to one.
+func Weight(i *datastore.Interaction) float64 {
+	weight := 0.0
+	if i.Followed {
+		weight++
+	}
+	weight += math.Min(float64(i.Chapters), 50) / 50
+	if i.Score > 0 {
+		weight += float64(i.Score-3) / 2
+	}
+	return weight
+}
+
+type item struct {
+	story  int64
+	weight float64
+}
+
+// Build computes the recommendations. Only stories in public are recommended,
+// categories lists the categories of every story.
+func Build(interactions []*datastore.Interaction, public map[int64]bool, categories map[int64][]int64, opts Options) *Result {
+	// what every user liked, and everything they touched so it is not recommended back
+	liked := map[int64][]item{}
+	seen := map[int64]map[int64]bool{}
+	for _, i := range interactions {
+		if seen[i.UserID] == nil {
+			seen[i.UserID] = map[int64]bool{}
+		}
+		seen[i.UserID][i.StoryID] = true
+		if w := Weight(i); w > 0 {
+			liked[i.UserID] = append(liked[i.UserID], item{i.StoryID, w})
+		}
+	}
+
+	readers := map[int64]int{}
+	pairs := map[[2]int64]int{}
+	for user, items := range liked {
+		sort.Slice(items, func(a, b int) bool {
+			if items[a].weight != items[b].weight {
+				return items[a].weight > items[b].weight
+			}
+			return items[a].story < items[b].story
+		})
+		if len(items) > opts.MaxItemsPerUser {
+			items = items[:opts.MaxItemsPerUser]
+			liked[user] = items
+		}
+		for a := range items {
+			readers[items[a].story]++
+			for b := a + 1; b < len(items); b++ {
+				x, y := items[a].story, items[b].story
+				if x > y {
+					x, y = y, x
+				}
+				pairs[[2]int64{x, y}]++
+			}
+		}
+	}
+
+	// cosine similarity of the reader sets
+	similar := map[int64][]Scored{}
+	for pair, count := range pairs {
+		if count < opts.MinCoReaders {
+			continue
+		}
+		score := float64(count) / math.Sqrt(float64(readers[pair[0]]*readers[pair[1]]))
+		if public[pair[1]] {
+			similar[pair[0]] = append(similar[pair[0]], Scored{StoryID: pair[1], Score: score})
+		}
+		if public[pair[0]] {
+			similar[pair[1]] = append(similar[pair[1]], Scored{StoryID: pair[0], Score: score})
+		}
+	}
+	for story, list := range similar {
+		if !public[story] {
+			delete(similar, story)
+			continue
+		}
+		similar[story] = top(list, opts.SimilarLimit)
+	}
+
+	var popular []Scored
+	for story, count := range readers {
+		if public[story] {
+			popular = append(popular, Scored{StoryID: story, Score: float64(count)})
+		}
+	}
+	popular = top(popular, len(popular))
+
+	// the most read stories of every category, candidates for genre lovers
+	popularByCategory := map[int64][]int64{}
+	for _, scored := range popular {
+		for _, category := range categories[scored.StoryID] {
+			if len(popularByCategory[category]) < opts.GenreCandidates {
+				popularByCategory[category] = append(popularByCategory[category], scored.StoryID)
+			}
+		}
+	}
+
+	users := map[int64][]Scored{}
+	for user, items := range liked {
+		affinity := genreAffinity(items, categories)
+		candidates := map[int64]*Scored{}
+		best := map[int64]float64{}
+		for _, it := range items {
+			for _, s := range similar[it.story] {
+				if seen[user][s.StoryID] {
+					continue
+				}
+				c := candidates[s.StoryID]
+				if c == nil {
+					c = &Scored{StoryID: s.StoryID}
+					candidates[s.StoryID] = c
+				}
+				contribution := it.weight * s.Score
+				// the reason is the liked story contributing the most
+				if contribution > best[s.StoryID] {
+					best[s.StoryID] = contribution
+					c.Reason = it.story
+				}
+				c.Score += contribution
+			}
+		}
+		for _, category := range topGenres(affinity, 3) {
+			for _, story := range popularByCategory[category] {
+				if !seen[user][story] && candidates[story] == nil {
+					candidates[story] = &Scored{StoryID: story}
+				}
+			}
+		}
+
+		list := make([]Scored, 0, len(candidates))
+		for _, c := range candidates {
+			genre := genreScore(affinity, categories[c.StoryID])
+			if c.Score == 0 {
+				c.Reason = 0
+			}
+			c.Score += opts.GenreWeight * genre
+			if c.Score > 0 {
+				list = append(list, *c)
+			}
+		}
+		if len(list) > 0 {
+			users[user] = top(list, opts.UserLimit)
+		}
+	}
+
+	return &Result{
+		Similar: similar,
+		Users:   users,
+		Popular: top(popular, opts.PopularLimit),
+	}
+}
+
+// genreAffinity is the share of what a user liked in every category.
+func genreAffinity(items []item, categories map[int64][]int64) map[int64]float64 {
+	affinity := map[int64]float64{}
+	total := 0.0
+	for _, it := range items {
+		for _, category := range categories[it.story] {
+			affinity[category] += it.weight
+			total += it.weight
+		}
+	}
+	for category := range affinity {
+		affinity[category] /= total
+	}
+	return affinity
+}
+
+func genreScore(affinity map[int64]float64, categories []int64) float64 {
+	if len(categories) == 0 {
+		return 0
+	}
+	score := 0.0
+	for _, category := range categories {
+		score += affinity[category]
+	}
+	return score / float64(len(categories))
+}
+
+func topGenres(affinity map[int64]float64, n int) []int64 {
+	genres := make([]int64, 0, len(affinity))
+	for category := range affinity {
+		genres = append(genres, category)
+	}
+	sort.Slice(genres, func(a, b int) bool {
+		if affinity[genres[a]] != affinity[genres[b]] {
+			return affinity[genres[a]] > affinity[genres[b]]
+		}
+		return genres[a] < genres[b]
+	})
+	if len(genres) > n {
+		genres = genres[:n]
+	}
+	return genres
+}
+
+// top sorts by score, ties by id so builds are stable, and keeps n.
+func top(list []Scored, n int) []Scored {
+	sort.Slice(list, func(a, b int) bool {
+		if list[a].Score != list[b].Score {
+			return list[a].Score > list[b].Score
+		}
+		return list[a].StoryID < list[b].StoryID
+	})
+	if len(list) > n {
+		list = list[:n]
+	}
+	return list
+}
@@ -0,0 +1,61 @@
+package services
+
+import (
+	"context"
+	"demo-cosebase/pkg/caching"
+	"errors"
+	"fmt"
+	"github.com/go-redis/cache/v9"
+	"github.com/hiendaovinh/toolkit/pkg/errorx"
+	"github.com/samber/do"
+)
+
+// ServiceCache lets admins see how the cache does and drop what went stale.
+type ServiceCache struct {
+	container *do.Injector
+	cache     caching.Cache
+}
+
+func NewServiceCache(container *do.Injector) (*ServiceCache, error) {
+	cache, err := do.Invoke[caching.Cache](container)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ServiceCache{container, cache}, nil
+}
+
+// Metrics are the counters of this api instance since it started.
+func (service *ServiceCache) Metrics() []*caching.Metrics {
+	return caching.Snapshot()
+}
+
+func (service *ServiceCache) Inspect(ctx context.Context, key string) (*caching.KeyInfo, error) {
+	inspector, ok := service.cache.(caching.Inspector)
+	if !ok {
+		return nil, errorx.Wrap(errors.New("cache cannot inspect keys"), errorx.Service)
+	}
+
+	info, err := inspector.Inspect(ctx, key)
+	if errors.Is(err, cache.ErrCacheMiss) {
+		return nil, errorx.Wrap(fmt.Errorf("key %s not found", key), errorx.NotExist)
+	}
+	if err != nil {
+		return nil, errorx.Wrap(err, errorx.Service)
+	}
+	return info, nil
+}
+
+func (service *ServiceCache) Delete(ctx context.Context, key string) error {
+	if err := service.cache.Delete(ctx, key); err != nil {
+		return errorx.Wrap(err, errorx.Service)
+	}
+	return nil
+}
+
+func (service *ServiceCache) InvalidateTag(ctx context.Context, tag string) error {
+	if err := service.cache.InvalidateTag(ctx, tag); err != nil {
+		return errorx.Wrap(err, errorx.Service)
+	}
+	return nil
+}
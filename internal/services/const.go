@@ -7,8 +7,48 @@ import (
 
 const (
 	CacheTtl5Mins = 5 * time.Minute
+	// how long a value past its ttl is served while it is refreshed in the background
+	CacheStaleWhileRevalidate = time.Minute
+	// how long the last good value is served while the database is unavailable
+	CacheStaleIfError = time.Hour
+	// how long a lookup of something that does not exist is remembered
+	CacheNegativeTtl = 30 * time.Second
+	// how long a readiness check waits for each dependency
+	HealthCheckTimeout = 2 * time.Second
+	// how often readiness probes check the mail transport
+	HealthMailInterval = 5 * time.Minute
 )
 
 func DBKeyUserByUsername(username string) string {
 	return fmt.Sprintf("user:%s", username)
 }
+
+func DBKeyStory(slug string) string {
+	return fmt.Sprintf("story:%s", slug)
+}
+
+func DBKeyStoryFeed(slug string) string {
+	return fmt.Sprintf("feed:story:%s", slug)
+}
+
+func DBKeyCategoryFeed(slug string) string {
+	return fmt.Sprintf("feed:category:%s", slug)
+}
+
+func DBKeyFollowedFeed(userID int64) string {
+	return fmt.Sprintf("feed:followed:%d", userID)
+}
+
+// Cache tags, invalidating one drops every cached value built from that record.
+
+func TagStory(storyID int64) string {
+	return fmt.Sprintf("story:%d", storyID)
+}
+
+func TagCategory(categoryID int64) string {
+	return fmt.Sprintf("category:%d", categoryID)
+}
+
+func TagUser(userID int64) string {
+	return fmt.Sprintf("user:%d", userID)
+}
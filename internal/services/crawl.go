@@ -0,0 +1,106 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"demo-cosebase/internal/crawler"
+	_ "demo-cosebase/internal/crawler/tangthuvien"
+	"demo-cosebase/internal/datastore"
+	"demo-cosebase/internal/datastore/redis_store"
+	"demo-cosebase/internal/models"
+	"errors"
+	"fmt"
+	"github.com/hiendaovinh/toolkit/pkg/errorx"
+	"github.com/redis/go-redis/v9"
+	"github.com/samber/do"
+	"github.com/uptrace/bun"
+	"slices"
+	"time"
+)
+
+const (
+	CrawlRunsDefaultLimit = 20
+	CrawlRunsMaxLimit     = 100
+)
+
+type ServiceCrawl struct {
+	container  *do.Injector
+	redisDB    redis.UniversalClient
+	postgresDB *bun.DB
+}
+
+func NewServiceCrawl(container *do.Injector) (*ServiceCrawl, error) {
+	db, err := do.InvokeNamed[redis.UniversalClient](container, "redis-db")
+	if err != nil {
+		return nil, err
+	}
+
+	postgresDB, err := do.Invoke[*bun.DB](container)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ServiceCrawl{container, db, postgresDB}, nil
+}
+
+func (service *ServiceCrawl) ListRuns(ctx context.Context, filter *datastore.CrawlRunFilter) ([]*models.CrawlRun, int, error) {
+	if filter.Limit <= 0 {
+		filter.Limit = CrawlRunsDefaultLimit
+	}
+	if filter.Limit > CrawlRunsMaxLimit {
+		filter.Limit = CrawlRunsMaxLimit
+	}
+	if filter.Offset < 0 {
+		filter.Offset = 0
+	}
+	return datastore.FindCrawlRuns(ctx, service.postgresDB, filter)
+}
+
+func (service *ServiceCrawl) FindRun(ctx context.Context, ID int64) (*models.CrawlRun, error) {
+	run, err := datastore.FindCrawlRunByID(ctx, service.postgresDB, ID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, errorx.Wrap(fmt.Errorf("crawl run %d not found", ID), errorx.NotExist)
+	}
+	if err != nil {
+		return nil, errorx.Wrap(err, errorx.Database)
+	}
+	return run, nil
+}
+
+// TriggerRun queues a crawl, it is run by the crawl daemon.
+func (service *ServiceCrawl) TriggerRun(ctx context.Context, req *models.CrawlRequest) (*models.CrawlRun, error) {
+	if req.Source != "" && !slices.Contains(crawler.Sources(), req.Source) {
+		return nil, errorx.Wrap(fmt.Errorf("unknown source %q", req.Source), errorx.Invalid)
+	}
+
+	job := &crawler.Job{
+		Command:  req.Command,
+		Source:   req.Source,
+		Rank:     req.Rank,
+		Pages:    req.Pages,
+		Chapters: req.Chapters,
+		Stories:  req.Stories,
+	}
+	if err := job.Validate(); err != nil {
+		return nil, errorx.Wrap(err, errorx.Invalid)
+	}
+
+	run, err := crawler.NewRun(job, models.CrawlTriggerAPI)
+	if err != nil {
+		return nil, errorx.Wrap(err, errorx.Service)
+	}
+	run.Status = models.CrawlRunQueued
+	run.QueuedAt = time.Now().Unix()
+	if _, err := datastore.CreateCrawlRun(ctx, service.postgresDB, run); err != nil {
+		return nil, errorx.Wrap(err, errorx.Database)
+	}
+
+	if err := redis_store.PushCrawlRun(ctx, service.redisDB, run.ID); err != nil {
+		run.Status = models.CrawlRunFailed
+		run.Error = err.Error()
+		//nolint:errcheck
+		datastore.UpdateCrawlRun(ctx, service.postgresDB, run)
+		return nil, errorx.Wrap(err, errorx.Service)
+	}
+	return run, nil
+}
@@ -0,0 +1,182 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"demo-cosebase/internal/datastore"
+	"demo-cosebase/internal/dedupe"
+	"demo-cosebase/internal/models"
+	"demo-cosebase/pkg/caching"
+	"errors"
+	"fmt"
+	"github.com/hiendaovinh/toolkit/pkg/errorx"
+	"github.com/samber/do"
+	"github.com/uptrace/bun"
+	"log"
+	"time"
+)
+
+const (
+	DuplicatesDefaultLimit = 20
+	DuplicatesMaxLimit     = 100
+)
+
+type ServiceDuplicate struct {
+	container  *do.Injector
+	postgresDB *bun.DB
+	cache      caching.Cache
+}
+
+func NewServiceDuplicate(container *do.Injector) (*ServiceDuplicate, error) {
+	postgresDB, err := do.Invoke[*bun.DB](container)
+	if err != nil {
+		return nil, err
+	}
+
+	cache, err := do.Invoke[caching.Cache](container)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ServiceDuplicate{container, postgresDB, cache}, nil
+}
+
+func (service *ServiceDuplicate) Scan(ctx context.Context, threshold float64) (int, error) {
+	if threshold <= 0 || threshold > 1 {
+		threshold = dedupe.DefaultThreshold
+	}
+	return dedupe.Scan(ctx, service.postgresDB, threshold)
+}
+
+func (service *ServiceDuplicate) List(ctx context.Context, status string, limit, offset int) ([]*models.StoryDuplicate, int, error) {
+	if limit <= 0 {
+		limit = DuplicatesDefaultLimit
+	}
+	if limit > DuplicatesMaxLimit {
+		limit = DuplicatesMaxLimit
+	}
+	if offset < 0 {
+		offset = 0
+	}
+	return datastore.FindStoryDuplicates(ctx, service.postgresDB, status, limit, offset)
+}
+
+func (service *ServiceDuplicate) Dismiss(ctx context.Context, ID int64, reviewer *models.User) (*models.StoryDuplicate, error) {
+	var duplicate *models.StoryDuplicate
+	err := service.postgresDB.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		var err error
+		duplicate, err = service.findPending(ctx, tx, ID)
+		if err != nil {
+			return err
+		}
+
+		duplicate.Status = models.DuplicateDismissed
+		duplicate.ReviewedAt = time.Now().Unix()
+		duplicate.ReviewedBy = reviewer.ID
+		if err := datastore.UpdateStoryDuplicate(ctx, tx, duplicate); err != nil {
+			return errorx.Wrap(err, errorx.Database)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return duplicate, nil
+}
+
+// Merge keeps one story of the pair and moves the chapters, sources, follows, categories
+// and comments of the other onto it. Without keepID the story with more chapters stays.
+// Stories with different statuses are not merged, the chapters of a hidden or taken down
+// story would become public, or the other way round.
+func (service *ServiceDuplicate) Merge(ctx context.Context, ID, keepID int64, reviewer *models.User) (*models.StoryDuplicate, error) {
+	var duplicate *models.StoryDuplicate
+	var keep, drop *models.Story
+	err := service.postgresDB.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		var err error
+		duplicate, err = service.findPending(ctx, tx, ID)
+		if err != nil {
+			return err
+		}
+
+		if keepID == 0 {
+			counts, err := datastore.CountStoryChapters(ctx, tx, duplicate.StoryID, duplicate.DuplicateID)
+			if err != nil {
+				return errorx.Wrap(err, errorx.Database)
+			}
+			keepID = duplicate.StoryID
+			if counts[duplicate.DuplicateID] > counts[duplicate.StoryID] {
+				keepID = duplicate.DuplicateID
+			}
+		}
+		dropID := duplicate.StoryID
+		switch keepID {
+		case duplicate.StoryID:
+			dropID = duplicate.DuplicateID
+		case duplicate.DuplicateID:
+		default:
+			return errorx.Wrap(fmt.Errorf("story %d is not part of duplicate %d", keepID, ID), errorx.Invalid)
+		}
+
+		keep, err = datastore.FindStoryByID(ctx, tx, keepID)
+		if err != nil {
+			return errorx.Wrap(err, errorx.Database)
+		}
+		drop, err = datastore.FindStoryByID(ctx, tx, dropID)
+		if err != nil {
+			return errorx.Wrap(err, errorx.Database)
+		}
+		if keep.Status != drop.Status {
+			return errorx.Wrap(fmt.Errorf("story %s is %s and story %s is %s, give them the same status before merging",
+				keep.Slug, keep.Status, drop.Slug, drop.Status), errorx.Invalid)
+		}
+
+		duplicate.Status = models.DuplicateMerged
+		duplicate.ReviewedAt = time.Now().Unix()
+		duplicate.ReviewedBy = reviewer.ID
+		if err := datastore.UpdateStoryDuplicate(ctx, tx, duplicate); err != nil {
+			return errorx.Wrap(err, errorx.Database)
+		}
+
+		keep.UpdatedAt = duplicate.ReviewedAt
+		change := &models.StoryStatusChange{
+			ActorID:   reviewer.ID,
+			Source:    "merge",
+			Reason:    fmt.Sprintf("merged story %d (%s), duplicate %d", drop.ID, drop.Slug, duplicate.ID),
+			CreatedAt: duplicate.ReviewedAt,
+		}
+		if err := datastore.MergeStories(ctx, tx, keep, drop, change); err != nil {
+			return errorx.Wrap(err, errorx.Database)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	InvalidateStory(ctx, service.cache, service.postgresDB, keep)
+	InvalidateStory(ctx, service.cache, service.postgresDB, drop)
+
+	// the lists of other stories leave drop out once it is gone, its own list is deleted
+	serviceRecommendation, err := do.Invoke[*ServiceRecommendation](service.container)
+	if err == nil {
+		err = serviceRecommendation.Forget(ctx, drop.Slug)
+	}
+	if err != nil {
+		log.Printf("duplicate: forget recommendations of %s: %v\n", drop.Slug, err)
+	}
+	return duplicate, nil
+}
+
+func (service *ServiceDuplicate) findPending(ctx context.Context, db bun.IDB, ID int64) (*models.StoryDuplicate, error) {
+	duplicate, err := datastore.FindStoryDuplicateForUpdate(ctx, db, ID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, errorx.Wrap(fmt.Errorf("duplicate %d not found", ID), errorx.NotExist)
+	}
+	if err != nil {
+		return nil, errorx.Wrap(err, errorx.Database)
+	}
+	if duplicate.Status != models.DuplicatePending {
+		return nil, errorx.Wrap(fmt.Errorf("duplicate %d is already %s", ID, duplicate.Status), errorx.Invalid)
+	}
+	return duplicate, nil
+}
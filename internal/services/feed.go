@@ -0,0 +1,288 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"demo-cosebase/internal/datastore"
+	"demo-cosebase/internal/models"
+	"demo-cosebase/pkg/atom"
+	"demo-cosebase/pkg/caching"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/hiendaovinh/toolkit/pkg/errorx"
+	"github.com/samber/do"
+	"github.com/uptrace/bun"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	FeedEntries       = 50
+	FeedExcerptLength = 500
+)
+
+// Feed readers poll, so they keep getting the last feed while it is rebuilt or the database is down.
+var feedCacheOptions = []caching.Option{
+	caching.StaleWhileRevalidate(CacheStaleWhileRevalidate),
+	caching.StaleIfError(CacheStaleIfError),
+	caching.NegativeTTL(CacheNegativeTtl),
+}
+
+// feedOptions tags a feed with tags, which the callback fills with what the feed shows.
+func feedOptions(tags *[]string) []caching.Option {
+	return append(feedCacheOptions[:len(feedCacheOptions):len(feedCacheOptions)], caching.TagsFunc(func() []string {
+		return *tags
+	}))
+}
+
+// chapterTags lists the story tags of chapters, once per story.
+func chapterTags(chapters []*models.Chapter) []string {
+	seen := map[int64]bool{}
+	tags := []string{}
+	for _, chapter := range chapters {
+		if !seen[chapter.StoryID] {
+			seen[chapter.StoryID] = true
+			tags = append(tags, TagStory(chapter.StoryID))
+		}
+	}
+	return tags
+}
+
+// RenderedFeed is a feed document ready to be served, it is what the cache keeps.
+type RenderedFeed struct {
+	Body    []byte
+	ETag    string
+	Updated int64
+}
+
+type ServiceFeed struct {
+	container     *do.Injector
+	postgresDB    *bun.DB
+	readonlyCache caching.ReadOnlyCache
+	cache         caching.Cache
+	siteURL       string
+}
+
+func NewServiceFeed(container *do.Injector) (*ServiceFeed, error) {
+	postgresDB, err := do.Invoke[*bun.DB](container)
+	if err != nil {
+		return nil, err
+	}
+
+	readonlyCache, err := do.Invoke[caching.ReadOnlyCache](container)
+	if err != nil {
+		return nil, err
+	}
+
+	cache, err := do.Invoke[caching.Cache](container)
+	if err != nil {
+		return nil, err
+	}
+
+	vs, err := do.InvokeNamed[map[string]string](container, "envs")
+	if err != nil {
+		return nil, err
+	}
+
+	return &ServiceFeed{container, postgresDB, readonlyCache, cache, strings.TrimRight(vs["SITE_URL"], "/")}, nil
+}
+
+func (service *ServiceFeed) StoryFeed(ctx context.Context, slug string) (*RenderedFeed, error) {
+	var tags []string
+	callback := func() (*RenderedFeed, error) {
+		ctx := context.WithoutCancel(ctx)
+		story, err := datastore.FindStoryBySlug(ctx, service.postgresDB, slug)
+		if errors.Is(err, sql.ErrNoRows) || (err == nil && !models.IsPublicStoryStatus(story.Status)) {
+			return nil, caching.ErrNotFound
+		}
+		if err != nil {
+			return nil, errorx.Wrap(err, errorx.Database)
+		}
+
+		chapters, err := datastore.FindRecentChapters(ctx, service.postgresDB, &datastore.RecentChaptersFilter{StoryID: story.ID}, FeedExcerptLength, FeedEntries)
+		if err != nil {
+			return nil, errorx.Wrap(err, errorx.Database)
+		}
+		tags = []string{TagStory(story.ID)}
+
+		feed := &atom.Feed{
+			ID:    fmt.Sprintf("urn:feed:story:%d", story.ID),
+			Title: story.Tittle,
+			Links: []atom.Link{
+				{Rel: "self", Type: atom.ContentType, Href: fmt.Sprintf("%s/feeds/stories/%s.atom", service.siteURL, story.Slug)},
+				{Rel: "alternate", Href: service.storyURL(story.Slug)},
+			},
+		}
+		if story.Author != "" {
+			feed.Author = &atom.Person{Name: story.Author}
+		}
+		return service.render(feed, chapters, time.Unix(story.UpdatedAt, 0))
+	}
+	feed, err := caching.UseCacheWithRO(ctx, service.readonlyCache, service.cache, DBKeyStoryFeed(slug), CacheTtl5Mins, callback, feedOptions(&tags)...)
+	if errors.Is(err, caching.ErrNotFound) {
+		return nil, errorx.Wrap(fmt.Errorf("story %s not found", slug), errorx.NotExist)
+	}
+	return feed, err
+}
+
+func (service *ServiceFeed) CategoryFeed(ctx context.Context, slug string) (*RenderedFeed, error) {
+	var tags []string
+	callback := func() (*RenderedFeed, error) {
+		ctx := context.WithoutCancel(ctx)
+		category, err := datastore.FindCategoryBySlug(ctx, service.postgresDB, slug)
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, caching.ErrNotFound
+		}
+		if err != nil {
+			return nil, errorx.Wrap(err, errorx.Database)
+		}
+
+		chapters, err := datastore.FindRecentChapters(ctx, service.postgresDB, &datastore.RecentChaptersFilter{CategoryID: category.ID}, FeedExcerptLength, FeedEntries)
+		if err != nil {
+			return nil, errorx.Wrap(err, errorx.Database)
+		}
+		tags = append(chapterTags(chapters), TagCategory(category.ID))
+
+		feed := &atom.Feed{
+			ID:    fmt.Sprintf("urn:feed:category:%d", category.ID),
+			Title: category.Name,
+			Links: []atom.Link{
+				{Rel: "self", Type: atom.ContentType, Href: fmt.Sprintf("%s/feeds/categories/%s.atom", service.siteURL, category.Slug)},
+			},
+		}
+		return service.render(feed, chapters, time.Time{})
+	}
+	feed, err := caching.UseCacheWithRO(ctx, service.readonlyCache, service.cache, DBKeyCategoryFeed(slug), CacheTtl5Mins, callback, feedOptions(&tags)...)
+	if errors.Is(err, caching.ErrNotFound) {
+		return nil, errorx.Wrap(fmt.Errorf("category %s not found", slug), errorx.NotExist)
+	}
+	return feed, err
+}
+
+// FollowedFeed is the private feed of the stories followed by the owner of token.
+func (service *ServiceFeed) FollowedFeed(ctx context.Context, token string) (*RenderedFeed, error) {
+	user, err := datastore.FindUserByFeedToken(ctx, service.postgresDB, hashFeedToken(token))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, errorx.Wrap(errors.New("feed not found"), errorx.NotExist)
+	}
+	if err != nil {
+		return nil, errorx.Wrap(err, errorx.Database)
+	}
+
+	var tags []string
+	callback := func() (*RenderedFeed, error) {
+		ctx := context.WithoutCancel(ctx)
+		chapters, err := datastore.FindRecentChapters(ctx, service.postgresDB, &datastore.RecentChaptersFilter{FollowerID: user.ID}, FeedExcerptLength, FeedEntries)
+		if err != nil {
+			return nil, errorx.Wrap(err, errorx.Database)
+		}
+		tags = append(chapterTags(chapters), TagUser(user.ID))
+
+		feed := &atom.Feed{
+			ID:    fmt.Sprintf("urn:feed:followed:%d", user.ID),
+			Title: "Truyện đang theo dõi",
+		}
+		return service.render(feed, chapters, time.Time{})
+	}
+	return caching.UseCacheWithRO(ctx, service.readonlyCache, service.cache, DBKeyFollowedFeed(user.ID), CacheTtl5Mins, callback, feedOptions(&tags)...)
+}
+
+// RotateFeedToken gives user a new private feed token, the previous one stops working.
+func (service *ServiceFeed) RotateFeedToken(ctx context.Context, user *models.User) (*models.FeedTokenResponse, error) {
+	random := make([]byte, 24)
+	if _, err := rand.Read(random); err != nil {
+		return nil, errorx.Wrap(err, errorx.Service)
+	}
+	token := hex.EncodeToString(random)
+
+	user.FeedToken = hashFeedToken(token)
+	if err := datastore.UpdateUserFeedToken(ctx, service.postgresDB, user); err != nil {
+		return nil, errorx.Wrap(err, errorx.Database)
+	}
+
+	return &models.FeedTokenResponse{
+		Token:   token,
+		FeedURL: fmt.Sprintf("%s/feeds/followed/%s.atom", service.siteURL, token),
+	}, nil
+}
+
+func (service *ServiceFeed) render(feed *atom.Feed, chapters []*models.Chapter, updated time.Time) (*RenderedFeed, error) {
+	for _, chapter := range chapters {
+		published := time.Unix(max(chapter.CreatedAt, chapter.PublishedAt), 0)
+		if published.After(updated) {
+			updated = published
+		}
+
+		title := chapter.Title
+		if title == "" {
+			title = fmt.Sprintf("Chương %d", chapter.Number)
+		}
+		entry := &atom.Entry{
+			ID:        fmt.Sprintf("urn:chapter:%d", chapter.ID),
+			Title:     title,
+			Published: atom.Time(published),
+			Updated:   atom.Time(time.Unix(chapter.UpdatedAt, 0)),
+			Summary:   &atom.Text{Type: "text", Body: excerpt(chapter.Content, FeedExcerptLength)},
+		}
+		if chapter.Story != nil {
+			if feed.Author == nil {
+				entry.Title = chapter.Story.Tittle + " - " + title
+			}
+			if chapter.Story.Author != "" {
+				entry.Author = &atom.Person{Name: chapter.Story.Author}
+			}
+			entry.Links = []atom.Link{{Rel: "alternate", Href: service.chapterURL(chapter.Story.Slug, chapter.Number)}}
+		}
+		feed.Entries = append(feed.Entries, entry)
+	}
+	if updated.IsZero() {
+		updated = time.Unix(0, 0)
+	}
+	feed.Updated = atom.Time(updated)
+	// Atom requires an author on the feed or on every entry
+	if feed.Author == nil {
+		feed.Author = &atom.Person{Name: "demo-cosebase"}
+	}
+
+	body, err := feed.Marshal()
+	if err != nil {
+		return nil, errorx.Wrap(err, errorx.Service)
+	}
+	sum := sha256.Sum256(body)
+	return &RenderedFeed{
+		Body:    body,
+		ETag:    `"` + hex.EncodeToString(sum[:16]) + `"`,
+		Updated: updated.Unix(),
+	}, nil
+}
+
+func (service *ServiceFeed) storyURL(slug string) string {
+	return fmt.Sprintf("%s/stories/%s", service.siteURL, slug)
+}
+
+func (service *ServiceFeed) chapterURL(slug string, number int) string {
+	return fmt.Sprintf("%s/stories/%s/chapters/%d", service.siteURL, slug, number)
+}
+
+func hashFeedToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// excerpt cuts text to about length characters on a word boundary.
+func excerpt(text string, length int) string {
+	text = strings.Join(strings.Fields(text), " ")
+	if utf8.RuneCountInString(text) <= length {
+		return text
+	}
+	runes := []rune(text)[:length]
+	cut := string(runes)
+	if i := strings.LastIndex(cut, " "); i > length/2 {
+		cut = cut[:i]
+	}
+	return cut + "…"
+}
@@ -0,0 +1,185 @@
+package services
+
+import (
+	"context"
+	"demo-cosebase/pkg"
+	"errors"
+	"github.com/redis/go-redis/v9"
+	"github.com/samber/do"
+	"github.com/uptrace/bun"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	HealthOK      = "ok"
+	HealthFailing = "failing"
+	// an optional dependency is failing, the instance still serves most requests
+	HealthDegraded = "degraded"
+)
+
+type HealthCheck struct {
+	Status  string  `json:"status"`
+	Latency float64 `json:"latency_ms"`
+	Error   string  `json:"error,omitempty"`
+}
+
+type HealthReport struct {
+	Status string                  `json:"status"`
+	Checks map[string]*HealthCheck `json:"checks,omitempty"`
+}
+
+/*
+ServiceHealth tells load balancers whether this instance should get traffic.
+
+Readiness checks Postgres and each named Redis client, a dependency that does not
+answer within HealthCheckTimeout fails the check. The mail transport is only
+reported: every replica shares it, so an outage of it must not take them all out
+of rotation, and it is checked at most every HealthMailInterval rather than on
+every probe. Once Drain is called readiness fails for good, so the instance stops
+receiving new requests while those in flight finish.
+*/
+type ServiceHealth struct {
+	container    *do.Injector
+	redisClients []string
+	draining     atomic.Bool
+	// resolved once the provider succeeded, until then every probe invokes it again
+	postgresDB atomic.Pointer[bun.DB]
+
+	mu            sync.Mutex
+	mail          *HealthCheck
+	mailCheckedAt time.Time
+}
+
+func NewServiceHealth(container *do.Injector, redisClients []string) (*ServiceHealth, error) {
+	return &ServiceHealth{container: container, redisClients: redisClients}, nil
+}
+
+// Drain makes readiness fail, it is called when the graceful shutdown starts.
+func (service *ServiceHealth) Drain() {
+	service.draining.Store(true)
+}
+
+func (service *ServiceHealth) Draining() bool {
+	return service.draining.Load()
+}
+
+// Ready runs every check concurrently, the report fails when a required one does and is
+// degraded when only the mail transport does.
+func (service *ServiceHealth) Ready(ctx context.Context) *HealthReport {
+	if service.Draining() {
+		return &HealthReport{Status: HealthFailing}
+	}
+
+	checks := map[string]func(ctx context.Context) error{
+		"postgres": service.pingPostgres,
+	}
+	for _, name := range service.redisClients {
+		checks[name] = func(ctx context.Context) error {
+			return service.pingRedis(ctx, name)
+		}
+	}
+
+	report := &HealthReport{Status: HealthOK, Checks: make(map[string]*HealthCheck, len(checks))}
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	var mail *HealthCheck
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		mail = service.checkMail(ctx)
+	}()
+	for name, check := range checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			result := runHealthCheck(ctx, check)
+
+			mu.Lock()
+			defer mu.Unlock()
+			report.Checks[name] = result
+			if result.Status != HealthOK {
+				report.Status = HealthFailing
+			}
+		}()
+	}
+	wg.Wait()
+	report.Checks["mail"] = mail
+	if mail.Status != HealthOK && report.Status == HealthOK {
+		report.Status = HealthDegraded
+	}
+
+	// the shutdown may have started while checking
+	if service.Draining() {
+		report.Status = HealthFailing
+	}
+	return report
+}
+
+func runHealthCheck(ctx context.Context, check func(ctx context.Context) error) *HealthCheck {
+	ctx, cancel := context.WithTimeout(ctx, HealthCheckTimeout)
+	defer cancel()
+
+	started := time.Now()
+	done := make(chan error, 1)
+	// connecting may not honor ctx, e.g. the first Postgres ping
+	go func() {
+		done <- check(ctx)
+	}()
+
+	var err error
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+
+	result := &HealthCheck{Status: HealthOK, Latency: float64(time.Since(started).Microseconds()) / 1000}
+	if errors.Is(err, context.DeadlineExceeded) {
+		err = errors.New("timed out")
+	}
+	if err != nil {
+		result.Status = HealthFailing
+		result.Error = err.Error()
+	}
+	return result
+}
+
+// checkMail returns the last result of the mail check, running it again once it is older
+// than HealthMailInterval. A failure degrades the report without failing it.
+func (service *ServiceHealth) checkMail(ctx context.Context) *HealthCheck {
+	service.mu.Lock()
+	defer service.mu.Unlock()
+
+	if service.mail == nil || time.Since(service.mailCheckedAt) >= HealthMailInterval {
+		service.mail = runHealthCheck(ctx, pkg.PingMail)
+		if service.mail.Status != HealthOK {
+			service.mail.Status = HealthDegraded
+		}
+		service.mailCheckedAt = time.Now()
+	}
+	result := *service.mail
+	return &result
+}
+
+func (service *ServiceHealth) pingPostgres(ctx context.Context) error {
+	postgresDB := service.postgresDB.Load()
+	if postgresDB == nil {
+		var err error
+		postgresDB, err = do.Invoke[*bun.DB](service.container)
+		if err != nil {
+			return err
+		}
+		service.postgresDB.Store(postgresDB)
+	}
+	return postgresDB.PingContext(ctx)
+}
+
+func (service *ServiceHealth) pingRedis(ctx context.Context, name string) error {
+	client, err := do.InvokeNamed[redis.UniversalClient](service.container, name)
+	if err != nil {
+		return err
+	}
+	return client.Ping(ctx).Err()
+}
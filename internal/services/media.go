@@ -0,0 +1,27 @@
+package services
+
+import (
+	"context"
+	"demo-cosebase/internal/media"
+	"demo-cosebase/pkg/storage"
+	"github.com/samber/do"
+	"io"
+)
+
+type ServiceMedia struct {
+	container *do.Injector
+	covers    *media.Covers
+}
+
+func NewServiceMedia(container *do.Injector) (*ServiceMedia, error) {
+	mediaStorage, err := do.Invoke[storage.Storage](container)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ServiceMedia{container, media.NewCovers(mediaStorage, nil)}, nil
+}
+
+func (service *ServiceMedia) OpenCover(ctx context.Context, hash, size string) (io.ReadCloser, *storage.ObjectInfo, error) {
+	return service.covers.Open(ctx, hash, size)
+}
@@ -0,0 +1,266 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"demo-cosebase/internal/datastore"
+	"demo-cosebase/internal/models"
+	"demo-cosebase/pkg/caching"
+	"errors"
+	"fmt"
+	"github.com/hiendaovinh/toolkit/pkg/errorx"
+	"github.com/samber/do"
+	"github.com/uptrace/bun"
+	"log"
+	"time"
+)
+
+const (
+	ReportsDefaultLimit = 20
+	ReportsMaxLimit     = 100
+)
+
+// ServiceModeration changes story statuses for admins and keeps the queue of reported
+// and DMCA flagged stories. Every status change is recorded with its author.
+type ServiceModeration struct {
+	container  *do.Injector
+	postgresDB *bun.DB
+	cache      caching.Cache
+}
+
+func NewServiceModeration(container *do.Injector) (*ServiceModeration, error) {
+	postgresDB, err := do.Invoke[*bun.DB](container)
+	if err != nil {
+		return nil, err
+	}
+
+	cache, err := do.Invoke[caching.Cache](container)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ServiceModeration{container, postgresDB, cache}, nil
+}
+
+// ChangeStatus moves a story to req.Status if the transition is allowed.
+func (service *ServiceModeration) ChangeStatus(ctx context.Context, actor *models.User, slug string, req *models.StoryStatusRequest) (*models.Story, error) {
+	var story *models.Story
+	err := service.postgresDB.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		var err error
+		story, err = service.findStory(ctx, tx, slug)
+		if err != nil {
+			return err
+		}
+		return service.transition(ctx, tx, story, req.Status, &models.StoryStatusChange{
+			ActorID: actor.ID,
+			Source:  "admin",
+			Reason:  req.Reason,
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	service.unpublish(ctx, story)
+	return story, nil
+}
+
+// History returns the status changes of a story, newest first.
+func (service *ServiceModeration) History(ctx context.Context, slug string) ([]*models.StoryStatusChange, error) {
+	story, err := service.findStory(ctx, service.postgresDB, slug)
+	if err != nil {
+		return nil, err
+	}
+
+	changes, err := datastore.FindStoryStatusChanges(ctx, service.postgresDB, story.ID)
+	if err != nil {
+		return nil, errorx.Wrap(err, errorx.Database)
+	}
+	return changes, nil
+}
+
+// Report queues a story reported by a reader, one open report per reader and story.
+func (service *ServiceModeration) Report(ctx context.Context, user *models.User, slug string, req *models.StoryReportRequest) (*models.StoryReport, error) {
+	story, err := service.findStory(ctx, service.postgresDB, slug)
+	if err != nil {
+		return nil, err
+	}
+	if !models.IsPublicStoryStatus(story.Status) {
+		return nil, errorx.Wrap(fmt.Errorf("story %s not found", slug), errorx.NotExist)
+	}
+
+	reported, err := datastore.HasOpenStoryReport(ctx, service.postgresDB, story.ID, user.ID)
+	if err != nil {
+		return nil, errorx.Wrap(err, errorx.Database)
+	}
+	if reported {
+		return nil, errorx.Wrap(errors.New("story already reported"), errorx.Invalid)
+	}
+
+	report, err := datastore.CreateStoryReport(ctx, service.postgresDB, &models.StoryReport{
+		StoryID:    story.ID,
+		Kind:       models.ReportAbuse,
+		ReporterID: user.ID,
+		Reason:     req.Reason,
+		Status:     models.ReportOpen,
+		CreatedAt:  time.Now().Unix(),
+	})
+	if err != nil {
+		return nil, errorx.Wrap(err, errorx.Database)
+	}
+	return report, nil
+}
+
+// FlagDMCA queues a DMCA notice received by an admin, the story stays visible until the
+// notice is resolved.
+func (service *ServiceModeration) FlagDMCA(ctx context.Context, admin *models.User, req *models.DMCAReportRequest) (*models.StoryReport, error) {
+	story, err := service.findStory(ctx, service.postgresDB, req.Slug)
+	if err != nil {
+		return nil, err
+	}
+
+	report, err := datastore.CreateStoryReport(ctx, service.postgresDB, &models.StoryReport{
+		StoryID:    story.ID,
+		Kind:       models.ReportDMCA,
+		ReporterID: admin.ID,
+		Reason:     req.Reason,
+		Contact:    req.Contact,
+		Status:     models.ReportOpen,
+		CreatedAt:  time.Now().Unix(),
+	})
+	if err != nil {
+		return nil, errorx.Wrap(err, errorx.Database)
+	}
+	report.Story = story
+	return report, nil
+}
+
+func (service *ServiceModeration) Queue(ctx context.Context, filter *datastore.StoryReportFilter) ([]*models.StoryReport, int, error) {
+	if filter.Limit <= 0 {
+		filter.Limit = ReportsDefaultLimit
+	}
+	if filter.Limit > ReportsMaxLimit {
+		filter.Limit = ReportsMaxLimit
+	}
+	if filter.Offset < 0 {
+		filter.Offset = 0
+	}
+
+	reports, total, err := datastore.FindStoryReports(ctx, service.postgresDB, filter)
+	if err != nil {
+		return nil, 0, errorx.Wrap(err, errorx.Database)
+	}
+	return reports, total, nil
+}
+
+// Resolve acts on an open report. Dismissing rejects it alone, hiding or taking down the
+// story resolves every open report of the story.
+func (service *ServiceModeration) Resolve(ctx context.Context, admin *models.User, ID int64, req *models.ModerationRequest) (*models.StoryReport, error) {
+	var report *models.StoryReport
+	err := service.postgresDB.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		var err error
+		report, err = datastore.FindStoryReportForUpdate(ctx, tx, ID)
+		if errors.Is(err, sql.ErrNoRows) {
+			return errorx.Wrap(fmt.Errorf("report %d not found", ID), errorx.NotExist)
+		}
+		if err != nil {
+			return errorx.Wrap(err, errorx.Database)
+		}
+		if report.Status != models.ReportOpen {
+			return errorx.Wrap(fmt.Errorf("report %d already %s", ID, report.Status), errorx.Invalid)
+		}
+
+		report.ResolverID = admin.ID
+		report.Resolution = req.Action
+		report.Note = req.Note
+		report.ResolvedAt = time.Now().Unix()
+
+		if req.Action == models.ModerationDismiss {
+			report.Status = models.ReportRejected
+			if _, err := datastore.UpdateStoryReport(ctx, tx, report); err != nil {
+				return errorx.Wrap(err, errorx.Database)
+			}
+			return nil
+		}
+
+		status := models.StoryHidden
+		if req.Action == models.ModerationTakeDown {
+			status = models.StoryTakenDown
+		}
+		if report.Story.Status != status {
+			reason := report.Reason
+			if req.Note != "" {
+				reason = req.Note
+			}
+			err := service.transition(ctx, tx, report.Story, status, &models.StoryStatusChange{
+				ActorID:  admin.ID,
+				Source:   "moderation",
+				Reason:   reason,
+				ReportID: report.ID,
+			})
+			if err != nil {
+				return err
+			}
+		}
+
+		report.Status = models.ReportResolved
+		if _, err := datastore.ResolveOpenStoryReports(ctx, tx, report); err != nil {
+			return errorx.Wrap(err, errorx.Database)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if report.Status == models.ReportResolved {
+		service.unpublish(ctx, report.Story)
+	}
+	return report, nil
+}
+
+// unpublish drops what is cached about a story after its status changed.
+func (service *ServiceModeration) unpublish(ctx context.Context, story *models.Story) {
+	InvalidateStory(ctx, service.cache, service.postgresDB, story)
+	if models.IsPublicStoryStatus(story.Status) {
+		return
+	}
+
+	serviceRecommendation, err := do.Invoke[*ServiceRecommendation](service.container)
+	if err == nil {
+		err = serviceRecommendation.Forget(ctx, story.Slug)
+	}
+	if err != nil {
+		log.Printf("moderation: forget recommendations of %s: %v\n", story.Slug, err)
+	}
+}
+
+func (service *ServiceModeration) findStory(ctx context.Context, db bun.IDB, slug string) (*models.Story, error) {
+	story, err := datastore.FindStoryBySlug(ctx, db, slug)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, errorx.Wrap(fmt.Errorf("story %s not found", slug), errorx.NotExist)
+	}
+	if err != nil {
+		return nil, errorx.Wrap(err, errorx.Database)
+	}
+	return story, nil
+}
+
+func (service *ServiceModeration) transition(ctx context.Context, tx bun.Tx, story *models.Story, to string, change *models.StoryStatusChange) error {
+	if story.Status == to {
+		return errorx.Wrap(fmt.Errorf("story is already %s", to), errorx.Invalid)
+	}
+	if !models.CanTransitionStory(story.Status, to) {
+		return errorx.Wrap(fmt.Errorf("story cannot go from %s to %s", story.Status, to), errorx.Invalid)
+	}
+
+	change.CreatedAt = time.Now().Unix()
+	err := datastore.ChangeStoryStatus(ctx, tx, story, to, change)
+	if errors.Is(err, datastore.ErrStoryStatusChanged) {
+		return errorx.Wrap(fmt.Errorf("story status changed meanwhile, it was %s", story.Status), errorx.Invalid)
+	}
+	if err != nil {
+		return errorx.Wrap(err, errorx.Database)
+	}
+	return nil
+}
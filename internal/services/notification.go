@@ -0,0 +1,58 @@
+package services
+
+import (
+	"context"
+	"demo-cosebase/internal/datastore"
+	"demo-cosebase/internal/models"
+	"github.com/hiendaovinh/toolkit/pkg/errorx"
+	"github.com/samber/do"
+	"github.com/uptrace/bun"
+	"time"
+)
+
+const (
+	NotificationsDefaultLimit = 20
+	NotificationsMaxLimit     = 100
+)
+
+type ServiceNotification struct {
+	container  *do.Injector
+	postgresDB *bun.DB
+}
+
+func NewServiceNotification(container *do.Injector) (*ServiceNotification, error) {
+	postgresDB, err := do.Invoke[*bun.DB](container)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ServiceNotification{container, postgresDB}, nil
+}
+
+// List returns the notifications of a user and the number of unread ones.
+func (service *ServiceNotification) List(ctx context.Context, userID int64, unreadOnly bool, limit, offset int) ([]*models.Notification, int, error) {
+	if limit <= 0 {
+		limit = NotificationsDefaultLimit
+	}
+	if limit > NotificationsMaxLimit {
+		limit = NotificationsMaxLimit
+	}
+	if offset < 0 {
+		offset = 0
+	}
+
+	notifications, unread, err := datastore.FindNotifications(ctx, service.postgresDB, userID, unreadOnly, limit, offset)
+	if err != nil {
+		return nil, 0, errorx.Wrap(err, errorx.Database)
+	}
+	return notifications, unread, nil
+}
+
+// MarkRead marks notifications as read, all the unread ones when IDs is empty.
+func (service *ServiceNotification) MarkRead(ctx context.Context, userID int64, IDs []int64) (int, error) {
+	marked, err := datastore.MarkNotificationsRead(ctx, service.postgresDB, userID, IDs, time.Now().Unix())
+	if err != nil {
+		return 0, errorx.Wrap(err, errorx.Database)
+	}
+	return marked, nil
+}
@@ -0,0 +1,449 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"demo-cosebase/internal/datastore"
+	"demo-cosebase/internal/models"
+	"demo-cosebase/pkg/caching"
+	"demo-cosebase/pkg/diff"
+	"errors"
+	"fmt"
+	"github.com/hiendaovinh/toolkit/pkg/errorx"
+	"github.com/samber/do"
+	"github.com/uptrace/bun"
+	"log"
+	"time"
+)
+
+const (
+	UploadedChaptersDefaultLimit = 20
+	UploadedChaptersMaxLimit     = 100
+
+	PublishSchedulerInterval = time.Minute
+)
+
+// ChapterDiff is what changed between two revisions of a chapter.
+type ChapterDiff struct {
+	ChapterID int64        `json:"chapter_id"`
+	From      int          `json:"from"`
+	To        int          `json:"to"`
+	Volume    []diff.Chunk `json:"volume,omitempty"`
+	Title     []diff.Chunk `json:"title,omitempty"`
+	Content   []diff.Chunk `json:"content"`
+	Stats     diff.Stats   `json:"stats"`
+}
+
+// ServicePublish is the publishing workflow of uploaded chapters: a chapter is created
+// as a draft, edited, optionally scheduled, then published. Every change of its text is
+// kept as a revision.
+type ServicePublish struct {
+	container  *do.Injector
+	postgresDB *bun.DB
+	cache      caching.Cache
+}
+
+func NewServicePublish(container *do.Injector) (*ServicePublish, error) {
+	postgresDB, err := do.Invoke[*bun.DB](container)
+	if err != nil {
+		return nil, err
+	}
+
+	cache, err := do.Invoke[caching.Cache](container)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ServicePublish{container, postgresDB, cache}, nil
+}
+
+// UploadSource is the chapter source of a user's uploads, so uploads never overwrite
+// crawled chapters nor the uploads of someone else.
+func UploadSource(userID int64) string {
+	return fmt.Sprintf("user:%d", userID)
+}
+
+func (service *ServicePublish) CreateDraft(ctx context.Context, user *models.User, slug string, req *models.ChapterDraftRequest) (*models.Chapter, error) {
+	story, err := datastore.FindStoryBySlug(ctx, service.postgresDB, slug)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, errorx.Wrap(fmt.Errorf("story %s not found", slug), errorx.NotExist)
+	}
+	if err != nil {
+		return nil, errorx.Wrap(err, errorx.Database)
+	}
+	if story.Status == models.StoryTakenDown {
+		return nil, errorx.Wrap(fmt.Errorf("story %s was taken down", slug), errorx.Invalid)
+	}
+
+	now := time.Now().Unix()
+	chapter := &models.Chapter{
+		StoryID:    story.ID,
+		Source:     UploadSource(user.ID),
+		Number:     req.Number,
+		Volume:     req.Volume,
+		Title:      req.Title,
+		Content:    req.Content,
+		Publisher:  user.Username,
+		Status:     models.ChapterDraft,
+		UploaderID: user.ID,
+		CreatedAt:  now,
+		UpdatedAt:  now,
+	}
+	err = service.postgresDB.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		existing, err := datastore.FindChaptersByNumbers(ctx, tx, story.ID, chapter.Source, []int{req.Number})
+		if err != nil {
+			return errorx.Wrap(err, errorx.Database)
+		}
+		if len(existing) > 0 {
+			return errorx.Wrap(fmt.Errorf("chapter %d already uploaded as %d", req.Number, existing[0].ID), errorx.Invalid)
+		}
+
+		if _, err := datastore.CreateChapter(ctx, tx, chapter); err != nil {
+			return errorx.Wrap(err, errorx.Database)
+		}
+		return service.createRevision(ctx, tx, chapter, user, "created")
+	})
+	if err != nil {
+		return nil, err
+	}
+	return chapter, nil
+}
+
+// Edit changes the fields set in req and records a revision, published chapters stay
+// published.
+func (service *ServicePublish) Edit(ctx context.Context, user *models.User, ID int64, req *models.ChapterEditRequest) (*models.Chapter, error) {
+	chapter, err := service.findOwnChapter(ctx, user, ID)
+	if err != nil {
+		return nil, err
+	}
+
+	changed := false
+	for field, value := range map[*string]*string{
+		&chapter.Volume:  req.Volume,
+		&chapter.Title:   req.Title,
+		&chapter.Content: req.Content,
+	} {
+		if value != nil && *value != *field {
+			*field = *value
+			changed = true
+		}
+	}
+	if !changed {
+		return chapter, nil
+	}
+
+	if err := service.save(ctx, user, chapter, "edited"); err != nil {
+		return nil, err
+	}
+	return chapter, nil
+}
+
+// Schedule publishes a draft at publishAt, a Unix time in the future. A scheduled
+// chapter can be scheduled again.
+func (service *ServicePublish) Schedule(ctx context.Context, user *models.User, ID int64, publishAt int64) (*models.Chapter, error) {
+	chapter, err := service.findOwnChapter(ctx, user, ID)
+	if err != nil {
+		return nil, err
+	}
+	if chapter.Status == models.ChapterPublished {
+		return nil, errorx.Wrap(errors.New("chapter already published"), errorx.Invalid)
+	}
+	now := time.Now().Unix()
+	if publishAt <= now {
+		return nil, errorx.Wrap(errors.New("publish time must be in the future"), errorx.Invalid)
+	}
+
+	// published meanwhile by the scheduler or another request
+	chapter, err = datastore.ScheduleChapter(ctx, service.postgresDB, chapter.ID, publishAt, now)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, errorx.Wrap(errors.New("chapter already published"), errorx.Invalid)
+	}
+	if err != nil {
+		return nil, errorx.Wrap(err, errorx.Database)
+	}
+	return chapter, nil
+}
+
+// Publish makes a draft or scheduled chapter visible and notifies the followers of its
+// story. Chapters of a story hidden from readers are not published, they can be
+// scheduled until the story is public again.
+func (service *ServicePublish) Publish(ctx context.Context, user *models.User, ID int64) (*models.Chapter, error) {
+	chapter, err := service.findOwnChapter(ctx, user, ID)
+	if err != nil {
+		return nil, err
+	}
+	if chapter.Status == models.ChapterPublished {
+		return nil, errorx.Wrap(errors.New("chapter already published"), errorx.Invalid)
+	}
+
+	now := time.Now().Unix()
+	err = service.postgresDB.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		story, err := datastore.FindStoryByID(ctx, tx, chapter.StoryID)
+		if err != nil {
+			return errorx.Wrap(err, errorx.Database)
+		}
+		if !models.IsPublicStoryStatus(story.Status) {
+			return errorx.Wrap(fmt.Errorf("story %s is %s, its chapters cannot be published", story.Slug, story.Status), errorx.Invalid)
+		}
+
+		// only the request that changes the status notifies the followers
+		chapter, err = datastore.PublishChapter(ctx, tx, chapter.ID, now)
+		if errors.Is(err, sql.ErrNoRows) {
+			return errorx.Wrap(errors.New("chapter already published"), errorx.Invalid)
+		}
+		if err != nil {
+			return errorx.Wrap(err, errorx.Database)
+		}
+		return service.notify(ctx, tx, story, chapter, now)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	service.invalidateFeeds(ctx, chapter.StoryID)
+	return chapter, nil
+}
+
+// PublishDue publishes the scheduled chapters whose time has come.
+func (service *ServicePublish) PublishDue(ctx context.Context) (int, error) {
+	now := time.Now().Unix()
+	var chapters []*models.Chapter
+	err := service.postgresDB.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		var err error
+		chapters, err = datastore.PublishDueChapters(ctx, tx, now)
+		if err != nil {
+			return errorx.Wrap(err, errorx.Database)
+		}
+		for _, chapter := range chapters {
+			story, err := datastore.FindStoryByID(ctx, tx, chapter.StoryID)
+			if err != nil {
+				return errorx.Wrap(err, errorx.Database)
+			}
+			if err := service.notify(ctx, tx, story, chapter, now); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	stories := map[int64]bool{}
+	for _, chapter := range chapters {
+		if !stories[chapter.StoryID] {
+			stories[chapter.StoryID] = true
+			service.invalidateFeeds(ctx, chapter.StoryID)
+		}
+	}
+	return len(chapters), nil
+}
+
+// RunScheduler publishes the due chapters every interval until ctx is done. Every API
+// instance runs it, PublishDue is safe to run concurrently.
+func (service *ServicePublish) RunScheduler(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		published, err := service.PublishDue(ctx)
+		if err != nil {
+			if ctx.Err() == nil {
+				log.Println("publish scheduler:", err)
+			}
+			continue
+		}
+		if published > 0 {
+			log.Printf("publish scheduler: %d chapters published\n", published)
+		}
+	}
+}
+
+func (service *ServicePublish) Revisions(ctx context.Context, user *models.User, ID int64) ([]*models.ChapterRevision, error) {
+	chapter, err := service.findOwnChapter(ctx, user, ID)
+	if err != nil {
+		return nil, err
+	}
+
+	revisions, err := datastore.FindChapterRevisions(ctx, service.postgresDB, chapter.ID)
+	if err != nil {
+		return nil, errorx.Wrap(err, errorx.Database)
+	}
+	return revisions, nil
+}
+
+// Diff compares revision to against, against defaults to the revision before it so the
+// diff shows what revision changed. The first revision is compared to an empty chapter.
+func (service *ServicePublish) Diff(ctx context.Context, user *models.User, ID int64, revision, against int) (*ChapterDiff, error) {
+	chapter, err := service.findOwnChapter(ctx, user, ID)
+	if err != nil {
+		return nil, err
+	}
+	if against == 0 {
+		against = revision - 1
+	}
+
+	to, err := service.findRevision(ctx, chapter.ID, revision)
+	if err != nil {
+		return nil, err
+	}
+	from := &models.ChapterRevision{}
+	if against > 0 {
+		from, err = service.findRevision(ctx, chapter.ID, against)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	result := &ChapterDiff{
+		ChapterID: chapter.ID,
+		From:      against,
+		To:        revision,
+		Content:   diff.Lines(from.Content, to.Content),
+	}
+	if from.Volume != to.Volume {
+		result.Volume = diff.Lines(from.Volume, to.Volume)
+	}
+	if from.Title != to.Title {
+		result.Title = diff.Lines(from.Title, to.Title)
+	}
+	result.Stats = diff.Count(result.Content)
+	return result, nil
+}
+
+// Revert restores the text of revision, as a new revision so the revert itself can be
+// reverted.
+func (service *ServicePublish) Revert(ctx context.Context, user *models.User, ID int64, revision int) (*models.Chapter, error) {
+	chapter, err := service.findOwnChapter(ctx, user, ID)
+	if err != nil {
+		return nil, err
+	}
+	rev, err := service.findRevision(ctx, chapter.ID, revision)
+	if err != nil {
+		return nil, err
+	}
+
+	chapter.Volume, chapter.Title, chapter.Content = rev.Volume, rev.Title, rev.Content
+	if err := service.save(ctx, user, chapter, fmt.Sprintf("reverted to revision %d", revision)); err != nil {
+		return nil, err
+	}
+	return chapter, nil
+}
+
+func (service *ServicePublish) ListUploads(ctx context.Context, user *models.User, status string, limit, offset int) ([]*models.Chapter, int, error) {
+	if limit <= 0 {
+		limit = UploadedChaptersDefaultLimit
+	}
+	if limit > UploadedChaptersMaxLimit {
+		limit = UploadedChaptersMaxLimit
+	}
+	if offset < 0 {
+		offset = 0
+	}
+
+	chapters, total, err := datastore.FindUploaderChapters(ctx, service.postgresDB, user.ID, status, limit, offset)
+	if err != nil {
+		return nil, 0, errorx.Wrap(err, errorx.Database)
+	}
+	return chapters, total, nil
+}
+
+// findOwnChapter returns a chapter the user may change: uploaders their own uploads,
+// admins any chapter.
+func (service *ServicePublish) findOwnChapter(ctx context.Context, user *models.User, ID int64) (*models.Chapter, error) {
+	chapter, err := datastore.FindChapterByID(ctx, service.postgresDB, ID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, errorx.Wrap(fmt.Errorf("chapter %d not found", ID), errorx.NotExist)
+	}
+	if err != nil {
+		return nil, errorx.Wrap(err, errorx.Database)
+	}
+
+	if user.Role != models.RoleAdmin && chapter.UploaderID != user.ID {
+		// a draft of someone else does not exist for the user
+		if chapter.Status != models.ChapterPublished {
+			return nil, errorx.Wrap(fmt.Errorf("chapter %d not found", ID), errorx.NotExist)
+		}
+		return nil, errorx.Wrap(errors.New("chapter uploaded by another user"), errorx.Authz)
+	}
+	return chapter, nil
+}
+
+func (service *ServicePublish) findRevision(ctx context.Context, chapterID int64, revision int) (*models.ChapterRevision, error) {
+	rev, err := datastore.FindChapterRevision(ctx, service.postgresDB, chapterID, revision)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, errorx.Wrap(fmt.Errorf("revision %d not found", revision), errorx.NotExist)
+	}
+	if err != nil {
+		return nil, errorx.Wrap(err, errorx.Database)
+	}
+	return rev, nil
+}
+
+// save stores the text of chapter and records it as a revision. The status is left to
+// Schedule and Publish, chapter is reloaded with the current one.
+func (service *ServicePublish) save(ctx context.Context, user *models.User, chapter *models.Chapter, note string) error {
+	chapter.UpdatedAt = time.Now().Unix()
+	err := service.postgresDB.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		if _, err := datastore.UpdateChapterText(ctx, tx, chapter); err != nil {
+			return errorx.Wrap(err, errorx.Database)
+		}
+		return service.createRevision(ctx, tx, chapter, user, note)
+	})
+	if err != nil {
+		return err
+	}
+
+	if chapter.Status == models.ChapterPublished {
+		service.invalidateFeeds(ctx, chapter.StoryID)
+	}
+	return nil
+}
+
+func (service *ServicePublish) createRevision(ctx context.Context, tx bun.Tx, chapter *models.Chapter, user *models.User, note string) error {
+	_, err := datastore.CreateChapterRevision(ctx, tx, &models.ChapterRevision{
+		ChapterID: chapter.ID,
+		Volume:    chapter.Volume,
+		Title:     chapter.Title,
+		Content:   chapter.Content,
+		EditorID:  user.ID,
+		Note:      note,
+		CreatedAt: chapter.UpdatedAt,
+	})
+	if err != nil {
+		return errorx.Wrap(err, errorx.Database)
+	}
+	return nil
+}
+
+// notify tells the followers of story about a new chapter, unless the story is hidden
+// from readers.
+func (service *ServicePublish) notify(ctx context.Context, tx bun.Tx, story *models.Story, chapter *models.Chapter, now int64) error {
+	if !models.IsPublicStoryStatus(story.Status) {
+		return nil
+	}
+
+	message := fmt.Sprintf("%s: chương %d", story.Tittle, chapter.Number)
+	if chapter.Title != "" {
+		message += " - " + chapter.Title
+	}
+	if _, err := datastore.NotifyFollowers(ctx, tx, chapter, message, now); err != nil {
+		return errorx.Wrap(err, errorx.Database)
+	}
+	return nil
+}
+
+// invalidateFeeds drops the cached feeds showing a story's chapters.
+func (service *ServicePublish) invalidateFeeds(ctx context.Context, storyID int64) {
+	story, err := datastore.FindStoryByID(ctx, service.postgresDB, storyID)
+	if err != nil {
+		log.Printf("publish: story %d: %v\n", storyID, err)
+		return
+	}
+	InvalidateStory(ctx, service.cache, service.postgresDB, story)
+}
@@ -0,0 +1,109 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"demo-cosebase/internal/datastore"
+	"demo-cosebase/internal/models"
+	"errors"
+	"fmt"
+	"github.com/hiendaovinh/toolkit/pkg/errorx"
+	"github.com/samber/do"
+	"github.com/uptrace/bun"
+	"time"
+)
+
+const (
+	HistoryDefaultLimit = 20
+	HistoryMaxLimit     = 100
+)
+
+// ServiceReading keeps what readers read and how they rate it, the recommendations are
+// built from it.
+type ServiceReading struct {
+	container  *do.Injector
+	postgresDB *bun.DB
+}
+
+func NewServiceReading(container *do.Injector) (*ServiceReading, error) {
+	postgresDB, err := do.Invoke[*bun.DB](container)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ServiceReading{container, postgresDB}, nil
+}
+
+func (service *ServiceReading) SaveProgress(ctx context.Context, user *models.User, slug string, chapter int) (*models.ReadingProgress, error) {
+	story, err := service.findStory(ctx, slug)
+	if err != nil {
+		return nil, err
+	}
+
+	progress, err := datastore.UpsertReadingProgress(ctx, service.postgresDB, &models.ReadingProgress{
+		UserID:        user.ID,
+		StoryID:       story.ID,
+		ChapterNumber: chapter,
+		MaxChapter:    chapter,
+		UpdatedAt:     time.Now().Unix(),
+	})
+	if err != nil {
+		return nil, errorx.Wrap(err, errorx.Database)
+	}
+	return progress, nil
+}
+
+// Rate stores the score of the user and returns the new rating of the story.
+func (service *ServiceReading) Rate(ctx context.Context, user *models.User, slug string, score int) (*models.RatingSummary, error) {
+	story, err := service.findStory(ctx, slug)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().Unix()
+	_, err = datastore.UpsertStoryRating(ctx, service.postgresDB, &models.StoryRating{
+		UserID:    user.ID,
+		StoryID:   story.ID,
+		Score:     score,
+		CreatedAt: now,
+		UpdatedAt: now,
+	})
+	if err != nil {
+		return nil, errorx.Wrap(err, errorx.Database)
+	}
+
+	summary, err := datastore.FindRatingSummary(ctx, service.postgresDB, story.ID)
+	if err != nil {
+		return nil, errorx.Wrap(err, errorx.Database)
+	}
+	return summary, nil
+}
+
+func (service *ServiceReading) History(ctx context.Context, user *models.User, limit, offset int) ([]*models.ReadingProgress, int, error) {
+	if limit <= 0 {
+		limit = HistoryDefaultLimit
+	}
+	if limit > HistoryMaxLimit {
+		limit = HistoryMaxLimit
+	}
+	if offset < 0 {
+		offset = 0
+	}
+
+	history, total, err := datastore.FindReadingHistory(ctx, service.postgresDB, user.ID, limit, offset)
+	if err != nil {
+		return nil, 0, errorx.Wrap(err, errorx.Database)
+	}
+	return history, total, nil
+}
+
+func (service *ServiceReading) findStory(ctx context.Context, slug string) (*models.Story, error) {
+	story, err := datastore.FindStoryBySlug(ctx, service.postgresDB, slug)
+	if errors.Is(err, sql.ErrNoRows) || (err == nil && !models.IsPublicStoryStatus(story.Status)) {
+		return nil, errorx.Wrap(fmt.Errorf("story %s not found", slug), errorx.NotExist)
+	}
+	if err != nil {
+		return nil, errorx.Wrap(err, errorx.Database)
+	}
+	return story, nil
+}
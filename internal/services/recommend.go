@@ -0,0 +1,86 @@
+package services
+
+import (
+	"context"
+	"demo-cosebase/internal/datastore"
+	"demo-cosebase/internal/datastore/redis_store"
+	"demo-cosebase/internal/models"
+	"github.com/hiendaovinh/toolkit/pkg/errorx"
+	"github.com/redis/go-redis/v9"
+	"github.com/samber/do"
+	"github.com/uptrace/bun"
+)
+
+// ServiceRecommendation serves the lists precomputed by the recommend job, every
+// request is a Redis read and a lookup of which listed stories are still public.
+type ServiceRecommendation struct {
+	container  *do.Injector
+	redisDB    redis.UniversalClient
+	postgresDB *bun.DB
+}
+
+func NewServiceRecommendation(container *do.Injector) (*ServiceRecommendation, error) {
+	db, err := do.InvokeNamed[redis.UniversalClient](container, "redis-db")
+	if err != nil {
+		return nil, err
+	}
+
+	postgresDB, err := do.Invoke[*bun.DB](container)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ServiceRecommendation{container, db, postgresDB}, nil
+}
+
+// ForUser returns the recommendations of a user, the popular stories when they have no
+// history yet.
+func (service *ServiceRecommendation) ForUser(ctx context.Context, userID int64) ([]*models.Recommendation, error) {
+	list, err := redis_store.FindUserRecommendations(ctx, service.redisDB, userID)
+	if err != nil {
+		return nil, errorx.Wrap(err, errorx.Database)
+	}
+	if list == nil {
+		list, err = redis_store.FindPopularRecommendations(ctx, service.redisDB)
+		if err != nil {
+			return nil, errorx.Wrap(err, errorx.Database)
+		}
+	}
+	return service.public(ctx, list)
+}
+
+// Similar returns the stories liked by the readers of a story, empty for unknown or
+// hidden stories.
+func (service *ServiceRecommendation) Similar(ctx context.Context, slug string) ([]*models.Recommendation, error) {
+	list, err := redis_store.FindSimilarStories(ctx, service.redisDB, slug)
+	if err != nil {
+		return nil, errorx.Wrap(err, errorx.Database)
+	}
+	return service.public(ctx, list)
+}
+
+// Forget drops the similar stories of a story leaving the public. Until the next job
+// rebuilds the lists it appears in, public filters it out of them.
+func (service *ServiceRecommendation) Forget(ctx context.Context, slug string) error {
+	return redis_store.DeleteSimilarStories(ctx, service.redisDB, slug)
+}
+
+// public leaves out the stories hidden or taken down since the lists were computed.
+func (service *ServiceRecommendation) public(ctx context.Context, list []*models.Recommendation) ([]*models.Recommendation, error) {
+	IDs := make([]int64, len(list))
+	for i, recommendation := range list {
+		IDs[i] = recommendation.StoryID
+	}
+	public, err := datastore.FindPublicStoryIDs(ctx, service.postgresDB, IDs)
+	if err != nil {
+		return nil, errorx.Wrap(err, errorx.Database)
+	}
+
+	visible := make([]*models.Recommendation, 0, len(list))
+	for _, recommendation := range list {
+		if public[recommendation.StoryID] {
+			visible = append(visible, recommendation)
+		}
+	}
+	return visible, nil
+}
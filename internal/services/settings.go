@@ -0,0 +1,77 @@
+package services
+
+import (
+	"context"
+	"demo-cosebase/internal/crawler"
+	"demo-cosebase/internal/datastore"
+	"demo-cosebase/internal/models"
+	"demo-cosebase/pkg/caching"
+	"fmt"
+	"github.com/hiendaovinh/toolkit/pkg/errorx"
+	"github.com/samber/do"
+	"github.com/uptrace/bun"
+	"log"
+	"slices"
+)
+
+type ServiceSettings struct {
+	container  *do.Injector
+	postgresDB *bun.DB
+	cache      caching.Cache
+}
+
+func NewServiceSettings(container *do.Injector) (*ServiceSettings, error) {
+	postgresDB, err := do.Invoke[*bun.DB](container)
+	if err != nil {
+		return nil, err
+	}
+
+	cache, err := do.Invoke[caching.Cache](container)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ServiceSettings{container, postgresDB, cache}, nil
+}
+
+// Settings returns the settings of user, the defaults when they never changed one.
+func (service *ServiceSettings) Settings(user *models.User) *models.UserSettings {
+	if user.Settings == nil {
+		return models.DefaultUserSettings()
+	}
+	return user.Settings
+}
+
+// Update applies patch to the stored settings. The user row is locked meanwhile so two
+// devices saving at once do not lose each other's changes.
+func (service *ServiceSettings) Update(ctx context.Context, user *models.User, patch *models.UserSettingsPatch) (*models.UserSettings, error) {
+	if patch.PreferredSource != nil && *patch.PreferredSource != "" && !slices.Contains(crawler.Sources(), *patch.PreferredSource) {
+		return nil, errorx.Wrap(fmt.Errorf("unknown source %q", *patch.PreferredSource), errorx.Invalid)
+	}
+
+	err := service.postgresDB.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		settings, err := datastore.FindUserSettingsForUpdate(ctx, tx, user.ID)
+		if err != nil {
+			return errorx.Wrap(err, errorx.Database)
+		}
+		if settings == nil {
+			settings = models.DefaultUserSettings()
+		}
+
+		patch.Apply(settings)
+		user.Settings = settings
+		if err := datastore.UpdateUserSettings(ctx, tx, user); err != nil {
+			return errorx.Wrap(err, errorx.Database)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// cached lookups of the user carry the previous settings
+	if err := service.cache.InvalidateTag(ctx, TagUser(user.ID)); err != nil {
+		log.Printf("settings: invalidate user %d: %v\n", user.ID, err)
+	}
+	return user.Settings, nil
+}
@@ -0,0 +1,112 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"demo-cosebase/internal/datastore"
+	"demo-cosebase/internal/export"
+	"demo-cosebase/internal/media"
+	"demo-cosebase/internal/models"
+	"demo-cosebase/pkg/caching"
+	"demo-cosebase/pkg/storage"
+	"errors"
+	"fmt"
+	"github.com/hiendaovinh/toolkit/pkg/errorx"
+	"github.com/samber/do"
+	"github.com/uptrace/bun"
+	"io"
+	"log"
+)
+
+type ServiceStory struct {
+	container     *do.Injector
+	postgresDB    *bun.DB
+	readonlyCache caching.ReadOnlyCache
+	cache         caching.Cache
+	covers        *media.Covers
+}
+
+func NewServiceStory(container *do.Injector) (*ServiceStory, error) {
+	postgresDB, err := do.Invoke[*bun.DB](container)
+	if err != nil {
+		return nil, err
+	}
+
+	readonlyCache, err := do.Invoke[caching.ReadOnlyCache](container)
+	if err != nil {
+		return nil, err
+	}
+
+	cache, err := do.Invoke[caching.Cache](container)
+	if err != nil {
+		return nil, err
+	}
+
+	mediaStorage, err := do.Invoke[storage.Storage](container)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ServiceStory{container, postgresDB, readonlyCache, cache, media.NewCovers(mediaStorage, nil)}, nil
+}
+
+// FindStoryBySlug returns a story visible to readers, hidden ones do not exist.
+func (service *ServiceStory) FindStoryBySlug(ctx context.Context, slug string) (*models.Story, error) {
+	var tags []string
+	callback := func() (*models.Story, error) {
+		story, err := datastore.FindStoryBySlug(ctx, service.postgresDB, slug)
+		if errors.Is(err, sql.ErrNoRows) || (err == nil && !models.IsPublicStoryStatus(story.Status)) {
+			return nil, caching.ErrNotFound
+		}
+		if err != nil {
+			return nil, errorx.Wrap(err, errorx.Database)
+		}
+		tags = []string{TagStory(story.ID)}
+		return story, nil
+	}
+	story, err := caching.UseCacheWithRO(ctx, service.readonlyCache, service.cache, DBKeyStory(slug), CacheTtl5Mins, callback,
+		caching.StaleIfError(CacheStaleIfError), caching.NegativeTTL(CacheNegativeTtl), caching.TagsFunc(func() []string { return tags }))
+	if errors.Is(err, caching.ErrNotFound) {
+		return nil, errorx.Wrap(fmt.Errorf("story %s not found", slug), errorx.NotExist)
+	}
+	return story, err
+}
+
+// ExportEPUB streams the chapters of r as an EPUB book to w.
+func (service *ServiceStory) ExportEPUB(ctx context.Context, w io.Writer, story *models.Story, r export.Range) error {
+	err := export.EPUB(ctx, w, service.postgresDB, service.covers, story, r)
+	if errors.Is(err, export.ErrNoChapters) {
+		return errorx.Wrap(err, errorx.NotExist)
+	}
+	return err
+}
+
+/*
+InvalidateStory drops everything cached about a story after it changed: its detail and
+feed, the category feeds of its categories and any feed showing its chapters.
+
+Followed feeds only include the story once their owner's feed is rebuilt, they catch up
+when they expire.
+*/
+func InvalidateStory(ctx context.Context, cache caching.Cache, db bun.IDB, story *models.Story) {
+	tags := []string{TagStory(story.ID)}
+	categories, err := datastore.FindStoryCategories(ctx, db, story.ID)
+	if err != nil {
+		log.Printf("story: categories of %s: %v\n", story.Slug, err)
+	}
+	for _, category := range categories {
+		tags = append(tags, TagCategory(category.ID))
+	}
+
+	for _, tag := range tags {
+		if err := cache.InvalidateTag(ctx, tag); err != nil {
+			log.Printf("story: invalidate %s: %v\n", tag, err)
+		}
+	}
+	// not-found answers carry no tag
+	for _, key := range []string{DBKeyStory(story.Slug), DBKeyStoryFeed(story.Slug)} {
+		if err := cache.Delete(ctx, key); err != nil {
+			log.Printf("story: invalidate %s: %v\n", key, err)
+		}
+	}
+}
@@ -69,11 +69,36 @@ func (service *ServiceUser) Authenticate(ctx context.Context, username string, p
 	return user, nil
 }
 
+// FindUserByUsername returns sql.ErrNoRows for unknown usernames, which are cached briefly too.
 func (service *ServiceUser) FindUserByUsername(ctx context.Context, username string) (*models.User, error) {
+	var tags []string
 	callback := func() (*models.User, error) {
-		return datastore.FindUserByUsername(ctx, service.postgresDB, username)
+		user, err := datastore.FindUserByUsername(ctx, service.postgresDB, username)
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, caching.ErrNotFound
+		}
+		if err == nil {
+			tags = []string{TagUser(user.ID)}
+		}
+		return user, err
+	}
+	user, err := caching.UseCacheWithRO(ctx, service.readonlyCache, service.cache, DBKeyUserByUsername(username), CacheTtl5Mins, callback,
+		caching.StaleIfError(CacheStaleIfError), caching.NegativeTTL(CacheNegativeTtl), caching.TagsFunc(func() []string { return tags }))
+	if errors.Is(err, caching.ErrNotFound) {
+		return nil, sql.ErrNoRows
+	}
+	return user, err
+}
+
+// forgetUsername drops the cached lookup of username, which may remember it as not found.
+func (service *ServiceUser) forgetUsername(ctx context.Context, username string) {
+	if err := service.cache.Delete(ctx, DBKeyUserByUsername(username)); err != nil {
+		fmt.Println(err)
 	}
-	return caching.UseCacheWithRO(ctx, service.readonlyCache, service.cache, DBKeyUserByUsername(username), CacheTtl5Mins, callback)
+}
+
+func (service *ServiceUser) FindUserByID(ctx context.Context, ID int64) (*models.User, error) {
+	return datastore.FindUserByID(ctx, service.postgresDB, ID)
 }
 
 func (service *ServiceUser) FindUserByEmail(ctx context.Context, email string) (*models.User, error) {
@@ -129,6 +154,7 @@ func (service *ServiceUser) CreateUser(ctx context.Context, email, username, pas
 	if err != nil {
 		return nil, err
 	}
+	service.forgetUsername(ctx, username)
 
 	return user, nil
 }
@@ -231,6 +257,7 @@ func (service *ServiceUser) FindOrCreateUserByEmail(ctx context.Context, userInf
 		if err != nil {
 			return nil, err
 		}
+		service.forgetUsername(ctx, newUser.Username)
 		return newUser, nil
 	}
 	return user, nil
@@ -0,0 +1,71 @@
+// Package atom writes Atom 1.0 feeds (RFC 4287).
+package atom
+
+import (
+	"bytes"
+	"encoding/xml"
+	"time"
+)
+
+const (
+	ContentType = "application/atom+xml; charset=utf-8"
+	namespace   = "http://www.w3.org/2005/Atom"
+)
+
+type Feed struct {
+	XMLName xml.Name `xml:"feed"`
+	Xmlns   string   `xml:"xmlns,attr"`
+	ID      string   `xml:"id"`
+	Title   string   `xml:"title"`
+	Updated Time     `xml:"updated"`
+	Links   []Link   `xml:"link"`
+	Author  *Person  `xml:"author,omitempty"`
+	Entries []*Entry `xml:"entry"`
+}
+
+type Entry struct {
+	ID        string  `xml:"id"`
+	Title     string  `xml:"title"`
+	Updated   Time    `xml:"updated"`
+	Published Time    `xml:"published"`
+	Links     []Link  `xml:"link"`
+	Author    *Person `xml:"author,omitempty"`
+	Summary   *Text   `xml:"summary,omitempty"`
+}
+
+type Link struct {
+	Rel  string `xml:"rel,attr,omitempty"`
+	Type string `xml:"type,attr,omitempty"`
+	Href string `xml:"href,attr"`
+}
+
+type Person struct {
+	Name string `xml:"name"`
+}
+
+type Text struct {
+	Type string `xml:"type,attr,omitempty"`
+	Body string `xml:",chardata"`
+}
+
+// Time is formatted as RFC 3339 in UTC.
+type Time time.Time
+
+func (t Time) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	return e.EncodeElement(time.Time(t).UTC().Format(time.RFC3339), start)
+}
+
+// Marshal returns the feed document with its XML declaration.
+func (f *Feed) Marshal() ([]byte, error) {
+	f.Xmlns = namespace
+
+	var b bytes.Buffer
+	b.WriteString(xml.Header)
+	encoder := xml.NewEncoder(&b)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(f); err != nil {
+		return nil, err
+	}
+	b.WriteString("\n")
+	return b.Bytes(), nil
+}
@@ -2,9 +2,6 @@ package caching
 
 import (
 	"context"
-	"errors"
-	"fmt"
-	"strings"
 	"time"
 
 	"github.com/go-redis/cache/v9"
@@ -17,65 +14,60 @@ type ReadOnlyCache interface {
 
 type Cache interface {
 	ReadOnlyCache
-	Set(ctx context.Context, key string, value any, ttl time.Duration) error
+	// Set stores value under key and records key under each of tags.
+	Set(ctx context.Context, key string, value any, ttl time.Duration, tags ...string) error
 	Delete(ctx context.Context, key string) error
+	// InvalidateTag deletes every key recorded under tag.
+	InvalidateTag(ctx context.Context, tag string) error
 }
 
-func UseCache[T any](ctx context.Context, cash Cache, key string, ttl time.Duration, callback func() (T, error)) (T, error) {
-	var v T
-	err := cash.Get(ctx, key, &v)
-	if !errors.Is(err, cache.ErrCacheMiss) {
-		return v, err
-	}
-
-	v, err = callback()
-	if err != nil {
-		return v, err
-	}
+/*
+Returns the cached value for key, calling callback to fill it on a miss.
 
-	// fire and forget
-	//nolint:errcheck
-	cash.Set(ctx, key, v, ttl)
-	return v, nil
+Concurrent misses for the same key are coalesced, so only one callback runs per
+process (and per cluster when cash implements Locker). Hot keys are refreshed
+shortly before they expire, see EarlyExpirationBeta. By default nothing is
+served past ttl, see StaleWhileRevalidate and StaleIfError.
+*/
+func UseCache[T any](ctx context.Context, cash Cache, key string, ttl time.Duration, callback func() (T, error), opts ...Option) (T, error) {
+	return useCache(ctx, cash, cash, key, ttl, callback, opts)
 }
 
-func UseCacheWithRO[T any](ctx context.Context, roCash ReadOnlyCache, cash Cache, key string, ttl time.Duration, callback func() (T, error)) (T, error) {
-	var v T
-	err := roCash.Get(ctx, key, &v)
-	if !errors.Is(err, cache.ErrCacheMiss) {
-		return v, err
-	}
-
-	v, err = callback()
-	if err != nil {
-		return v, err
-	}
-
-	// fire and forget
-	//nolint:errcheck
-	cash.Set(ctx, key, v, ttl)
-	return v, nil
+// Same as UseCache, but reads through roCash (typically a replica) and writes to cash.
+func UseCacheWithRO[T any](ctx context.Context, roCash ReadOnlyCache, cash Cache, key string, ttl time.Duration, callback func() (T, error), opts ...Option) (T, error) {
+	return useCache(ctx, roCash, cash, key, ttl, callback, opts)
 }
 
 type CacheRedis struct {
 	instance *cache.Cache
+	client   redis.UniversalClient
+	local    bool
+	lock     *lockOptions
+	sync     *localSync
 }
 
 func (c *CacheRedis) Get(ctx context.Context, key string, target any) error {
 	return c.instance.Get(ctx, key, target)
 }
 
-func (c *CacheRedis) Set(ctx context.Context, key string, value any, ttl time.Duration) error {
-	return c.instance.Set(&cache.Item{
+func (c *CacheRedis) Set(ctx context.Context, key string, value any, ttl time.Duration, tags ...string) error {
+	err := c.instance.Set(&cache.Item{
 		Ctx:   ctx,
 		Key:   key,
 		Value: value,
 		TTL:   ttl,
 	})
+	if err != nil {
+		return err
+	}
+	c.publish(ctx, key)
+	return c.tag(ctx, key, ttl, tags)
 }
 
 func (c *CacheRedis) Delete(ctx context.Context, key string) error {
-	return c.instance.Delete(ctx, key)
+	err := c.instance.Delete(ctx, key)
+	c.publish(ctx, key)
+	return err
 }
 
 func NewCacheRedis(client redis.UniversalClient, withLocalCache bool) (*CacheRedis, error) {
@@ -83,55 +75,22 @@ func NewCacheRedis(client redis.UniversalClient, withLocalCache bool) (*CacheRed
 	if withLocalCache {
 		localCache = cache.NewTinyLFU(10000, time.Minute)
 	}
-	return &CacheRedis{cache.New(&cache.Options{
-		Redis:      client,
-		LocalCache: localCache,
-	})}, nil
-}
-
-type RedisClient interface {
-	Keys(ctx context.Context, pattern string) *redis.StringSliceCmd
-	Del(ctx context.Context, keys ...string) *redis.IntCmd
-	Scan(ctx context.Context, cursor uint64, match string, count int64) *redis.ScanCmd
+	return &CacheRedis{
+		instance: cache.New(&cache.Options{
+			Redis:      client,
+			LocalCache: localCache,
+		}),
+		client: client,
+		local:  withLocalCache,
+	}, nil
 }
 
 /*
 Deletes all keys in redis that match the pattern.
 
-It uses SCAN to iterate over the keys and delete them in batches of 100.
-
-Wildcard (*) is supported in the pattern, but it should be used carefully, as it can be exstreamly slow on production with large datasets.
+It is Purge without options, see there for how the keys are found and deleted.
 */
 func DeleteKeys(ctx context.Context, client redis.UniversalClient, pattern string) error {
-	clusterClient, ok := client.(*redis.ClusterClient)
-	if ok {
-		_ = clusterClient.ForEachMaster(ctx, func(ctx context.Context, c *redis.Client) error {
-			deleteKeys(ctx, c, pattern)
-			return nil
-		})
-	} else {
-		deleteKeys(ctx, client, pattern)
-	}
-
-	return nil
-}
-
-func deleteKeys(ctx context.Context, client RedisClient, pattern string) {
-	if !strings.Contains(pattern, "*") {
-		err := client.Del(ctx, pattern).Err()
-		fmt.Println("Deleted key", pattern, err)
-		return
-	}
-
-	cursor := uint64(0)
-	for {
-		keys, cursor, err := client.Scan(ctx, cursor, pattern, 10000).Result()
-		for _, key := range keys {
-			err := client.Del(ctx, key).Err()
-			fmt.Println("Deleted key", key, err)
-		}
-		if cursor <= 0 || err != nil {
-			break
-		}
-	}
+	_, err := Purge(ctx, client, &PurgeOptions{Pattern: pattern})
+	return err
 }
@@ -0,0 +1,345 @@
+/*
+Package cachetest is the conformance suite of caching.Cache implementations.
+
+Every implementation must pass Run, so code written against one behaves the same
+on the others:
+
+	func TestCacheMemory(t *testing.T) {
+		cachetest.Run(t, func(t *testing.T) caching.Cache {
+			return caching.NewCacheMemory(0, caching.EvictLRU)
+		})
+	}
+
+Implementations sharing a backend between calls of newCache, like CacheRedis on
+a test Redis, should hand out a flushed one.
+*/
+package cachetest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"demo-cosebase/pkg/caching"
+
+	"github.com/go-redis/cache/v9"
+)
+
+type record struct {
+	ID      int64
+	Name    string
+	Tags    []string
+	Meta    map[string]int
+	Child   *record
+	Created time.Time
+}
+
+// Run checks c against the contract of caching.Cache and the UseCache helpers.
+func Run(t *testing.T, newCache func(t *testing.T) caching.Cache) {
+	tests := []struct {
+		name string
+		run  func(t *testing.T, c caching.Cache)
+	}{
+		{"Miss", testMiss},
+		{"RoundTrip", testRoundTrip},
+		{"Overwrite", testOverwrite},
+		{"Delete", testDelete},
+		{"Expiry", testExpiry},
+		{"InvalidateTag", testInvalidateTag},
+		{"UseCacheCoalesces", testUseCacheCoalesces},
+		{"UseCacheNotFound", testUseCacheNotFound},
+		{"UseCacheError", testUseCacheError},
+		{"UseCacheLegacyValue", testUseCacheLegacyValue},
+		{"UseCacheCallerCancels", testUseCacheCallerCancels},
+		{"UseCacheTypes", testUseCacheTypes},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			test.run(t, newCache(t))
+		})
+	}
+}
+
+func testMiss(t *testing.T, c caching.Cache) {
+	var v string
+	if err := c.Get(context.Background(), "cachetest:missing", &v); !errors.Is(err, cache.ErrCacheMiss) {
+		t.Fatalf("Get of a missing key: got %v, want cache.ErrCacheMiss", err)
+	}
+}
+
+func testRoundTrip(t *testing.T, c caching.Cache) {
+	ctx := context.Background()
+	want := &record{
+		ID:      42,
+		Name:    "Đấu Phá Thương Khung",
+		Tags:    []string{"a", "b"},
+		Meta:    map[string]int{"chapters": 1648},
+		Child:   &record{ID: 7},
+		Created: time.Unix(1700000000, 0),
+	}
+	if err := c.Set(ctx, "cachetest:record", want, time.Minute); err != nil {
+		t.Fatal(err)
+	}
+
+	var got record
+	if err := c.Get(ctx, "cachetest:record", &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.ID != want.ID || got.Name != want.Name || len(got.Tags) != 2 || got.Meta["chapters"] != 1648 ||
+		got.Child == nil || got.Child.ID != 7 || !got.Created.Equal(want.Created) {
+		t.Fatalf("round trip: got %+v, want %+v", got, want)
+	}
+
+	// go-redis/cache stores strings and bytes as they are
+	if err := c.Set(ctx, "cachetest:bytes", []byte("raw"), time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	var s string
+	if err := c.Get(ctx, "cachetest:bytes", &s); err != nil || s != "raw" {
+		t.Fatalf("bytes read as string: got %q, %v", s, err)
+	}
+}
+
+func testOverwrite(t *testing.T, c caching.Cache) {
+	ctx := context.Background()
+	for _, v := range []int{1, 2} {
+		if err := c.Set(ctx, "cachetest:overwrite", v, time.Minute); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var got int
+	if err := c.Get(ctx, "cachetest:overwrite", &got); err != nil || got != 2 {
+		t.Fatalf("after overwrite: got %d, %v, want 2", got, err)
+	}
+}
+
+func testDelete(t *testing.T, c caching.Cache) {
+	ctx := context.Background()
+	if err := c.Set(ctx, "cachetest:delete", "v", time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Delete(ctx, "cachetest:delete"); err != nil {
+		t.Fatal(err)
+	}
+	var v string
+	if err := c.Get(ctx, "cachetest:delete", &v); !errors.Is(err, cache.ErrCacheMiss) {
+		t.Fatalf("Get after Delete: got %v, want cache.ErrCacheMiss", err)
+	}
+}
+
+func testExpiry(t *testing.T, c caching.Cache) {
+	ctx := context.Background()
+	// one second is the shortest ttl go-redis/cache accepts
+	if err := c.Set(ctx, "cachetest:expiry", "v", time.Second); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(1100 * time.Millisecond)
+	var v string
+	if err := c.Get(ctx, "cachetest:expiry", &v); !errors.Is(err, cache.ErrCacheMiss) {
+		t.Fatalf("Get after ttl: got %v, want cache.ErrCacheMiss", err)
+	}
+}
+
+func testInvalidateTag(t *testing.T, c caching.Cache) {
+	ctx := context.Background()
+	sets := map[string][]string{
+		"cachetest:tag:a": {"cachetest:story:1"},
+		"cachetest:tag:b": {"cachetest:story:1", "cachetest:category:1"},
+		"cachetest:tag:c": {"cachetest:category:1"},
+		"cachetest:tag:d": nil,
+	}
+	for key, tags := range sets {
+		if err := c.Set(ctx, key, key, time.Minute, tags...); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := c.InvalidateTag(ctx, "cachetest:story:1"); err != nil {
+		t.Fatal(err)
+	}
+	// a tag nothing was stored under is not an error
+	if err := c.InvalidateTag(ctx, "cachetest:story:2"); err != nil {
+		t.Fatal(err)
+	}
+
+	for key, gone := range map[string]bool{"cachetest:tag:a": true, "cachetest:tag:b": true, "cachetest:tag:c": false, "cachetest:tag:d": false} {
+		var v string
+		err := c.Get(ctx, key, &v)
+		if gone && !errors.Is(err, cache.ErrCacheMiss) {
+			t.Errorf("%s: got %v, want it invalidated", key, err)
+		}
+		if !gone && err != nil {
+			t.Errorf("%s: got %v, want it kept", key, err)
+		}
+	}
+}
+
+func testUseCacheCoalesces(t *testing.T, c caching.Cache) {
+	ctx := context.Background()
+	var calls atomic.Int32
+	release := make(chan struct{})
+	callback := func() (*record, error) {
+		calls.Add(1)
+		<-release
+		return &record{ID: 1}, nil
+	}
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 20)
+	for range 20 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			v, err := caching.UseCache(ctx, c, "cachetest:coalesce", time.Minute, callback)
+			if err == nil && v.ID != 1 {
+				err = fmt.Errorf("got %+v", v)
+			}
+			errs <- err
+		}()
+	}
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n := calls.Load(); n != 1 {
+		t.Fatalf("callback ran %d times for concurrent misses, want 1", n)
+	}
+}
+
+func testUseCacheNotFound(t *testing.T, c caching.Cache) {
+	ctx := context.Background()
+	calls := 0
+	callback := func() (*record, error) {
+		calls++
+		return nil, fmt.Errorf("record 1: %w", caching.ErrNotFound)
+	}
+	for range 2 {
+		if _, err := caching.UseCache(ctx, c, "cachetest:not-found", time.Minute, callback); !errors.Is(err, caching.ErrNotFound) {
+			t.Fatalf("got %v, want caching.ErrNotFound", err)
+		}
+	}
+	if calls != 1 {
+		t.Fatalf("callback ran %d times, want the not-found answer cached", calls)
+	}
+}
+
+func testUseCacheError(t *testing.T, c caching.Cache) {
+	ctx := context.Background()
+	failure := errors.New("database down")
+	calls := 0
+	callback := func() (int, error) {
+		calls++
+		return 0, failure
+	}
+	for range 2 {
+		if _, err := caching.UseCache(ctx, c, "cachetest:error", time.Minute, callback); !errors.Is(err, failure) {
+			t.Fatalf("got %v, want the callback error", err)
+		}
+	}
+	if calls != 2 {
+		t.Fatalf("callback ran %d times, errors must not be cached", calls)
+	}
+}
+
+// A value cached without the UseCache envelope decodes into a zero envelope and must be reloaded.
+func testUseCacheLegacyValue(t *testing.T, c caching.Cache) {
+	ctx := context.Background()
+	if err := c.Set(ctx, "cachetest:legacy", &record{ID: 5, Name: "legacy"}, time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	v, err := caching.UseCache(ctx, c, "cachetest:legacy", time.Minute, func() (*record, error) {
+		return &record{ID: 9}, nil
+	})
+	if err != nil || v == nil || v.ID != 9 {
+		t.Fatalf("got %+v, %v, want the value reloaded", v, err)
+	}
+}
+
+// The caller that started a load cancelling must not fail the others waiting for it.
+func testUseCacheCallerCancels(t *testing.T, c caching.Cache) {
+	release := make(chan struct{})
+	started := make(chan struct{})
+	callback := func() (*record, error) {
+		close(started)
+		<-release
+		return &record{ID: 1}, nil
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	first := make(chan error, 1)
+	go func() {
+		_, err := caching.UseCache(ctx, c, "cachetest:cancel", time.Minute, callback)
+		first <- err
+	}()
+	<-started
+
+	second := make(chan error, 1)
+	go func() {
+		v, err := caching.UseCache(context.Background(), c, "cachetest:cancel", time.Minute, callback)
+		if err == nil && v.ID != 1 {
+			err = fmt.Errorf("got %+v", v)
+		}
+		second <- err
+	}()
+	time.Sleep(20 * time.Millisecond)
+
+	cancel()
+	if err := <-first; !errors.Is(err, context.Canceled) {
+		t.Fatalf("cancelled caller: got %v, want context.Canceled", err)
+	}
+	close(release)
+	if err := <-second; err != nil {
+		t.Fatalf("other caller: %v", err)
+	}
+}
+
+// Call sites reading the same key into different types must not share a load.
+func testUseCacheTypes(t *testing.T, c caching.Cache) {
+	ctx := context.Background()
+	release := make(chan struct{})
+	started := make(chan struct{})
+
+	first := make(chan error, 1)
+	go func() {
+		v, err := caching.UseCache(ctx, c, "cachetest:types", time.Minute, func() (int, error) {
+			close(started)
+			<-release
+			return 1, nil
+		})
+		if err == nil && v != 1 {
+			err = fmt.Errorf("got %v, want 1", v)
+		}
+		first <- err
+	}()
+	<-started
+
+	second := make(chan error, 1)
+	go func() {
+		v, err := caching.UseCache(ctx, c, "cachetest:types", time.Minute, func() (string, error) {
+			return "one", nil
+		})
+		if err == nil && v != "one" {
+			err = fmt.Errorf("got %q, want one", v)
+		}
+		second <- err
+	}()
+	time.Sleep(20 * time.Millisecond)
+	close(release)
+
+	if err := <-first; err != nil {
+		t.Fatalf("int caller: %v", err)
+	}
+	if err := <-second; err != nil {
+		t.Fatalf("string caller: %v", err)
+	}
+}
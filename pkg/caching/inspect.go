@@ -0,0 +1,74 @@
+package caching
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/go-redis/cache/v9"
+	"github.com/redis/go-redis/v9"
+)
+
+// KeyInfo describes a stored key, TTL is -1 for keys that do not expire. Size is in bytes,
+// the length of a string and the memory Redis reports for the other types, e.g. the tag
+// sets.
+type KeyInfo struct {
+	Key  string  `json:"key"`
+	Type string  `json:"type"`
+	TTL  float64 `json:"ttl_seconds"`
+	Size int64   `json:"size"`
+}
+
+// Inspector is implemented by caches that can describe a key, it returns cache.ErrCacheMiss for missing ones.
+type Inspector interface {
+	Inspect(ctx context.Context, key string) (*KeyInfo, error)
+}
+
+func (c *CacheRedis) Inspect(ctx context.Context, key string) (*KeyInfo, error) {
+	pipe := c.client.Pipeline()
+	ttl := pipe.PTTL(ctx, key)
+	kind := pipe.Type(ctx, key)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return nil, err
+	}
+	if kind.Val() == "none" {
+		return nil, cache.ErrCacheMiss
+	}
+
+	// STRLEN fails with WRONGTYPE on the other types
+	var size *redis.IntCmd
+	if kind.Val() == "string" {
+		size = c.client.StrLen(ctx, key)
+	} else {
+		size = c.client.MemoryUsage(ctx, key)
+	}
+	if err := size.Err(); err != nil && !errors.Is(err, redis.Nil) {
+		return nil, err
+	}
+
+	info := &KeyInfo{Key: key, Type: kind.Val(), TTL: ttl.Val().Seconds(), Size: size.Val()}
+	// go-redis passes -2 (missing key) and -1 (no expire) through as they are
+	switch ttl.Val() {
+	case -2:
+		// expired between the commands
+		return nil, cache.ErrCacheMiss
+	case -1:
+		info.TTL = -1
+	}
+	return info, nil
+}
+
+func (c *CacheMemory) Inspect(ctx context.Context, key string) (*KeyInfo, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	item, ok := c.items[key]
+	if !ok || item.expired(time.Now()) {
+		return nil, cache.ErrCacheMiss
+	}
+	ttl := -1.0
+	if !item.expires.IsZero() {
+		ttl = time.Until(item.expires).Seconds()
+	}
+	return &KeyInfo{Key: key, Type: "string", TTL: ttl, Size: int64(len(item.value))}, nil
+}
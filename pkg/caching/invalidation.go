@@ -0,0 +1,83 @@
+package caching
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"log"
+	"strings"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// DefaultInvalidationChannel is the pub/sub channel caches announce their writes on.
+const DefaultInvalidationChannel = "cache:invalidate"
+
+/*
+Keeps local caches in sync across instances.
+
+Every write through a synced CacheRedis is published on the channel, and caches
+holding a local copy evict the keys written by the others. Messages are the
+writer id then the keys, one per line.
+*/
+type localSync struct {
+	id      string
+	channel string
+	pubsub  *redis.PubSub
+}
+
+/*
+Announces writes on channel and, with a local cache, evicts what other instances write.
+
+Every cache sharing the Redis must be synced, including those without a local
+cache, otherwise their writes leave stale local copies behind. Messages missed
+while the subscription reconnects are not replayed, the local ttl bounds how long
+such a copy survives.
+*/
+func (c *CacheRedis) SyncLocal(channel string) (*CacheRedis, error) {
+	if channel == "" {
+		channel = DefaultInvalidationChannel
+	}
+	buf := make([]byte, 8)
+	if _, err := rand.Read(buf); err != nil {
+		return nil, err
+	}
+	c.sync = &localSync{id: hex.EncodeToString(buf), channel: channel}
+
+	if c.local {
+		c.sync.pubsub = c.client.Subscribe(context.Background(), channel)
+		go c.evictRemote(c.sync.pubsub.Channel())
+	}
+	return c, nil
+}
+
+func (c *CacheRedis) evictRemote(messages <-chan *redis.Message) {
+	for message := range messages {
+		id, keys, _ := strings.Cut(message.Payload, "\n")
+		if id == c.sync.id {
+			continue
+		}
+		for _, key := range strings.Split(keys, "\n") {
+			c.instance.DeleteFromLocalCache(key)
+		}
+	}
+}
+
+// publish tells the other instances keys changed, failures only leave local copies until their ttl.
+func (c *CacheRedis) publish(ctx context.Context, keys ...string) {
+	if c.sync == nil || len(keys) == 0 {
+		return
+	}
+	payload := c.sync.id + "\n" + strings.Join(keys, "\n")
+	if err := c.client.Publish(ctx, c.sync.channel, payload).Err(); err != nil {
+		log.Printf("caching: publish invalidation: %v\n", err)
+	}
+}
+
+// Shutdown stops listening for invalidations, it is called by do.Injector.Shutdown.
+func (c *CacheRedis) Shutdown() error {
+	if c.sync == nil || c.sync.pubsub == nil {
+		return nil
+	}
+	return c.sync.pubsub.Close()
+}
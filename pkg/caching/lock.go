@@ -0,0 +1,78 @@
+package caching
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	DefaultLockTTL  = 5 * time.Second
+	DefaultLockWait = 2 * time.Second
+)
+
+// Only delete the lock if we still own it, it may have expired and been taken by someone else.
+var releaseScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0
+`)
+
+type lockOptions struct {
+	ttl  time.Duration
+	wait time.Duration
+}
+
+/*
+Enables cross-instance coalescing of cache misses.
+
+The first instance to miss a key takes a Redis lock for at most ttl while it
+computes the value; the others wait up to wait for it before computing it
+themselves. Zero values fall back to DefaultLockTTL and DefaultLockWait.
+*/
+func (c *CacheRedis) EnableLock(ttl, wait time.Duration) *CacheRedis {
+	if ttl <= 0 {
+		ttl = DefaultLockTTL
+	}
+	if wait <= 0 {
+		wait = DefaultLockWait
+	}
+	c.lock = &lockOptions{ttl: ttl, wait: wait}
+	return c
+}
+
+// Always succeeds when locking is not enabled.
+func (c *CacheRedis) TryLock(ctx context.Context, key string) (func(), bool, error) {
+	if c.lock == nil {
+		return func() {}, true, nil
+	}
+
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return nil, false, err
+	}
+	token := hex.EncodeToString(buf)
+	lockKey := "lock:cache:" + key
+
+	acquired, err := c.client.SetNX(ctx, lockKey, token, c.lock.ttl).Result()
+	if err != nil || !acquired {
+		return nil, false, err
+	}
+
+	return func() {
+		// the caller's context may be done by now
+		//nolint:errcheck
+		releaseScript.Run(context.WithoutCancel(ctx), c.client, []string{lockKey}, token)
+	}, true, nil
+}
+
+func (c *CacheRedis) LockWait() time.Duration {
+	if c.lock == nil {
+		return 0
+	}
+	return c.lock.wait
+}
@@ -0,0 +1,202 @@
+package caching
+
+import (
+	"container/list"
+	"context"
+	"sync"
+	"time"
+
+	"github.com/go-redis/cache/v9"
+)
+
+type EvictionPolicy int
+
+const (
+	// EvictLRU drops the least recently used key first.
+	EvictLRU EvictionPolicy = iota
+	// EvictLFU drops a rarely used key first, approximated like Redis by sampling a few keys.
+	EvictLFU
+)
+
+const (
+	DefaultMemorySize = 10000
+	// how many keys EvictLFU compares to pick one to drop
+	lfuSamples = 5
+	// go-redis/cache stores values without a ttl for an hour
+	memoryDefaultTTL = time.Hour
+)
+
+type memoryItem struct {
+	key     string
+	value   []byte
+	expires time.Time
+	tags    []string
+	hits    int
+	element *list.Element
+}
+
+func (item *memoryItem) expired(now time.Time) bool {
+	return !item.expires.IsZero() && !now.Before(item.expires)
+}
+
+/*
+CacheMemory is an in-process Cache, for tests and single node deployments.
+
+Values are encoded with the msgpack codec of go-redis/cache, so what comes out of
+Get is what CacheRedis would have returned. At most size keys are kept, the
+policy decides which one goes when a new key does not fit.
+*/
+type CacheMemory struct {
+	mu     sync.Mutex
+	items  map[string]*memoryItem
+	recent *list.List
+	tags   map[string]map[string]struct{}
+	size   int
+	policy EvictionPolicy
+	codec  *cache.Cache
+}
+
+func NewCacheMemory(size int, policy EvictionPolicy) *CacheMemory {
+	if size <= 0 {
+		size = DefaultMemorySize
+	}
+	return &CacheMemory{
+		items:  map[string]*memoryItem{},
+		recent: list.New(),
+		tags:   map[string]map[string]struct{}{},
+		size:   size,
+		policy: policy,
+		codec:  cache.New(&cache.Options{}),
+	}
+}
+
+func (c *CacheMemory) Get(ctx context.Context, key string, target any) error {
+	c.mu.Lock()
+	item, ok := c.items[key]
+	if ok && item.expired(time.Now()) {
+		c.remove(item)
+		ok = false
+	}
+	if !ok {
+		c.mu.Unlock()
+		return cache.ErrCacheMiss
+	}
+	item.hits++
+	c.recent.MoveToFront(item.element)
+	value := item.value
+	c.mu.Unlock()
+
+	return c.codec.Unmarshal(value, target)
+}
+
+func (c *CacheMemory) Set(ctx context.Context, key string, value any, ttl time.Duration, tags ...string) error {
+	b, err := c.codec.Marshal(value)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	var expires time.Time
+	switch {
+	case ttl < 0:
+	case ttl < time.Second:
+		// same as go-redis/cache, which rejects shorter ttls
+		expires = now.Add(memoryDefaultTTL)
+	default:
+		expires = now.Add(ttl)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if old, ok := c.items[key]; ok {
+		c.remove(old)
+	}
+	for len(c.items) >= c.size {
+		c.evict(now)
+	}
+
+	item := &memoryItem{key: key, value: b, expires: expires}
+	item.element = c.recent.PushFront(item)
+	c.items[key] = item
+	for _, tag := range tags {
+		keys, ok := c.tags[tag]
+		if !ok {
+			keys = map[string]struct{}{}
+			c.tags[tag] = keys
+		}
+		keys[key] = struct{}{}
+		item.tags = append(item.tags, tag)
+	}
+	return nil
+}
+
+func (c *CacheMemory) Delete(ctx context.Context, key string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if item, ok := c.items[key]; ok {
+		c.remove(item)
+	}
+	return nil
+}
+
+func (c *CacheMemory) InvalidateTag(ctx context.Context, tag string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for key := range c.tags[tag] {
+		if item, ok := c.items[key]; ok {
+			c.remove(item)
+		}
+	}
+	delete(c.tags, tag)
+	return nil
+}
+
+// Len is the number of keys held, expired ones included until they are noticed.
+func (c *CacheMemory) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.items)
+}
+
+func (c *CacheMemory) remove(item *memoryItem) {
+	delete(c.items, item.key)
+	c.recent.Remove(item.element)
+	for _, tag := range item.tags {
+		if keys, ok := c.tags[tag]; ok {
+			delete(keys, item.key)
+			if len(keys) == 0 {
+				delete(c.tags, tag)
+			}
+		}
+	}
+}
+
+// evict drops one key, an expired one when it finds one. Must hold mu.
+func (c *CacheMemory) evict(now time.Time) {
+	oldest := c.recent.Back().Value.(*memoryItem)
+	if oldest.expired(now) || c.policy == EvictLRU {
+		c.remove(oldest)
+		return
+	}
+
+	// map iteration order is random, which is all the sampling needs
+	var victim *memoryItem
+	sampled := 0
+	for _, item := range c.items {
+		if item.expired(now) {
+			victim = item
+			break
+		}
+		if victim == nil || item.hits < victim.hits {
+			victim = item
+		}
+		sampled++
+		if sampled == lfuSamples {
+			break
+		}
+	}
+	c.remove(victim)
+}
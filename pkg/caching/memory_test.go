@@ -0,0 +1,18 @@
+package caching_test
+
+import (
+	"testing"
+
+	"demo-cosebase/pkg/caching"
+	"demo-cosebase/pkg/caching/cachetest"
+)
+
+func TestCacheMemory(t *testing.T) {
+	for name, policy := range map[string]caching.EvictionPolicy{"LRU": caching.EvictLRU, "LFU": caching.EvictLFU} {
+		t.Run(name, func(t *testing.T) {
+			cachetest.Run(t, func(t *testing.T) caching.Cache {
+				return caching.NewCacheMemory(0, policy)
+			})
+		})
+	}
+}
@@ -0,0 +1,135 @@
+package caching
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// LatencyBuckets are the upper bounds of the latency histograms.
+var LatencyBuckets = []time.Duration{
+	time.Millisecond,
+	5 * time.Millisecond,
+	10 * time.Millisecond,
+	25 * time.Millisecond,
+	50 * time.Millisecond,
+	100 * time.Millisecond,
+	250 * time.Millisecond,
+	500 * time.Millisecond,
+	time.Second,
+}
+
+type histogram struct {
+	count   atomic.Int64
+	sum     atomic.Int64 // nanoseconds
+	buckets []atomic.Int64
+}
+
+func newHistogram() *histogram {
+	return &histogram{buckets: make([]atomic.Int64, len(LatencyBuckets))}
+}
+
+func (h *histogram) observe(d time.Duration) {
+	h.count.Add(1)
+	h.sum.Add(int64(d))
+	for i, bound := range LatencyBuckets {
+		if d <= bound {
+			h.buckets[i].Add(1)
+		}
+	}
+}
+
+func (h *histogram) snapshot() Histogram {
+	s := Histogram{Count: h.count.Load(), Sum: time.Duration(h.sum.Load()).Seconds(), Buckets: make([]int64, len(h.buckets))}
+	for i := range h.buckets {
+		s.Buckets[i] = h.buckets[i].Load()
+	}
+	return s
+}
+
+type counters struct {
+	hits       atomic.Int64
+	misses     atomic.Int64
+	stale      atomic.Int64
+	errors     atomic.Int64
+	loadErrors atomic.Int64
+	get        *histogram
+	load       *histogram
+}
+
+// Histogram is a cumulative latency histogram, Buckets follow LatencyBuckets.
+type Histogram struct {
+	Count   int64   `json:"count"`
+	Sum     float64 `json:"sum_seconds"`
+	Buckets []int64 `json:"buckets"`
+}
+
+/*
+Metrics of the UseCache helpers for the keys sharing a prefix.
+
+Hits count fresh values and remembered not-found answers, Stale values served past
+their ttl. Errors are failed cache reads, LoadErrors failed callbacks. Get times
+the cache read, Load the callback.
+*/
+type Metrics struct {
+	Prefix     string    `json:"prefix"`
+	Hits       int64     `json:"hits"`
+	Misses     int64     `json:"misses"`
+	Stale      int64     `json:"stale"`
+	Errors     int64     `json:"errors"`
+	LoadErrors int64     `json:"load_errors"`
+	HitRate    float64   `json:"hit_rate"`
+	Get        Histogram `json:"get"`
+	Load       Histogram `json:"load"`
+}
+
+var metrics sync.Map // prefix -> *counters
+
+// OtherPrefix labels the keys without a segment, so they share one series.
+const OtherPrefix = "other"
+
+// KeyPrefix is the label of key in metrics, its first segment: "user:" for "user:alice".
+func KeyPrefix(key string) string {
+	if i := strings.IndexByte(key, ':'); i >= 0 {
+		return key[:i+1]
+	}
+	return OtherPrefix
+}
+
+func countersFor(key string) *counters {
+	prefix := KeyPrefix(key)
+	if c, ok := metrics.Load(prefix); ok {
+		return c.(*counters)
+	}
+	c, _ := metrics.LoadOrStore(prefix, &counters{get: newHistogram(), load: newHistogram()})
+	return c.(*counters)
+}
+
+// Snapshot returns the metrics of every key prefix seen so far, sorted by prefix.
+func Snapshot() []*Metrics {
+	result := []*Metrics{}
+	metrics.Range(func(key, value any) bool {
+		c := value.(*counters)
+		m := &Metrics{
+			Prefix:     key.(string),
+			Hits:       c.hits.Load(),
+			Misses:     c.misses.Load(),
+			Stale:      c.stale.Load(),
+			Errors:     c.errors.Load(),
+			LoadErrors: c.loadErrors.Load(),
+			Get:        c.get.snapshot(),
+			Load:       c.load.snapshot(),
+		}
+		if served := m.Hits + m.Stale + m.Misses; served > 0 {
+			m.HitRate = float64(m.Hits+m.Stale) / float64(served)
+		}
+		result = append(result, m)
+		return true
+	})
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Prefix < result[j].Prefix
+	})
+	return result
+}
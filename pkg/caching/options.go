@@ -0,0 +1,82 @@
+package caching
+
+import "time"
+
+// DefaultNegativeTTL is how long a not-found answer is cached unless NegativeTTL says otherwise.
+const DefaultNegativeTTL = 30 * time.Second
+
+type options struct {
+	staleWhileRevalidate time.Duration
+	staleIfError         time.Duration
+	negativeTTL          time.Duration
+	tags                 []string
+	tagsFunc             func() []string
+}
+
+// Option tunes how UseCache treats stale values and not-found answers, and how it tags values.
+type Option func(*options)
+
+/*
+Serves a value for up to d after its TTL while it is refreshed in the background.
+
+The refresh outlives the request that triggered it, so the callback must not
+depend on a context that is cancelled when the request ends.
+*/
+func StaleWhileRevalidate(d time.Duration) Option {
+	return func(o *options) {
+		o.staleWhileRevalidate = d
+	}
+}
+
+// Serves the last good value for up to d after its TTL when the callback fails.
+func StaleIfError(d time.Duration) Option {
+	return func(o *options) {
+		o.staleIfError = d
+	}
+}
+
+// How long a callback returning ErrNotFound is remembered, 0 disables negative caching.
+func NegativeTTL(d time.Duration) Option {
+	return func(o *options) {
+		o.negativeTTL = d
+	}
+}
+
+// Records the cached value under tags, see Cache.InvalidateTag.
+func Tags(tags ...string) Option {
+	return func(o *options) {
+		o.tags = append(o.tags, tags...)
+	}
+}
+
+/*
+Like Tags, for tags only known once the callback has run.
+
+fn is called right after a successful callback, typically returning what the
+callback collected while loading the value.
+*/
+func TagsFunc(fn func() []string) Option {
+	return func(o *options) {
+		o.tagsFunc = fn
+	}
+}
+
+func (o *options) tagsFor() []string {
+	if o.tagsFunc == nil {
+		return o.tags
+	}
+	return append(o.tags[:len(o.tags):len(o.tags)], o.tagsFunc()...)
+}
+
+func newOptions(opts []Option) *options {
+	o := &options{negativeTTL: DefaultNegativeTTL}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
+
+// How long a value is kept after its TTL, the hard TTL is ttl plus grace.
+func (o *options) grace() time.Duration {
+	return max(o.staleWhileRevalidate, o.staleIfError)
+}
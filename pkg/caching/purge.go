@@ -0,0 +1,247 @@
+package caching
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+
+	"github.com/redis/go-redis/v9"
+	"golang.org/x/time/rate"
+)
+
+const DefaultPurgeBatch = 500
+
+// purgeSender is the writer id of purge invalidations, no cache has it so they all evict.
+const purgeSender = "purge"
+
+type PurgeOptions struct {
+	// Keys matching Pattern are deleted, * and the other SCAN wildcards are supported.
+	Pattern string
+	// DryRun only counts the matching keys.
+	DryRun bool
+	// Keys fetched by each SCAN and unlinked by each pipeline, DefaultPurgeBatch when zero.
+	Batch int
+	// At most Rate keys are unlinked per second across all nodes, zero is unlimited.
+	Rate float64
+	// Progress is called after each batch with the totals so far, it must be safe for
+	// concurrent use as cluster nodes are purged in parallel.
+	Progress func(PurgeStats)
+	// Channel announces the purged keys so local caches synced on it evict them, see
+	// CacheRedis.SyncLocal. DefaultInvalidationChannel when empty.
+	Channel string
+}
+
+type PurgeStats struct {
+	// keys returned by SCAN
+	Matched int64
+	// keys unlinked, a key expiring meanwhile is matched but not deleted
+	Deleted int64
+	// batches that failed
+	Failed int64
+	// purged keys removed from the tag sets still listing them
+	Untagged int64
+}
+
+type purge struct {
+	client  redis.UniversalClient
+	opts    *PurgeOptions
+	limiter *rate.Limiter
+
+	mu    sync.Mutex
+	stats PurgeStats
+	errs  []error
+}
+
+/*
+Purge deletes the keys matching a pattern without blocking Redis.
+
+Keys are found with SCAN and unlinked in pipelines of opts.Batch, so memory is
+reclaimed in the background and no node is held up by a large DEL. On a cluster
+every master is purged. A failed batch is recorded and the purge goes on, the
+returned error joins every failure.
+
+Each batch is announced on opts.Channel for local caches to evict, and once the
+keys are gone they are removed from the tag sets (see Cache.InvalidateTag), which
+also takes a pass over every tag set.
+
+Wildcards still walk the whole keyspace, prefer tags (see Cache.InvalidateTag)
+for anything done on a request path.
+*/
+func Purge(ctx context.Context, client redis.UniversalClient, opts *PurgeOptions) (PurgeStats, error) {
+	if opts.Pattern == "" {
+		return PurgeStats{}, errors.New("caching: purge needs a pattern")
+	}
+	if opts.Batch <= 0 {
+		opts.Batch = DefaultPurgeBatch
+	}
+	if opts.Channel == "" {
+		opts.Channel = DefaultInvalidationChannel
+	}
+
+	p := &purge{client: client, opts: opts, limiter: rate.NewLimiter(rate.Inf, 0)}
+	if opts.Rate > 0 {
+		p.limiter = rate.NewLimiter(rate.Limit(opts.Rate), max(opts.Batch, int(opts.Rate)))
+	}
+
+	// a pattern without wildcard is a single key, no need to scan for it
+	if !strings.ContainsAny(opts.Pattern, "*?[") {
+		n, err := client.Exists(ctx, opts.Pattern).Result()
+		if err != nil {
+			p.fail(fmt.Errorf("exists %s: %w", opts.Pattern, err))
+		} else if n > 0 {
+			p.batch(ctx, client, []string{opts.Pattern})
+		}
+	} else {
+		p.eachNode(ctx, p.node)
+	}
+
+	if !opts.DryRun && p.stats.Deleted > 0 {
+		p.eachNode(ctx, p.untag)
+	}
+	return p.stats, errors.Join(p.errs...)
+}
+
+// eachNode runs fn on every master of a cluster, or on the client itself.
+func (p *purge) eachNode(ctx context.Context, fn func(ctx context.Context, node redis.UniversalClient)) {
+	clusterClient, ok := p.client.(*redis.ClusterClient)
+	if !ok {
+		fn(ctx, p.client)
+		return
+	}
+	err := clusterClient.ForEachMaster(ctx, func(ctx context.Context, node *redis.Client) error {
+		fn(ctx, node)
+		return nil
+	})
+	if err != nil {
+		p.fail(err)
+	}
+}
+
+func (p *purge) node(ctx context.Context, client redis.UniversalClient) {
+	var cursor uint64
+	for {
+		keys, next, err := client.Scan(ctx, cursor, p.opts.Pattern, int64(p.opts.Batch)).Result()
+		if err != nil {
+			p.fail(fmt.Errorf("scan %s: %w", p.opts.Pattern, err))
+			return
+		}
+		// COUNT is only a hint, SCAN may return more
+		for len(keys) > 0 {
+			n := min(len(keys), p.opts.Batch)
+			p.batch(ctx, client, keys[:n])
+			keys = keys[n:]
+		}
+		cursor = next
+		if cursor == 0 {
+			return
+		}
+	}
+}
+
+func (p *purge) batch(ctx context.Context, client redis.UniversalClient, keys []string) {
+	var deleted int64
+	var err error
+	if !p.opts.DryRun {
+		deleted, err = p.unlink(ctx, client, keys)
+		p.announce(ctx, keys)
+	}
+
+	p.mu.Lock()
+	p.stats.Matched += int64(len(keys))
+	if err != nil {
+		p.stats.Failed++
+		p.errs = append(p.errs, err)
+	}
+	p.stats.Deleted += deleted
+	stats := p.stats
+	p.mu.Unlock()
+
+	if p.opts.Progress != nil {
+		p.opts.Progress(stats)
+	}
+}
+
+func (p *purge) unlink(ctx context.Context, client redis.UniversalClient, keys []string) (int64, error) {
+	if err := p.limiter.WaitN(ctx, len(keys)); err != nil {
+		return 0, err
+	}
+
+	pipe := client.Pipeline()
+	cmds := make([]*redis.IntCmd, len(keys))
+	for i, key := range keys {
+		cmds[i] = pipe.Unlink(ctx, key)
+	}
+	_, err := pipe.Exec(ctx)
+
+	var deleted int64
+	for _, cmd := range cmds {
+		deleted += cmd.Val()
+	}
+	return deleted, err
+}
+
+// announce tells local caches to evict keys, a failure only leaves local copies until their ttl.
+func (p *purge) announce(ctx context.Context, keys []string) {
+	payload := purgeSender + "\n" + strings.Join(keys, "\n")
+	if err := p.client.Publish(ctx, p.opts.Channel, payload).Err(); err != nil {
+		log.Printf("caching: publish purge invalidation: %v\n", err)
+	}
+}
+
+// untag removes the members matching the pattern from the tag sets of a node.
+func (p *purge) untag(ctx context.Context, node redis.UniversalClient) {
+	var cursor uint64
+	for {
+		tags, next, err := node.Scan(ctx, cursor, tagKey("*"), int64(p.opts.Batch)).Result()
+		if err != nil {
+			p.fail(fmt.Errorf("scan tags: %w", err))
+			return
+		}
+		for _, tag := range tags {
+			if err := p.untagSet(ctx, node, tag); err != nil {
+				p.fail(fmt.Errorf("untag %s: %w", tag, err))
+			}
+		}
+		cursor = next
+		if cursor == 0 {
+			return
+		}
+	}
+}
+
+func (p *purge) untagSet(ctx context.Context, node redis.UniversalClient, tag string) error {
+	var cursor uint64
+	for {
+		keys, next, err := node.SScan(ctx, tag, cursor, p.opts.Pattern, int64(p.opts.Batch)).Result()
+		if err != nil {
+			return err
+		}
+		if len(keys) > 0 {
+			members := make([]any, len(keys))
+			for i, key := range keys {
+				members[i] = key
+			}
+			removed, err := node.SRem(ctx, tag, members...).Result()
+			if err != nil {
+				return err
+			}
+			p.mu.Lock()
+			p.stats.Untagged += removed
+			p.mu.Unlock()
+		}
+		cursor = next
+		if cursor == 0 {
+			return nil
+		}
+	}
+}
+
+func (p *purge) fail(err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.stats.Failed++
+	p.errs = append(p.errs, err)
+}
@@ -0,0 +1,184 @@
+package caching_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	"demo-cosebase/pkg/caching"
+	"demo-cosebase/pkg/caching/cachetest"
+
+	"github.com/go-redis/cache/v9"
+	"github.com/redis/go-redis/v9"
+)
+
+// testRedis connects to the Redis at CACHE_TEST_REDIS, e.g. redis://localhost:6379/15, which is flushed.
+func testRedis(t *testing.T) *redis.Client {
+	url := os.Getenv("CACHE_TEST_REDIS")
+	if url == "" {
+		t.Skip("CACHE_TEST_REDIS is not set")
+	}
+	opts, err := redis.ParseURL(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := redis.NewClient(opts)
+	t.Cleanup(func() { client.Close() })
+	return client
+}
+
+func TestCacheRedis(t *testing.T) {
+	client := testRedis(t)
+
+	for name, local := range map[string]bool{"Remote": false, "Local": true} {
+		t.Run(name, func(t *testing.T) {
+			cachetest.Run(t, func(t *testing.T) caching.Cache {
+				if err := client.FlushDB(context.Background()).Err(); err != nil {
+					t.Fatal(err)
+				}
+				c, err := caching.NewCacheRedis(client, local)
+				if err != nil {
+					t.Fatal(err)
+				}
+				return c.EnableLock(caching.DefaultLockTTL, caching.DefaultLockWait)
+			})
+		})
+	}
+}
+
+// A caller waiting on the lock of another takes the not-found answer it stores.
+func TestLockWaitNotFound(t *testing.T) {
+	ctx := context.Background()
+	client := testRedis(t)
+	if err := client.FlushDB(ctx).Err(); err != nil {
+		t.Fatal(err)
+	}
+	c, err := caching.NewCacheRedis(client, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.EnableLock(caching.DefaultLockTTL, caching.DefaultLockWait)
+
+	release := make(chan struct{})
+	started := make(chan struct{})
+	holder := make(chan error, 1)
+	go func() {
+		// another type, so the two calls do not share a load in this process and the
+		// second one waits on the Redis lock
+		_, err := caching.UseCache(ctx, c, "lock:missing", time.Minute, func() (int, error) {
+			close(started)
+			<-release
+			return 0, caching.ErrNotFound
+		})
+		holder <- err
+	}()
+	<-started
+
+	waiter := make(chan error, 1)
+	calls := 0
+	began := time.Now()
+	go func() {
+		_, err := caching.UseCache(ctx, c, "lock:missing", time.Minute, func() (string, error) {
+			calls++
+			return "", nil
+		})
+		waiter <- err
+	}()
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+
+	if err := <-holder; !errors.Is(err, caching.ErrNotFound) {
+		t.Fatalf("lock holder: got %v, want ErrNotFound", err)
+	}
+	if err := <-waiter; !errors.Is(err, caching.ErrNotFound) {
+		t.Fatalf("waiter: got %v, want ErrNotFound", err)
+	}
+	if calls != 0 || time.Since(began) >= caching.DefaultLockWait {
+		t.Fatalf("waiter loaded %d times after %s, want the stored answer", calls, time.Since(began))
+	}
+}
+
+func TestInspect(t *testing.T) {
+	ctx := context.Background()
+	client := testRedis(t)
+	if err := client.FlushDB(ctx).Err(); err != nil {
+		t.Fatal(err)
+	}
+	c, err := caching.NewCacheRedis(client, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Set(ctx, "inspect:a", []byte("value"), time.Minute, "inspect"); err != nil {
+		t.Fatal(err)
+	}
+
+	info, err := c.Inspect(ctx, "inspect:a")
+	if err != nil || info.Type != "string" || info.Size != 5 || info.TTL <= 0 {
+		t.Fatalf("string key: got %+v, %v", info, err)
+	}
+	// the tag sets are not strings
+	info, err = c.Inspect(ctx, "tag:inspect")
+	if err != nil || info.Type != "set" || info.Size <= 0 {
+		t.Fatalf("tag set: got %+v, %v", info, err)
+	}
+	if _, err := c.Inspect(ctx, "inspect:missing"); !errors.Is(err, cache.ErrCacheMiss) {
+		t.Fatalf("missing key: got %v, want cache.ErrCacheMiss", err)
+	}
+}
+
+func TestPurge(t *testing.T) {
+	ctx := context.Background()
+	client := testRedis(t)
+	if err := client.FlushDB(ctx).Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	c, err := caching.NewCacheRedis(client, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err = c.SyncLocal(caching.DefaultInvalidationChannel)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { c.Shutdown() })
+
+	for _, key := range []string{"purge:a", "purge:b", "kept:a"} {
+		if err := c.Set(ctx, key, key, time.Minute, "purge-test"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// let the subscription settle
+	time.Sleep(100 * time.Millisecond)
+
+	stats, err := caching.Purge(ctx, client, &caching.PurgeOptions{Pattern: "purge:*"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Matched != 2 || stats.Deleted != 2 || stats.Untagged != 2 {
+		t.Fatalf("got %+v, want 2 matched, deleted and untagged", stats)
+	}
+
+	// the local copies are evicted by the announcement
+	time.Sleep(100 * time.Millisecond)
+	var v string
+	for key, gone := range map[string]bool{"purge:a": true, "purge:b": true, "kept:a": false} {
+		err := c.Get(ctx, key, &v)
+		if gone && !errors.Is(err, cache.ErrCacheMiss) {
+			t.Errorf("%s: got %v, want it purged", key, err)
+		}
+		if !gone && err != nil {
+			t.Errorf("%s: got %v, want it kept", key, err)
+		}
+	}
+
+	members, err := client.SMembers(ctx, "tag:purge-test").Result()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(members) != 1 || members[0] != "kept:a" {
+		t.Fatalf("tag set holds %v, want only kept:a", members)
+	}
+}
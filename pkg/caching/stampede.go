@@ -0,0 +1,284 @@
+package caching
+
+import (
+	"context"
+	"errors"
+	"math"
+	"math/rand/v2"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/go-redis/cache/v9"
+	"golang.org/x/sync/singleflight"
+)
+
+/*
+Controls probabilistic early expiration (XFetch).
+
+A cached value is recomputed before its expiry with a probability that grows as
+the expiry approaches and as the value gets more expensive to compute. Higher
+values refresh earlier, 0 disables early expiration.
+*/
+var EarlyExpirationBeta = 1.0
+
+// How often a request polls the cache while another instance holds the fill lock.
+var lockPollInterval = 25 * time.Millisecond
+
+var group singleflight.Group
+
+/*
+ErrNotFound is returned by a UseCache callback, possibly wrapped, when what it
+looks up does not exist.
+
+The answer is cached for a short while (see NegativeTTL) and later hits return
+ErrNotFound itself, so callers map it back to their own error.
+*/
+var ErrNotFound = errors.New("caching: not found")
+
+// errRefreshing is returned by fill when another instance is already refreshing an early-expiring key.
+var errRefreshing = errors.New("caching: refresh in progress")
+
+/*
+Implemented by caches that can coalesce misses across instances.
+
+TryLock returns acquired=false when another instance is filling the key, in
+which case the caller waits up to LockWait for the value to show up before
+computing it itself.
+*/
+type Locker interface {
+	TryLock(ctx context.Context, key string) (release func(), acquired bool, err error)
+	LockWait() time.Duration
+}
+
+/*
+entry is the envelope stored by UseCache.
+
+Expiry is the soft TTL after which the value is stale, HardExpiry the point after
+which it must not be served at all. Delta is what XFetch needs to refresh early.
+A Missing entry remembers that the callback returned ErrNotFound.
+*/
+type entry[T any] struct {
+	Value   T    `msgpack:"v"`
+	Missing bool `msgpack:"m"`
+	// time spent computing the value, in milliseconds
+	Delta int64 `msgpack:"d"`
+	// unix milliseconds
+	Expiry     int64 `msgpack:"e"`
+	HardExpiry int64 `msgpack:"h"`
+}
+
+func (e *entry[T]) result() (T, error) {
+	if e.Missing {
+		var zero T
+		return zero, ErrNotFound
+	}
+	return e.Value, nil
+}
+
+func (e *entry[T]) fresh(now time.Time) bool {
+	return now.UnixMilli() < e.Expiry
+}
+
+/*
+Whether the value was cached before the envelope was introduced.
+
+msgpack decodes such a value into a zero entry without error, while fill always
+sets Expiry, so it is recognized by the missing expiry.
+*/
+func (e *entry[T]) legacy() bool {
+	return e.Expiry == 0 && !e.Missing
+}
+
+// Whether the value may still be served up to d after its soft TTL.
+func (e *entry[T]) staleFor(now time.Time, d time.Duration) bool {
+	if e.HardExpiry != 0 && now.UnixMilli() >= e.HardExpiry {
+		return false
+	}
+	return now.UnixMilli() < e.Expiry+d.Milliseconds()
+}
+
+func (e *entry[T]) expiresEarly(now time.Time) bool {
+	if EarlyExpirationBeta <= 0 || e.Expiry == 0 {
+		return false
+	}
+	gap := float64(e.Delta) * EarlyExpirationBeta * -math.Log(1-rand.Float64())
+	return float64(now.UnixMilli())+gap >= float64(e.Expiry)
+}
+
+func useCache[T any](ctx context.Context, read ReadOnlyCache, cash Cache, key string, ttl time.Duration, callback func() (T, error), opts []Option) (T, error) {
+	o := newOptions(opts)
+	m := countersFor(key)
+
+	var e entry[T]
+	started := time.Now()
+	err := read.Get(ctx, key, &e)
+	m.get.observe(time.Since(started))
+	switch {
+	case err == nil && !e.legacy():
+	case err == nil, errors.Is(err, cache.ErrCacheMiss), isDecodeError(err):
+		m.misses.Add(1)
+		return refresh(ctx, cash, key, ttl, callback, o, false)
+	default:
+		m.errors.Add(1)
+		return e.Value, err
+	}
+
+	now := time.Now()
+	if e.fresh(now) {
+		m.hits.Add(1)
+		if !e.expiresEarly(now) {
+			return e.result()
+		}
+		// the cached value is still valid, so it wins over a failed or skipped refresh
+		if v, err := refresh(ctx, cash, key, ttl, callback, o, true); err == nil || errors.Is(err, ErrNotFound) {
+			return v, err
+		}
+		return e.result()
+	}
+
+	if e.staleFor(now, o.staleWhileRevalidate) {
+		m.stale.Add(1)
+		go func() {
+			//nolint:errcheck
+			refresh(context.WithoutCancel(ctx), cash, key, ttl, callback, o, true)
+		}()
+		return e.result()
+	}
+
+	v, err := refresh(ctx, cash, key, ttl, callback, o, false)
+	if err != nil && !errors.Is(err, ErrNotFound) && e.staleFor(now, o.staleIfError) {
+		m.stale.Add(1)
+		return e.result()
+	}
+	m.misses.Add(1)
+	return v, err
+}
+
+/*
+Runs fill at most once per key and type at a time within this process.
+
+The fill is shared by every caller of the key, so it runs without their
+cancellation and one caller giving up does not fail the others. Early refreshes
+may be skipped, they are coalesced apart from the fills callers wait for. Call
+sites reading the same key into different types do not share fills.
+*/
+func refresh[T any](ctx context.Context, cash Cache, key string, ttl time.Duration, callback func() (T, error), o *options, early bool) (T, error) {
+	var zero T
+	flight := reflect.TypeFor[T]().String() + "\x00" + key
+	if early {
+		flight = "early\x00" + flight
+	}
+	ch := group.DoChan(flight, func() (any, error) {
+		return fill(context.WithoutCancel(ctx), cash, key, ttl, callback, o, early)
+	})
+
+	select {
+	case <-ctx.Done():
+		return zero, ctx.Err()
+	case res := <-ch:
+		if res.Err != nil {
+			return zero, res.Err
+		}
+		// two types with the same name in different packages share the flight
+		v, ok := res.Val.(T)
+		if !ok {
+			return fill(ctx, cash, key, ttl, callback, o, early)
+		}
+		return v, nil
+	}
+}
+
+/*
+Computes and stores the value of key.
+
+With early set, a value is already being served and the refresh is skipped when
+another instance holds the lock.
+*/
+func fill[T any](ctx context.Context, cash Cache, key string, ttl time.Duration, callback func() (T, error), o *options, early bool) (T, error) {
+	if locker, ok := cash.(Locker); ok {
+		release, acquired, err := locker.TryLock(ctx, key)
+		switch {
+		case err != nil:
+			// the lock is an optimization, compute without it
+		case acquired:
+			defer release()
+		case early:
+			var zero T
+			return zero, errRefreshing
+		default:
+			if v, found, err := waitFor[T](ctx, cash, key, locker.LockWait()); found {
+				return v, err
+			}
+		}
+	}
+
+	started := time.Now()
+	v, err := callback()
+	m := countersFor(key)
+	m.load.observe(time.Since(started))
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		m.loadErrors.Add(1)
+	}
+	if errors.Is(err, ErrNotFound) {
+		if o.negativeTTL > 0 {
+			// fire and forget
+			//nolint:errcheck
+			cash.Set(ctx, key, &entry[T]{
+				Missing:    true,
+				Expiry:     time.Now().Add(o.negativeTTL).UnixMilli(),
+				HardExpiry: time.Now().Add(o.negativeTTL).UnixMilli(),
+			}, o.negativeTTL, o.tags...)
+		}
+		return v, ErrNotFound
+	}
+	if err != nil {
+		return v, err
+	}
+
+	now := time.Now()
+	hard := ttl + o.grace()
+	// fire and forget
+	//nolint:errcheck
+	cash.Set(ctx, key, &entry[T]{
+		Value:      v,
+		Delta:      now.Sub(started).Milliseconds(),
+		Expiry:     now.Add(ttl).UnixMilli(),
+		HardExpiry: now.Add(hard).UnixMilli(),
+	}, hard, o.tagsFor()...)
+	return v, nil
+}
+
+/*
+Polls cash until the lock holder has stored key or wait elapses.
+
+A stored not-found answer is a result too, it returns ErrNotFound.
+*/
+func waitFor[T any](ctx context.Context, cash Cache, key string, wait time.Duration) (T, bool, error) {
+	var zero T
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	ticker := time.NewTicker(lockPollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return zero, false, nil
+		case <-timer.C:
+			return zero, false, nil
+		case <-ticker.C:
+			// a stale value is what we are waiting to replace
+			var e entry[T]
+			if err := cash.Get(ctx, key, &e); err == nil && !e.legacy() && e.fresh(time.Now()) {
+				v, err := e.result()
+				return v, true, err
+			}
+		}
+	}
+}
+
+// Values of another shape than the envelope, e.g. a bare string, fail to decode; treat them as misses.
+func isDecodeError(err error) bool {
+	return err != nil && strings.HasPrefix(err.Error(), "msgpack:")
+}
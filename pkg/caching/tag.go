@@ -0,0 +1,72 @@
+package caching
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// How many keys InvalidateTag pops from a tag set per round trip.
+const tagBatchSize = 500
+
+/*
+Adds a key to a tag set and makes sure the set lives at least as long as the key.
+
+Each script touches a single key, so it runs on any node of a cluster.
+*/
+var tagScript = redis.NewScript(`
+redis.call("SADD", KEYS[1], ARGV[1])
+local ttl = tonumber(ARGV[2])
+if redis.call("PTTL", KEYS[1]) < ttl then
+	redis.call("PEXPIRE", KEYS[1], ttl)
+end
+return 1
+`)
+
+func tagKey(tag string) string {
+	return "tag:" + tag
+}
+
+func (c *CacheRedis) tag(ctx context.Context, key string, ttl time.Duration, tags []string) error {
+	var errs []error
+	for _, tag := range tags {
+		err := tagScript.Run(ctx, c.client, []string{tagKey(tag)}, key, ttl.Milliseconds()).Err()
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+/*
+Deletes every key recorded under tag.
+
+Keys are popped from the tag set rather than read then deleted, so a key tagged
+while the invalidation runs is either deleted now or kept in the set for the
+next one. Keys live in their own slots and are unlinked one by one through a
+pipeline, which go-redis splits per node on a cluster.
+*/
+func (c *CacheRedis) InvalidateTag(ctx context.Context, tag string) error {
+	for {
+		keys, err := c.client.SPopN(ctx, tagKey(tag), tagBatchSize).Result()
+		if err != nil && !errors.Is(err, redis.Nil) {
+			return err
+		}
+		if len(keys) == 0 {
+			return nil
+		}
+
+		pipe := c.client.Pipeline()
+		for _, key := range keys {
+			c.instance.DeleteFromLocalCache(key)
+			pipe.Unlink(ctx, key)
+		}
+		_, err = pipe.Exec(ctx)
+		c.publish(ctx, keys...)
+		if err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,161 @@
+// Package diff compares texts line by line with the Myers algorithm, the edit script is
+// the shortest one and its cost grows with the number of changes, not the text length.
+package diff
+
+import "strings"
+
+type Op string
+
+const (
+	Equal  Op = "equal"
+	Insert Op = "insert"
+	Delete Op = "delete"
+)
+
+// Chunk is a run of consecutive lines with the same operation.
+type Chunk struct {
+	Op    Op       `json:"op"`
+	Lines []string `json:"lines"`
+}
+
+// Stats counts the inserted and deleted lines of a diff.
+type Stats struct {
+	Inserted int `json:"inserted"`
+	Deleted  int `json:"deleted"`
+}
+
+// Lines returns the chunks turning a into b.
+func Lines(a, b string) []Chunk {
+	return Diff(split(a), split(b))
+}
+
+// Count returns the stats of chunks.
+func Count(chunks []Chunk) Stats {
+	var stats Stats
+	for _, chunk := range chunks {
+		switch chunk.Op {
+		case Insert:
+			stats.Inserted += len(chunk.Lines)
+		case Delete:
+			stats.Deleted += len(chunk.Lines)
+		}
+	}
+	return stats
+}
+
+// Diff returns the chunks turning a into b.
+func Diff(a, b []string) []Chunk {
+	// the common prefix and suffix are kept out of the search
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+
+	var chunks []Chunk
+	chunks = appendLines(chunks, Equal, a[:prefix]...)
+	chunks = myers(chunks, a[prefix:len(a)-suffix], b[prefix:len(b)-suffix])
+	chunks = appendLines(chunks, Equal, a[len(a)-suffix:]...)
+	return chunks
+}
+
+func myers(chunks []Chunk, a, b []string) []Chunk {
+	n, m := len(a), len(b)
+	if n == 0 {
+		return appendLines(chunks, Insert, b...)
+	}
+	if m == 0 {
+		return appendLines(chunks, Delete, a...)
+	}
+
+	max := n + m
+	offset := max
+	v := make([]int, 2*max+2)
+	// trace keeps v before every step d to walk the path back
+	var trace [][]int
+	d := 0
+search:
+	for ; d <= max; d++ {
+		trace = append(trace, append([]int(nil), v...))
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+				x = v[offset+k+1]
+			} else {
+				x = v[offset+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[offset+k] = x
+			if x >= n && y >= m {
+				break search
+			}
+		}
+	}
+
+	// walk back from (n, m), collecting the edits in reverse
+	type edit struct {
+		op   Op
+		line string
+	}
+	var edits []edit
+	x, y := n, m
+	for ; d > 0; d-- {
+		v := trace[d]
+		k := x - y
+		var prevK int
+		if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+			prevK = k + 1
+		} else {
+			prevK = k - 1
+		}
+		prevX := v[offset+prevK]
+		prevY := prevX - prevK
+		for x > prevX && y > prevY {
+			x--
+			y--
+			edits = append(edits, edit{Equal, a[x]})
+		}
+		if x == prevX {
+			y--
+			edits = append(edits, edit{Insert, b[y]})
+		} else {
+			x--
+			edits = append(edits, edit{Delete, a[x]})
+		}
+	}
+	for x > 0 && y > 0 {
+		x--
+		y--
+		edits = append(edits, edit{Equal, a[x]})
+	}
+
+	for i := len(edits) - 1; i >= 0; i-- {
+		chunks = appendLines(chunks, edits[i].op, edits[i].line)
+	}
+	return chunks
+}
+
+func appendLines(chunks []Chunk, op Op, lines ...string) []Chunk {
+	if len(lines) == 0 {
+		return chunks
+	}
+	if last := len(chunks) - 1; last >= 0 && chunks[last].Op == op {
+		chunks[last].Lines = append(chunks[last].Lines, lines...)
+		return chunks
+	}
+	return append(chunks, Chunk{Op: op, Lines: append([]string(nil), lines...)})
+}
+
+func split(text string) []string {
+	if text == "" {
+		return nil
+	}
+	return strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n")
+}
@@ -0,0 +1,281 @@
+// Package epub writes EPUB 3 books to a stream. Chapters are compressed into the
+// archive as they are added, only their titles are kept until Close writes the package
+// document and the navigation.
+package epub
+
+import (
+	"archive/zip"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"html"
+	"io"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const mimetype = "application/epub+zip"
+
+var ErrClosed = errors.New("epub: writer closed")
+
+type Metadata struct {
+	// Identifier is the unique id of the book, e.g. "urn:story:123".
+	Identifier  string
+	Title       string
+	Language    string
+	Author      string
+	Description string
+	Modified    time.Time
+}
+
+type chapter struct {
+	file   string
+	volume string
+	title  string
+}
+
+type Writer struct {
+	zip      *zip.Writer
+	meta     Metadata
+	cover    string
+	coverExt string
+	chapters []chapter
+	closed   bool
+}
+
+// NewWriter starts a book on w, the mimetype entry is written right away.
+func NewWriter(w io.Writer, meta Metadata) (*Writer, error) {
+	if meta.Language == "" {
+		meta.Language = "vi"
+	}
+	if meta.Modified.IsZero() {
+		meta.Modified = time.Now()
+	}
+
+	zw := zip.NewWriter(w)
+	// the mimetype must be the first entry, stored and without data descriptor
+	header := &zip.FileHeader{
+		Name:               "mimetype",
+		Method:             zip.Store,
+		CRC32:              crc32.ChecksumIEEE([]byte(mimetype)),
+		CompressedSize64:   uint64(len(mimetype)),
+		UncompressedSize64: uint64(len(mimetype)),
+	}
+	entry, err := zw.CreateRaw(header)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := io.WriteString(entry, mimetype); err != nil {
+		return nil, err
+	}
+
+	entry, err = zw.Create("META-INF/container.xml")
+	if err != nil {
+		return nil, err
+	}
+	_, err = io.WriteString(entry, `<?xml version="1.0" encoding="UTF-8"?>
+<container version="1.0" xmlns="urn:oasis:names:tc:opendocument:xmlns:container">
+  <rootfiles>
+    <rootfile full-path="OEBPS/content.opf" media-type="application/oebps-package+xml"/>
+  </rootfiles>
+</container>
+`)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Writer{zip: zw, meta: meta}, nil
+}
+
+// SetCover adds the cover image, mediaType is image/jpeg, image/png or image/gif. It
+// must be called before the first chapter so the cover page comes first.
+func (w *Writer) SetCover(image io.Reader, mediaType string) error {
+	if w.closed {
+		return ErrClosed
+	}
+	if len(w.chapters) > 0 {
+		return errors.New("epub: cover set after chapters")
+	}
+
+	ext := map[string]string{"image/jpeg": "jpg", "image/png": "png", "image/gif": "gif"}[mediaType]
+	if ext == "" {
+		return fmt.Errorf("epub: unsupported cover type %q", mediaType)
+	}
+
+	entry, err := w.zip.Create("OEBPS/images/cover." + ext)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(entry, image); err != nil {
+		return err
+	}
+
+	entry, err = w.zip.Create("OEBPS/cover.xhtml")
+	if err != nil {
+		return err
+	}
+	body := fmt.Sprintf(`<section epub:type="cover"><img src="images/cover.%s" alt="%s"/></section>`, ext, escape(w.meta.Title))
+	if _, err := io.WriteString(entry, w.page(w.meta.Title, body)); err != nil {
+		return err
+	}
+
+	w.cover, w.coverExt = "cover.xhtml", ext
+	return nil
+}
+
+// AddChapter writes a chapter, paragraphs are separated by blank lines. Consecutive
+// chapters of the same volume are grouped under it in the table of contents, an empty
+// volume puts the chapter at the top level.
+func (w *Writer) AddChapter(volume, title, text string) error {
+	if w.closed {
+		return ErrClosed
+	}
+
+	file := fmt.Sprintf("chapter-%05d.xhtml", len(w.chapters)+1)
+	entry, err := w.zip.Create("OEBPS/" + file)
+	if err != nil {
+		return err
+	}
+
+	var body strings.Builder
+	body.WriteString(`<section epub:type="chapter"><h2>`)
+	body.WriteString(escape(title))
+	body.WriteString("</h2>\n")
+	for _, paragraph := range strings.Split(text, "\n\n") {
+		paragraph = strings.TrimSpace(paragraph)
+		if paragraph == "" {
+			continue
+		}
+		body.WriteString("<p>")
+		body.WriteString(strings.ReplaceAll(escape(paragraph), "\n", "<br/>"))
+		body.WriteString("</p>\n")
+	}
+	body.WriteString("</section>")
+	if _, err := io.WriteString(entry, w.page(title, body.String())); err != nil {
+		return err
+	}
+
+	w.chapters = append(w.chapters, chapter{file: file, volume: volume, title: title})
+	return nil
+}
+
+// Close writes the navigation and the package document then the zip directory. It does
+// not close the underlying writer.
+func (w *Writer) Close() error {
+	if w.closed {
+		return ErrClosed
+	}
+	w.closed = true
+
+	entry, err := w.zip.Create("OEBPS/nav.xhtml")
+	if err != nil {
+		return err
+	}
+	if _, err := io.WriteString(entry, w.nav()); err != nil {
+		return err
+	}
+
+	entry, err = w.zip.Create("OEBPS/content.opf")
+	if err != nil {
+		return err
+	}
+	if _, err := io.WriteString(entry, w.opf()); err != nil {
+		return err
+	}
+	return w.zip.Close()
+}
+
+func (w *Writer) page(title, body string) string {
+	return fmt.Sprintf(`<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE html>
+<html xmlns="http://www.w3.org/1999/xhtml" xmlns:epub="http://www.idpf.org/2007/ops" lang="%[1]s" xml:lang="%[1]s">
+<head><meta charset="utf-8"/><title>%[2]s</title></head>
+<body>
+%[3]s
+</body>
+</html>
+`, escape(w.meta.Language), escape(title), body)
+}
+
+func (w *Writer) nav() string {
+	var b strings.Builder
+	b.WriteString(`<nav epub:type="toc" id="toc"><h1>`)
+	b.WriteString(escape(w.meta.Title))
+	b.WriteString("</h1>\n<ol>\n")
+
+	for i := 0; i < len(w.chapters); {
+		ch := w.chapters[i]
+		if ch.volume == "" {
+			fmt.Fprintf(&b, "<li><a href=\"%s\">%s</a></li>\n", ch.file, escape(ch.title))
+			i++
+			continue
+		}
+
+		// a volume entry links to its first chapter, EPUB 3 requires a link or a span
+		fmt.Fprintf(&b, "<li><a href=\"%s\">%s</a>\n<ol>\n", ch.file, escape(ch.volume))
+		for ; i < len(w.chapters) && w.chapters[i].volume == ch.volume; i++ {
+			fmt.Fprintf(&b, "<li><a href=\"%s\">%s</a></li>\n", w.chapters[i].file, escape(w.chapters[i].title))
+		}
+		b.WriteString("</ol>\n</li>\n")
+	}
+
+	b.WriteString("</ol>\n</nav>")
+	return w.page(w.meta.Title, b.String())
+}
+
+func (w *Writer) opf() string {
+	var b strings.Builder
+	b.WriteString(`<?xml version="1.0" encoding="UTF-8"?>
+<package xmlns="http://www.idpf.org/2007/opf" version="3.0" unique-identifier="book-id">
+<metadata xmlns:dc="http://purl.org/dc/elements/1.1/">
+`)
+	fmt.Fprintf(&b, "<dc:identifier id=\"book-id\">%s</dc:identifier>\n", escape(w.meta.Identifier))
+	fmt.Fprintf(&b, "<dc:title>%s</dc:title>\n", escape(w.meta.Title))
+	fmt.Fprintf(&b, "<dc:language>%s</dc:language>\n", escape(w.meta.Language))
+	if w.meta.Author != "" {
+		fmt.Fprintf(&b, "<dc:creator>%s</dc:creator>\n", escape(w.meta.Author))
+	}
+	if w.meta.Description != "" {
+		fmt.Fprintf(&b, "<dc:description>%s</dc:description>\n", escape(w.meta.Description))
+	}
+	fmt.Fprintf(&b, "<meta property=\"dcterms:modified\">%s</meta>\n", w.meta.Modified.UTC().Format("2006-01-02T15:04:05Z"))
+	if w.cover != "" {
+		b.WriteString("<meta name=\"cover\" content=\"cover-image\"/>\n")
+	}
+	b.WriteString("</metadata>\n<manifest>\n")
+
+	b.WriteString("<item id=\"nav\" href=\"nav.xhtml\" media-type=\"application/xhtml+xml\" properties=\"nav\"/>\n")
+	if w.cover != "" {
+		mediaType := map[string]string{"jpg": "image/jpeg", "png": "image/png", "gif": "image/gif"}[w.coverExt]
+		fmt.Fprintf(&b, "<item id=\"cover-image\" href=\"images/cover.%s\" media-type=\"%s\" properties=\"cover-image\"/>\n", w.coverExt, mediaType)
+		b.WriteString("<item id=\"cover\" href=\"cover.xhtml\" media-type=\"application/xhtml+xml\"/>\n")
+	}
+	for i, ch := range w.chapters {
+		fmt.Fprintf(&b, "<item id=\"chapter-%d\" href=\"%s\" media-type=\"application/xhtml+xml\"/>\n", i+1, ch.file)
+	}
+
+	b.WriteString("</manifest>\n<spine>\n")
+	if w.cover != "" {
+		b.WriteString("<itemref idref=\"cover\" linear=\"no\"/>\n")
+	}
+	for i := range w.chapters {
+		fmt.Fprintf(&b, "<itemref idref=\"chapter-%d\"/>\n", i+1)
+	}
+	b.WriteString("</spine>\n</package>\n")
+	return b.String()
+}
+
+// escape escapes s for XML text and attributes and drops the runes XML 1.0 forbids.
+func escape(s string) string {
+	s = strings.Map(func(r rune) rune {
+		switch {
+		case r == '\t' || r == '\n' || r == '\r':
+			return r
+		case r < 0x20, r == utf8.RuneError, r >= 0xFFFE && r <= 0xFFFF:
+			return -1
+		}
+		return r
+	}, s)
+	return html.EscapeString(s)
+}
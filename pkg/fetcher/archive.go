@@ -0,0 +1,181 @@
+package fetcher
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+)
+
+var ErrNotArchived = errors.New("fetcher: response not archived")
+
+// Record is an archived response. The body is kept as received, still compressed when
+// the server compressed it.
+type Record struct {
+	Method     string      `json:"method"`
+	URL        string      `json:"url"`
+	StatusCode int         `json:"status_code"`
+	Header     http.Header `json:"header"`
+	Body       []byte      `json:"-"`
+}
+
+// Archive keeps responses on disk, a JSON file with the status and headers and a file
+// with the body for every request, both named after the hash of the method and url.
+// Recording a crawl then replaying it runs the crawler without network.
+type Archive struct {
+	dir string
+}
+
+func OpenArchive(dir string) (*Archive, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	return &Archive{dir: dir}, nil
+}
+
+func (a *Archive) Save(record *Record) error {
+	meta, err := json.MarshalIndent(record, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	name := a.name(record.Method, record.URL)
+	// the body first, a json file always has its body
+	if err := writeFileAtomic(name+".body", record.Body); err != nil {
+		return err
+	}
+	return writeFileAtomic(name+".json", meta)
+}
+
+func (a *Archive) Load(method, rawURL string) (*Record, error) {
+	name := a.name(method, rawURL)
+	meta, err := os.ReadFile(name + ".json")
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("%w: %s %s", ErrNotArchived, method, rawURL)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	record := &Record{}
+	if err := json.Unmarshal(meta, record); err != nil {
+		return nil, fmt.Errorf("fetcher: archive %s: %w", name, err)
+	}
+	record.Body, err = os.ReadFile(name + ".body")
+	if err != nil {
+		return nil, err
+	}
+	return record, nil
+}
+
+// Recorder returns a transport saving every response of next in the archive.
+func (a *Archive) Recorder(next http.RoundTripper) http.RoundTripper {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	return &recorder{archive: a, next: next}
+}
+
+// Replayer returns a transport answering from the archive only. Requests never recorded
+// get a 404, like a page missing on the site.
+func (a *Archive) Replayer() http.RoundTripper {
+	return &replayer{archive: a}
+}
+
+func (a *Archive) name(method, rawURL string) string {
+	sum := sha256.Sum256([]byte(method + " " + rawURL))
+	return filepath.Join(a.dir, hex.EncodeToString(sum[:]))
+}
+
+type recorder struct {
+	archive *Archive
+	next    http.RoundTripper
+}
+
+func (r *recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := r.next.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+
+	err = r.archive.Save(&Record{
+		Method:     req.Method,
+		URL:        req.URL.String(),
+		StatusCode: resp.StatusCode,
+		Header:     resp.Header.Clone(),
+		Body:       body,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("fetcher: record %s: %w", req.URL, err)
+	}
+	return resp, nil
+}
+
+type replayer struct {
+	archive *Archive
+}
+
+func (r *replayer) RoundTrip(req *http.Request) (*http.Response, error) {
+	if err := req.Context().Err(); err != nil {
+		return nil, err
+	}
+
+	record, err := r.archive.Load(req.Method, req.URL.String())
+	if errors.Is(err, ErrNotArchived) {
+		record = &Record{
+			StatusCode: http.StatusNotFound,
+			Header:     http.Header{"Content-Type": {"text/plain; charset=utf-8"}},
+			Body:       []byte(err.Error()),
+		}
+	} else if err != nil {
+		return nil, err
+	}
+
+	header := record.Header.Clone()
+	if header == nil {
+		header = http.Header{}
+	}
+	header.Set("Content-Length", strconv.Itoa(len(record.Body)))
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", record.StatusCode, http.StatusText(record.StatusCode)),
+		StatusCode:    record.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(bytes.NewReader(record.Body)),
+		ContentLength: int64(len(record.Body)),
+		Request:       req,
+	}, nil
+}
+
+func writeFileAtomic(name string, content []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(name), ".archive-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(content); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), name)
+}
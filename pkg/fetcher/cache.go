@@ -0,0 +1,71 @@
+package fetcher
+
+import (
+	"container/list"
+	"net/http"
+	"sync"
+)
+
+// Entry is a cached response kept for conditional requests.
+type Entry struct {
+	ETag         string
+	LastModified string
+	Header       http.Header
+	Body         []byte
+}
+
+type Cache interface {
+	Get(url string) (*Entry, bool)
+	Set(url string, entry *Entry)
+}
+
+// MemoryCache is a size bounded LRU Cache.
+type MemoryCache struct {
+	mu         sync.Mutex
+	maxEntries int
+	ll         *list.List
+	items      map[string]*list.Element
+}
+
+type memoryItem struct {
+	url   string
+	entry *Entry
+}
+
+func NewMemoryCache(maxEntries int) *MemoryCache {
+	return &MemoryCache{
+		maxEntries: maxEntries,
+		ll:         list.New(),
+		items:      map[string]*list.Element{},
+	}
+}
+
+func (c *MemoryCache) Get(url string) (*Entry, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	el, ok := c.items[url]
+	if !ok {
+		return nil, false
+	}
+	c.ll.MoveToFront(el)
+	return el.Value.(*memoryItem).entry, true
+}
+
+func (c *MemoryCache) Set(url string, entry *Entry) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if el, ok := c.items[url]; ok {
+		el.Value.(*memoryItem).entry = entry
+		c.ll.MoveToFront(el)
+		return
+	}
+
+	c.items[url] = c.ll.PushFront(&memoryItem{url, entry})
+	for c.maxEntries > 0 && c.ll.Len() > c.maxEntries {
+		oldest := c.ll.Back()
+		c.ll.Remove(oldest)
+		delete(c.items, oldest.Value.(*memoryItem).url)
+	}
+}
@@ -0,0 +1,418 @@
+package fetcher
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+const (
+	DefaultUserAgent   = "Mozilla/5.0 (compatible; demo-cosebase-crawler/1.0)"
+	DefaultTimeout     = 30 * time.Second
+	DefaultRate        = 1.0
+	DefaultBurst       = 2
+	DefaultMaxRetries  = 4
+	DefaultBaseBackoff = 500 * time.Millisecond
+	DefaultMaxBackoff  = 30 * time.Second
+	DefaultMaxBodySize = 10 << 20
+
+	// as many as net/http follows by default
+	maxRedirects = 10
+)
+
+var (
+	ErrDisallowed = errors.New("fetcher: disallowed by robots.txt")
+	ErrTooLarge   = errors.New("fetcher: response body too large")
+	// ErrTooManyRedirects is returned after maxRedirects redirects.
+	ErrTooManyRedirects = errors.New("fetcher: too many redirects")
+)
+
+// StatusError is returned when the server answers with a status code that is
+// neither successful nor retryable, or when retries are exhausted.
+type StatusError struct {
+	URL        string
+	StatusCode int
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("fetcher: %s returned %d %s", e.URL, e.StatusCode, http.StatusText(e.StatusCode))
+}
+
+type Config struct {
+	UserAgent string
+	// Timeout bounds a single attempt, including reading the body.
+	Timeout time.Duration
+	// Rate is the number of requests per second allowed for each host.
+	Rate  float64
+	Burst int
+	// MaxRetries is the number of extra attempts made on 429 and 5xx responses.
+	MaxRetries  int
+	BaseBackoff time.Duration
+	MaxBackoff  time.Duration
+	MaxBodySize int64
+	// IgnoreRobots disables robots.txt checks, it should only be used against hosts we own.
+	IgnoreRobots bool
+	// Cache keeps validators (ETag, Last-Modified) and bodies for conditional requests.
+	// A nil Cache disables conditional requests.
+	Cache Cache
+	// Transport is used for every request, http.DefaultTransport when nil.
+	Transport http.RoundTripper
+}
+
+func DefaultConfig() *Config {
+	return &Config{
+		UserAgent:   DefaultUserAgent,
+		Timeout:     DefaultTimeout,
+		Rate:        DefaultRate,
+		Burst:       DefaultBurst,
+		MaxRetries:  DefaultMaxRetries,
+		BaseBackoff: DefaultBaseBackoff,
+		MaxBackoff:  DefaultMaxBackoff,
+		MaxBodySize: DefaultMaxBodySize,
+		Cache:       NewMemoryCache(1000),
+	}
+}
+
+type Response struct {
+	URL        string
+	StatusCode int
+	Header     http.Header
+	Body       []byte
+	// FromCache is true when the server answered 304 and Body comes from the cache.
+	FromCache bool
+}
+
+type Fetcher struct {
+	cfg    Config
+	client *http.Client
+
+	mu       sync.Mutex
+	limiters map[string]*rate.Limiter
+	robots   map[string]*robots
+}
+
+func New(cfg *Config) *Fetcher {
+	if cfg == nil {
+		cfg = DefaultConfig()
+	}
+	c := *cfg
+	if c.UserAgent == "" {
+		c.UserAgent = DefaultUserAgent
+	}
+	if c.Timeout <= 0 {
+		c.Timeout = DefaultTimeout
+	}
+	if c.Rate <= 0 {
+		c.Rate = DefaultRate
+	}
+	if c.Burst <= 0 {
+		c.Burst = DefaultBurst
+	}
+	if c.MaxRetries < 0 {
+		c.MaxRetries = 0
+	}
+	if c.BaseBackoff <= 0 {
+		c.BaseBackoff = DefaultBaseBackoff
+	}
+	if c.MaxBackoff <= 0 {
+		c.MaxBackoff = DefaultMaxBackoff
+	}
+	if c.MaxBodySize <= 0 {
+		c.MaxBodySize = DefaultMaxBodySize
+	}
+	transport := c.Transport
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+
+	f := &Fetcher{
+		cfg:      c,
+		limiters: map[string]*rate.Limiter{},
+		robots:   map[string]*robots{},
+	}
+	f.client = &http.Client{
+		Transport:     transport,
+		CheckRedirect: f.checkRedirect,
+	}
+	return f
+}
+
+// checkRedirect applies robots.txt and the host rate limiter to redirect targets, which
+// may be on another host than the URL Fetch checked.
+func (f *Fetcher) checkRedirect(req *http.Request, via []*http.Request) error {
+	if len(via) >= maxRedirects {
+		return fmt.Errorf("%w: stopped after %d", ErrTooManyRedirects, maxRedirects)
+	}
+
+	// robots.txt is always allowed, checking it while fetching it would loop
+	if !f.cfg.IgnoreRobots && req.URL.Path != "/robots.txt" {
+		rules, err := f.robotsFor(req.Context(), req.URL)
+		if err != nil {
+			return err
+		}
+		if !rules.allowed(req.URL.RequestURI()) {
+			return fmt.Errorf("%w: %s", ErrDisallowed, req.URL)
+		}
+	}
+	return f.limiter(req.URL.Host).Wait(req.Context())
+}
+
+// FetchContent returns the body of rawURL as a string.
+func (f *Fetcher) FetchContent(ctx context.Context, rawURL string) (string, error) {
+	resp, err := f.Fetch(ctx, rawURL)
+	if err != nil {
+		return "", err
+	}
+	return string(resp.Body), nil
+}
+
+// Fetch performs a GET on rawURL, waiting for the host rate limiter, checking robots.txt,
+// retrying 429 and 5xx answers with exponential back-off and sending conditional headers
+// when a previous response is cached.
+func (f *Fetcher) Fetch(ctx context.Context, rawURL string) (*Response, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("fetcher: unsupported scheme %q", u.Scheme)
+	}
+	normalizeURL(u)
+
+	if !f.cfg.IgnoreRobots {
+		rules, err := f.robotsFor(ctx, u)
+		if err != nil {
+			return nil, err
+		}
+		if !rules.allowed(u.RequestURI()) {
+			return nil, fmt.Errorf("%w: %s", ErrDisallowed, rawURL)
+		}
+	}
+
+	var cached *Entry
+	if f.cfg.Cache != nil {
+		// keyed like the entries do stores, by the parsed URL
+		cached, _ = f.cfg.Cache.Get(u.String())
+	}
+
+	for attempt := 0; ; attempt++ {
+		resp, retryAfter, err := f.do(ctx, u, cached)
+		if err == nil {
+			return resp, nil
+		}
+
+		if ctx.Err() != nil || attempt >= f.cfg.MaxRetries || !retryable(err) {
+			return nil, err
+		}
+
+		if err := sleep(ctx, f.backoff(attempt, retryAfter)); err != nil {
+			return nil, err
+		}
+	}
+}
+
+func (f *Fetcher) do(ctx context.Context, u *url.URL, cached *Entry) (*Response, time.Duration, error) {
+	if err := f.limiter(u.Host).Wait(ctx); err != nil {
+		return nil, 0, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, f.cfg.Timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	req.Header.Set("User-Agent", f.cfg.UserAgent)
+	req.Header.Set("Accept-Encoding", "gzip")
+	if cached != nil {
+		if cached.ETag != "" {
+			req.Header.Set("If-None-Match", cached.ETag)
+		}
+		if cached.LastModified != "" {
+			req.Header.Set("If-Modified-Since", cached.LastModified)
+		}
+	}
+
+	resp, err := f.client.Do(req)
+	if err != nil {
+		// refused redirects are answers, not network failures
+		if errors.Is(err, ErrDisallowed) || errors.Is(err, ErrTooManyRedirects) {
+			return nil, 0, err
+		}
+		return nil, 0, &temporaryError{err}
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusNotModified && cached != nil:
+		//nolint:errcheck
+		io.Copy(io.Discard, io.LimitReader(resp.Body, f.cfg.MaxBodySize))
+		return &Response{
+			URL:        u.String(),
+			StatusCode: http.StatusOK,
+			Header:     cached.Header.Clone(),
+			Body:       cached.Body,
+			FromCache:  true,
+		}, 0, nil
+	case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500:
+		//nolint:errcheck
+		io.Copy(io.Discard, io.LimitReader(resp.Body, f.cfg.MaxBodySize))
+		return nil, parseRetryAfter(resp.Header.Get("Retry-After")), &temporaryError{&StatusError{u.String(), resp.StatusCode}}
+	case resp.StatusCode < 200 || resp.StatusCode > 299:
+		return nil, 0, &StatusError{u.String(), resp.StatusCode}
+	}
+
+	body, err := f.readBody(resp)
+	if err != nil {
+		if errors.Is(err, ErrTooLarge) {
+			return nil, 0, err
+		}
+		return nil, 0, &temporaryError{err}
+	}
+
+	if f.cfg.Cache != nil {
+		etag, lastModified := resp.Header.Get("ETag"), resp.Header.Get("Last-Modified")
+		if etag != "" || lastModified != "" {
+			f.cfg.Cache.Set(u.String(), &Entry{
+				ETag:         etag,
+				LastModified: lastModified,
+				Header:       resp.Header.Clone(),
+				Body:         body,
+			})
+		}
+	}
+
+	return &Response{
+		URL:        u.String(),
+		StatusCode: resp.StatusCode,
+		Header:     resp.Header,
+		Body:       body,
+	}, 0, nil
+}
+
+// normalizeURL drops what does not change the resource, the fragment and the default
+// port, so spellings of a URL share their cache entry.
+func normalizeURL(u *url.URL) {
+	u.Fragment, u.RawFragment = "", ""
+	u.Host = strings.ToLower(u.Host)
+	if port := u.Port(); (u.Scheme == "http" && port == "80") || (u.Scheme == "https" && port == "443") {
+		u.Host = strings.TrimSuffix(u.Host, ":"+port)
+	}
+	if u.Path == "" && u.Opaque == "" {
+		u.Path = "/"
+	}
+}
+
+func (f *Fetcher) readBody(resp *http.Response) ([]byte, error) {
+	var reader io.Reader = resp.Body
+	if strings.EqualFold(resp.Header.Get("Content-Encoding"), "gzip") {
+		gz, err := gzip.NewReader(resp.Body)
+		if err != nil {
+			return nil, err
+		}
+		defer gz.Close()
+		reader = gz
+		resp.Header.Del("Content-Encoding")
+		resp.Header.Del("Content-Length")
+	}
+
+	var buf bytes.Buffer
+	n, err := io.Copy(&buf, io.LimitReader(reader, f.cfg.MaxBodySize+1))
+	if err != nil {
+		return nil, err
+	}
+	if n > f.cfg.MaxBodySize {
+		return nil, fmt.Errorf("%w: more than %d bytes from %s", ErrTooLarge, f.cfg.MaxBodySize, resp.Request.URL)
+	}
+	return buf.Bytes(), nil
+}
+
+func (f *Fetcher) limiter(host string) *rate.Limiter {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	l, ok := f.limiters[host]
+	if !ok {
+		l = rate.NewLimiter(rate.Limit(f.cfg.Rate), f.cfg.Burst)
+		f.limiters[host] = l
+	}
+	return l
+}
+
+func (f *Fetcher) backoff(attempt int, retryAfter time.Duration) time.Duration {
+	if retryAfter > 0 {
+		if retryAfter > f.cfg.MaxBackoff {
+			return f.cfg.MaxBackoff
+		}
+		return retryAfter
+	}
+
+	d := f.cfg.BaseBackoff << attempt
+	if d <= 0 || d > f.cfg.MaxBackoff {
+		d = f.cfg.MaxBackoff
+	}
+	// full jitter on the upper half keeps concurrent workers from retrying in lockstep
+	half := d / 2
+	return half + time.Duration(rand.Int63n(int64(half)+1))
+}
+
+type temporaryError struct {
+	err error
+}
+
+func (e *temporaryError) Error() string {
+	return e.err.Error()
+}
+
+func (e *temporaryError) Unwrap() error {
+	return e.err
+}
+
+func retryable(err error) bool {
+	var t *temporaryError
+	return errors.As(err, &t)
+}
+
+func parseRetryAfter(v string) time.Duration {
+	v = strings.TrimSpace(v)
+	if v == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(v); err == nil {
+		if seconds < 0 {
+			return 0
+		}
+		return time.Duration(seconds) * time.Second
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		if d := time.Until(t); d > 0 {
+			return d
+		}
+	}
+	return 0
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,305 @@
+package fetcher_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"demo-cosebase/pkg/fetcher"
+)
+
+// testConfig fetches fast, the tests that need a limit or a back-off set their own.
+func testConfig() *fetcher.Config {
+	cfg := fetcher.DefaultConfig()
+	cfg.Rate = 1000
+	cfg.Burst = 1000
+	cfg.BaseBackoff = time.Millisecond
+	cfg.MaxBackoff = 10 * time.Millisecond
+	cfg.Timeout = 5 * time.Second
+	return cfg
+}
+
+func serve(t *testing.T, handler http.Handler) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestFetchRateLimit(t *testing.T) {
+	server := serve(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	cfg := testConfig()
+	cfg.IgnoreRobots = true
+	cfg.Rate = 20
+	cfg.Burst = 1
+	f := fetcher.New(cfg)
+
+	started := time.Now()
+	for range 4 {
+		if _, err := f.Fetch(context.Background(), server.URL+"/"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// the first request uses the burst, the next three wait 50ms each
+	if elapsed := time.Since(started); elapsed < 130*time.Millisecond {
+		t.Fatalf("4 requests at 20/s took %s", elapsed)
+	}
+}
+
+func TestFetchRetry(t *testing.T) {
+	var attempts atomic.Int32
+	server := serve(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/flaky":
+			if attempts.Add(1) < 3 {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			w.Write([]byte("ok"))
+		case "/down":
+			attempts.Add(1)
+			w.WriteHeader(http.StatusInternalServerError)
+		case "/missing":
+			attempts.Add(1)
+			w.WriteHeader(http.StatusNotFound)
+		case "/busy":
+			if attempts.Add(1) == 1 {
+				w.Header().Set("Retry-After", "1")
+				w.WriteHeader(http.StatusTooManyRequests)
+				return
+			}
+			w.Write([]byte("ok"))
+		}
+	}))
+	cfg := testConfig()
+	cfg.IgnoreRobots = true
+	cfg.MaxRetries = 2
+	f := fetcher.New(cfg)
+	ctx := context.Background()
+
+	t.Run("recovers", func(t *testing.T) {
+		attempts.Store(0)
+		content, err := f.FetchContent(ctx, server.URL+"/flaky")
+		if err != nil || content != "ok" {
+			t.Fatalf("got %q, %v", content, err)
+		}
+		if n := attempts.Load(); n != 3 {
+			t.Fatalf("%d attempts, want 3", n)
+		}
+	})
+
+	t.Run("gives up", func(t *testing.T) {
+		attempts.Store(0)
+		_, err := f.Fetch(ctx, server.URL+"/down")
+		var status *fetcher.StatusError
+		if !errors.As(err, &status) || status.StatusCode != http.StatusInternalServerError {
+			t.Fatalf("got %v, want a 500 StatusError", err)
+		}
+		if n := attempts.Load(); n != 3 {
+			t.Fatalf("%d attempts, want 1 and 2 retries", n)
+		}
+	})
+
+	t.Run("client errors are not retried", func(t *testing.T) {
+		attempts.Store(0)
+		_, err := f.Fetch(ctx, server.URL+"/missing")
+		var status *fetcher.StatusError
+		if !errors.As(err, &status) || status.StatusCode != http.StatusNotFound {
+			t.Fatalf("got %v, want a 404 StatusError", err)
+		}
+		if n := attempts.Load(); n != 1 {
+			t.Fatalf("%d attempts, want 1", n)
+		}
+	})
+
+	t.Run("honors Retry-After", func(t *testing.T) {
+		attempts.Store(0)
+		cfg := testConfig()
+		cfg.IgnoreRobots = true
+		cfg.MaxBackoff = 5 * time.Second
+		f := fetcher.New(cfg)
+
+		started := time.Now()
+		if _, err := f.Fetch(ctx, server.URL+"/busy"); err != nil {
+			t.Fatal(err)
+		}
+		if elapsed := time.Since(started); elapsed < time.Second {
+			t.Fatalf("retried after %s, want the 1s of Retry-After", elapsed)
+		}
+	})
+}
+
+func TestFetchConditional(t *testing.T) {
+	var full, notModified atomic.Int32
+	server := serve(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/etag":
+			if r.Header.Get("If-None-Match") == `"v1"` {
+				notModified.Add(1)
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+			w.Header().Set("ETag", `"v1"`)
+		case "/last-modified":
+			if r.Header.Get("If-Modified-Since") == "Mon, 02 Jan 2006 15:04:05 GMT" {
+				notModified.Add(1)
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+			w.Header().Set("Last-Modified", "Mon, 02 Jan 2006 15:04:05 GMT")
+		}
+		full.Add(1)
+		w.Write([]byte("chapter " + r.URL.Path))
+	}))
+	cfg := testConfig()
+	cfg.IgnoreRobots = true
+	f := fetcher.New(cfg)
+	ctx := context.Background()
+
+	for _, path := range []string{"/etag", "/last-modified"} {
+		t.Run(path, func(t *testing.T) {
+			full.Store(0)
+			notModified.Store(0)
+
+			first, err := f.Fetch(ctx, server.URL+path)
+			if err != nil || first.FromCache {
+				t.Fatalf("first fetch: %+v, %v", first, err)
+			}
+			// the fragment is not part of the resource, the cached entry applies
+			second, err := f.Fetch(ctx, server.URL+path+"#comments")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !second.FromCache || second.StatusCode != http.StatusOK || string(second.Body) != "chapter "+path {
+				t.Fatalf("second fetch: got %d %q from cache %v", second.StatusCode, second.Body, second.FromCache)
+			}
+			if full.Load() != 1 || notModified.Load() != 1 {
+				t.Fatalf("%d full and %d 304 answers, want 1 of each", full.Load(), notModified.Load())
+			}
+		})
+	}
+}
+
+const testRobots = `
+User-agent: bot
+Allow: /
+
+User-agent: Demo-Cosebase-Crawler
+Disallow: /private
+Allow: /private/open
+
+User-agent: *
+Disallow: /
+`
+
+func TestFetchRobots(t *testing.T) {
+	var hits atomic.Int32
+	server := serve(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/robots.txt" {
+			w.Write([]byte(testRobots))
+			return
+		}
+		hits.Add(1)
+	}))
+	f := fetcher.New(testConfig())
+	ctx := context.Background()
+
+	tests := []struct {
+		path    string
+		allowed bool
+	}{
+		// our group overrides "*"
+		{"/story/1", true},
+		{"/private/notes", false},
+		{"/private/open/1", true},
+	}
+	for _, test := range tests {
+		hits.Store(0)
+		_, err := f.Fetch(ctx, server.URL+test.path)
+		if test.allowed && err != nil {
+			t.Errorf("%s: %v", test.path, err)
+		}
+		if !test.allowed && !errors.Is(err, fetcher.ErrDisallowed) {
+			t.Errorf("%s: got %v, want ErrDisallowed", test.path, err)
+		}
+		if test.allowed != (hits.Load() == 1) {
+			t.Errorf("%s: %d requests reached the server", test.path, hits.Load())
+		}
+	}
+
+	t.Run("other crawler", func(t *testing.T) {
+		cfg := testConfig()
+		cfg.UserAgent = "Mozilla/5.0 (compatible; storybot/2.1)"
+		if _, err := fetcher.New(cfg).Fetch(ctx, server.URL+"/story/1"); !errors.Is(err, fetcher.ErrDisallowed) {
+			t.Fatalf("got %v, want the * group to apply and not the bot one", err)
+		}
+	})
+
+	t.Run("unreachable robots.txt", func(t *testing.T) {
+		server := serve(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}))
+		if _, err := f.Fetch(ctx, server.URL+"/story/1"); !errors.Is(err, fetcher.ErrDisallowed) {
+			t.Fatalf("got %v, want ErrDisallowed", err)
+		}
+	})
+
+	t.Run("missing robots.txt", func(t *testing.T) {
+		server := serve(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path == "/robots.txt" {
+				w.WriteHeader(http.StatusNotFound)
+			}
+		}))
+		if _, err := f.Fetch(ctx, server.URL+"/story/1"); err != nil {
+			t.Fatal(err)
+		}
+	})
+}
+
+func TestFetchRedirect(t *testing.T) {
+	var privateHits atomic.Int32
+	mux := http.NewServeMux()
+	mux.HandleFunc("/robots.txt", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("User-agent: *\nDisallow: /private\n"))
+	})
+	mux.HandleFunc("/old", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/new", http.StatusMovedPermanently)
+	})
+	mux.HandleFunc("/new", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("new"))
+	})
+	mux.HandleFunc("/hidden", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/private/page", http.StatusFound)
+	})
+	mux.HandleFunc("/private/", func(w http.ResponseWriter, r *http.Request) {
+		privateHits.Add(1)
+	})
+	mux.HandleFunc("/loop", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/loop", http.StatusFound)
+	})
+	server := serve(t, mux)
+	f := fetcher.New(testConfig())
+	ctx := context.Background()
+
+	resp, err := f.Fetch(ctx, server.URL+"/old")
+	if err != nil || string(resp.Body) != "new" {
+		t.Fatalf("followed redirect: got %+v, %v", resp, err)
+	}
+
+	if _, err := f.Fetch(ctx, server.URL+"/hidden"); !errors.Is(err, fetcher.ErrDisallowed) {
+		t.Fatalf("redirect to a disallowed path: got %v, want ErrDisallowed", err)
+	}
+	if n := privateHits.Load(); n != 0 {
+		t.Fatalf("the disallowed target was requested %d times", n)
+	}
+
+	_, err = f.Fetch(ctx, server.URL+"/loop")
+	if !errors.Is(err, fetcher.ErrTooManyRedirects) || !strings.Contains(err.Error(), "/loop") {
+		t.Fatalf("redirect loop: got %v, want ErrTooManyRedirects", err)
+	}
+}
@@ -0,0 +1,260 @@
+package fetcher
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+const (
+	robotsTTL = 24 * time.Hour
+	// an unreachable robots.txt disallows the host, for a short while so an outage of it
+	// does not stop crawling for a day
+	robotsFailureTTL = 5 * time.Minute
+)
+
+type robotsRule struct {
+	allow   bool
+	pattern string
+}
+
+type robots struct {
+	rules       []robotsRule
+	disallowAll bool
+	crawlDelay  time.Duration
+	fetchedAt   time.Time
+	ttl         time.Duration
+}
+
+// allowed applies the longest match rule from RFC 9309, allow wins on ties.
+func (r *robots) allowed(path string) bool {
+	if r.disallowAll {
+		return false
+	}
+	if path == "" {
+		path = "/"
+	}
+	if path == "/robots.txt" {
+		return true
+	}
+
+	best, allow := -1, true
+	for _, rule := range r.rules {
+		if !matchRobotsPattern(rule.pattern, path) {
+			continue
+		}
+		l := len(rule.pattern)
+		if l > best || (l == best && rule.allow) {
+			best, allow = l, rule.allow
+		}
+	}
+	return allow
+}
+
+func (f *Fetcher) robotsFor(ctx context.Context, u *url.URL) (*robots, error) {
+	key := u.Scheme + "://" + u.Host
+
+	f.mu.Lock()
+	r, ok := f.robots[key]
+	f.mu.Unlock()
+	if ok && time.Since(r.fetchedAt) < r.ttl {
+		return r, nil
+	}
+
+	r, err := f.fetchRobots(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+
+	f.mu.Lock()
+	f.robots[key] = r
+	f.mu.Unlock()
+
+	if r.crawlDelay > 0 {
+		if limit := rate.Every(r.crawlDelay); limit < rate.Limit(f.cfg.Rate) {
+			f.limiter(u.Host).SetLimit(limit)
+		}
+	}
+	return r, nil
+}
+
+func (f *Fetcher) fetchRobots(ctx context.Context, origin string) (*robots, error) {
+	u, err := url.Parse(origin + "/robots.txt")
+	if err != nil {
+		return nil, err
+	}
+
+	if err := f.limiter(u.Host).Wait(ctx); err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, f.cfg.Timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", f.cfg.UserAgent)
+
+	resp, err := f.client.Do(req)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, err
+		}
+		// unreachable robots.txt means complete disallow, RFC 9309 section 2.3.1.4
+		return &robots{disallowAll: true, fetchedAt: time.Now(), ttl: robotsFailureTTL}, nil
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode >= 500:
+		return &robots{disallowAll: true, fetchedAt: time.Now(), ttl: robotsFailureTTL}, nil
+	case resp.StatusCode >= 400:
+		return &robots{fetchedAt: time.Now(), ttl: robotsTTL}, nil
+	}
+
+	// RFC 9309 requires parsing at least 500 KiB
+	r := parseRobots(io.LimitReader(resp.Body, 512<<10), f.cfg.UserAgent)
+	r.fetchedAt = time.Now()
+	r.ttl = robotsTTL
+	return r, nil
+}
+
+// productToken returns the crawler name robots.txt groups are matched against, the
+// name of the last "name/version" product of userAgent, e.g. "demo-cosebase-crawler"
+// for DefaultUserAgent.
+func productToken(userAgent string) string {
+	fields := strings.FieldsFunc(userAgent, func(r rune) bool {
+		return r == ' ' || r == '(' || r == ')' || r == ';'
+	})
+	product := ""
+	for _, field := range fields {
+		if name, _, ok := strings.Cut(field, "/"); ok && name != "" {
+			product = name
+		}
+	}
+	if product == "" && len(fields) > 0 {
+		product = fields[0]
+	}
+	return product
+}
+
+// parseRobots keeps the group whose user-agent is the product token of userAgent, compared
+// case-insensitively and whole as RFC 9309 requires, falling back to the "*" group.
+func parseRobots(reader io.Reader, userAgent string) *robots {
+	product := productToken(userAgent)
+
+	type group struct {
+		agents     []string
+		rules      []robotsRule
+		crawlDelay time.Duration
+	}
+
+	var groups []*group
+	var current *group
+	inAgents := false
+
+	scanner := bufio.NewScanner(reader)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if i := strings.IndexByte(line, '#'); i >= 0 {
+			line = line[:i]
+		}
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		key = strings.ToLower(strings.TrimSpace(key))
+		value = strings.TrimSpace(value)
+
+		switch key {
+		case "user-agent":
+			if !inAgents {
+				current = &group{}
+				groups = append(groups, current)
+			}
+			current.agents = append(current.agents, strings.ToLower(value))
+			inAgents = true
+		case "allow", "disallow":
+			inAgents = false
+			if current == nil || (key == "disallow" && value == "") {
+				continue
+			}
+			current.rules = append(current.rules, robotsRule{allow: key == "allow", pattern: value})
+		case "crawl-delay":
+			inAgents = false
+			if current == nil {
+				continue
+			}
+			if seconds, err := strconv.ParseFloat(value, 64); err == nil && seconds > 0 {
+				current.crawlDelay = time.Duration(seconds * float64(time.Second))
+			}
+		default:
+			inAgents = false
+		}
+	}
+
+	var matched, wildcard *group
+	for _, g := range groups {
+		for _, agent := range g.agents {
+			if agent == "*" {
+				if wildcard == nil {
+					wildcard = g
+				}
+				continue
+			}
+			if matched == nil && agent != "" && strings.EqualFold(agent, product) {
+				matched = g
+			}
+		}
+	}
+	if matched == nil {
+		matched = wildcard
+	}
+	if matched == nil {
+		return &robots{}
+	}
+	return &robots{rules: matched.rules, crawlDelay: matched.crawlDelay}
+}
+
+// matchRobotsPattern supports the "*" and "$" wildcards.
+func matchRobotsPattern(pattern, path string) bool {
+	anchored := strings.HasSuffix(pattern, "$")
+	if anchored {
+		pattern = strings.TrimSuffix(pattern, "$")
+	}
+
+	parts := strings.Split(pattern, "*")
+	if len(parts) == 1 {
+		if anchored {
+			return path == pattern
+		}
+		return strings.HasPrefix(path, pattern)
+	}
+
+	if !strings.HasPrefix(path, parts[0]) {
+		return false
+	}
+	rest := path[len(parts[0]):]
+	last := parts[len(parts)-1]
+	for _, part := range parts[1 : len(parts)-1] {
+		i := strings.Index(rest, part)
+		if i < 0 {
+			return false
+		}
+		rest = rest[i+len(part):]
+	}
+
+	if anchored {
+		return strings.HasSuffix(rest, last)
+	}
+	return strings.Contains(rest, last)
+}
@@ -0,0 +1,50 @@
+package metrics
+
+import (
+	"demo-cosebase/pkg/caching"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	cacheRequests = prometheus.NewDesc(prometheus.BuildFQName(namespace, "cache", "requests_total"),
+		"UseCache lookups by key prefix and result (hit, stale, miss, error).", []string{"prefix", "result"}, nil)
+	cacheLoadErrors = prometheus.NewDesc(prometheus.BuildFQName(namespace, "cache", "load_errors_total"),
+		"UseCache callbacks that failed, by key prefix.", []string{"prefix"}, nil)
+	cacheGet = prometheus.NewDesc(prometheus.BuildFQName(namespace, "cache", "get_duration_seconds"),
+		"Latency of cache reads by key prefix.", []string{"prefix"}, nil)
+	cacheLoad = prometheus.NewDesc(prometheus.BuildFQName(namespace, "cache", "load_duration_seconds"),
+		"Latency of UseCache callbacks by key prefix.", []string{"prefix"}, nil)
+)
+
+// cacheCollector turns caching.Snapshot into Prometheus metrics.
+type cacheCollector struct{}
+
+func NewCacheCollector() prometheus.Collector {
+	return cacheCollector{}
+}
+
+func (cacheCollector) Describe(ch chan<- *prometheus.Desc) {
+	for _, desc := range []*prometheus.Desc{cacheRequests, cacheLoadErrors, cacheGet, cacheLoad} {
+		ch <- desc
+	}
+}
+
+func (cacheCollector) Collect(ch chan<- prometheus.Metric) {
+	for _, m := range caching.Snapshot() {
+		for result, n := range map[string]int64{"hit": m.Hits, "stale": m.Stale, "miss": m.Misses, "error": m.Errors} {
+			ch <- prometheus.MustNewConstMetric(cacheRequests, prometheus.CounterValue, float64(n), m.Prefix, result)
+		}
+		ch <- prometheus.MustNewConstMetric(cacheLoadErrors, prometheus.CounterValue, float64(m.LoadErrors), m.Prefix)
+		ch <- histogram(cacheGet, m.Get, m.Prefix)
+		ch <- histogram(cacheLoad, m.Load, m.Prefix)
+	}
+}
+
+func histogram(desc *prometheus.Desc, h caching.Histogram, labels ...string) prometheus.Metric {
+	buckets := make(map[float64]uint64, len(h.Buckets))
+	for i, n := range h.Buckets {
+		buckets[caching.LatencyBuckets[i].Seconds()] = uint64(n)
+	}
+	return prometheus.MustNewConstHistogram(desc, uint64(h.Count), h.Sum, buckets, labels...)
+}
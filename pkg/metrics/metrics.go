@@ -0,0 +1,90 @@
+/*
+Package metrics exposes what the api does to Prometheus.
+
+A Registry gathers the HTTP request metrics, the Postgres and Redis pool stats,
+the cache metrics of pkg/caching and the Go runtime metrics.
+*/
+package metrics
+
+import (
+	"database/sql"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+)
+
+const namespace = "api"
+
+// HTTP counts requests and their latency by route template, so /stories/:slug is one series.
+type HTTP struct {
+	requests *prometheus.CounterVec
+	duration *prometheus.HistogramVec
+}
+
+func NewHTTP(registerer prometheus.Registerer) (*HTTP, error) {
+	m := &HTTP{
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "http_requests_total",
+			Help:      "HTTP requests by method, route template and status.",
+		}, []string{"method", "route", "status"}),
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "http_request_duration_seconds",
+			Help:      "HTTP request latency by method, route template and status.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"method", "route", "status"}),
+	}
+	for _, collector := range []prometheus.Collector{m.requests, m.duration} {
+		if err := registerer.Register(collector); err != nil {
+			return nil, err
+		}
+	}
+	return m, nil
+}
+
+func (m *HTTP) Observe(method, route string, status int, elapsed time.Duration) {
+	code := strconv.Itoa(status)
+	m.requests.WithLabelValues(method, route, code).Inc()
+	m.duration.WithLabelValues(method, route, code).Observe(elapsed.Seconds())
+}
+
+// NewRegistry returns a registry with the Go runtime, process and cache collectors.
+func NewRegistry() (*prometheus.Registry, error) {
+	registry := prometheus.NewRegistry()
+	for _, collector := range []prometheus.Collector{
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		NewCacheCollector(),
+	} {
+		if err := registry.Register(collector); err != nil {
+			return nil, err
+		}
+	}
+	return registry, nil
+}
+
+/*
+Reports the pool stats of the Postgres connection once resolve returns it.
+
+The api starts without waiting for Postgres, so the pool is looked up on every
+scrape instead of being required when the registry is built.
+*/
+type dbCollector struct {
+	resolve func() (*sql.DB, bool)
+}
+
+func NewDBCollector(resolve func() (*sql.DB, bool)) prometheus.Collector {
+	return &dbCollector{resolve}
+}
+
+// Describe sends nothing, which makes the collector unchecked: its metrics come and go.
+func (c *dbCollector) Describe(ch chan<- *prometheus.Desc) {}
+
+func (c *dbCollector) Collect(ch chan<- prometheus.Metric) {
+	if db, ok := c.resolve(); ok {
+		collectors.NewDBStatsCollector(db, "postgres").Collect(ch)
+	}
+}
@@ -0,0 +1,58 @@
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/redis/go-redis/v9"
+)
+
+var (
+	redisHits = prometheus.NewDesc(prometheus.BuildFQName(namespace, "redis_pool", "hits_total"),
+		"Times a free connection was found in the pool.", []string{"client"}, nil)
+	redisMisses = prometheus.NewDesc(prometheus.BuildFQName(namespace, "redis_pool", "misses_total"),
+		"Times a free connection was not found in the pool.", []string{"client"}, nil)
+	redisTimeouts = prometheus.NewDesc(prometheus.BuildFQName(namespace, "redis_pool", "timeouts_total"),
+		"Times waiting for a connection timed out.", []string{"client"}, nil)
+	redisTotal = prometheus.NewDesc(prometheus.BuildFQName(namespace, "redis_pool", "connections"),
+		"Connections in the pool.", []string{"client"}, nil)
+	redisIdle = prometheus.NewDesc(prometheus.BuildFQName(namespace, "redis_pool", "idle_connections"),
+		"Idle connections in the pool.", []string{"client"}, nil)
+	redisStale = prometheus.NewDesc(prometheus.BuildFQName(namespace, "redis_pool", "stale_connections_total"),
+		"Stale connections removed from the pool.", []string{"client"}, nil)
+)
+
+/*
+Reports the pool stats of named Redis clients.
+
+Clients are resolved on every scrape, so one that was never used is not
+connected for the sake of metrics: resolve returns false for it.
+*/
+type redisCollector struct {
+	names   []string
+	resolve func(name string) (redis.UniversalClient, bool)
+}
+
+func NewRedisCollector(names []string, resolve func(name string) (redis.UniversalClient, bool)) prometheus.Collector {
+	return &redisCollector{names: names, resolve: resolve}
+}
+
+func (c *redisCollector) Describe(ch chan<- *prometheus.Desc) {
+	for _, desc := range []*prometheus.Desc{redisHits, redisMisses, redisTimeouts, redisTotal, redisIdle, redisStale} {
+		ch <- desc
+	}
+}
+
+func (c *redisCollector) Collect(ch chan<- prometheus.Metric) {
+	for _, name := range c.names {
+		client, ok := c.resolve(name)
+		if !ok {
+			continue
+		}
+		stats := client.PoolStats()
+		ch <- prometheus.MustNewConstMetric(redisHits, prometheus.CounterValue, float64(stats.Hits), name)
+		ch <- prometheus.MustNewConstMetric(redisMisses, prometheus.CounterValue, float64(stats.Misses), name)
+		ch <- prometheus.MustNewConstMetric(redisTimeouts, prometheus.CounterValue, float64(stats.Timeouts), name)
+		ch <- prometheus.MustNewConstMetric(redisTotal, prometheus.GaugeValue, float64(stats.TotalConns), name)
+		ch <- prometheus.MustNewConstMetric(redisIdle, prometheus.GaugeValue, float64(stats.IdleConns), name)
+		ch <- prometheus.MustNewConstMetric(redisStale, prometheus.CounterValue, float64(stats.StaleConns), name)
+	}
+}
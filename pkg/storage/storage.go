@@ -0,0 +1,26 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"time"
+)
+
+var ErrNotFound = errors.New("storage: object not found")
+
+type ObjectInfo struct {
+	Key         string
+	Size        int64
+	ContentType string
+	ModTime     time.Time
+}
+
+// Storage is a flat object store addressed by slash separated keys, like S3 buckets.
+type Storage interface {
+	Put(ctx context.Context, key string, reader io.Reader, contentType string) error
+	// Get returns ErrNotFound when the key does not exist, the caller closes the reader.
+	Get(ctx context.Context, key string) (io.ReadCloser, *ObjectInfo, error)
+	Stat(ctx context.Context, key string) (*ObjectInfo, error)
+	Delete(ctx context.Context, key string) error
+}
@@ -0,0 +1,56 @@
+package textclean
+
+import (
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// HTMLToText extracts the text of an HTML fragment, block elements and <br> become
+// line breaks so paragraphs survive. Scripts, styles and comments are dropped.
+func HTMLToText(fragment string) string {
+	var b strings.Builder
+	skip := 0
+
+	tokenizer := html.NewTokenizer(strings.NewReader(fragment))
+	for {
+		switch tokenizer.Next() {
+		case html.ErrorToken:
+			return b.String()
+		case html.TextToken:
+			if skip == 0 {
+				b.Write(tokenizer.Text())
+			}
+		case html.StartTagToken, html.SelfClosingTagToken:
+			name, _ := tokenizer.TagName()
+			a := atom.Lookup(name)
+			switch {
+			case a == atom.Script || a == atom.Style || a == atom.Noscript:
+				skip++
+			case a == atom.Br || isBlock(a):
+				b.WriteByte('\n')
+			}
+		case html.EndTagToken:
+			name, _ := tokenizer.TagName()
+			a := atom.Lookup(name)
+			switch {
+			case a == atom.Script || a == atom.Style || a == atom.Noscript:
+				if skip > 0 {
+					skip--
+				}
+			case isBlock(a):
+				b.WriteByte('\n')
+			}
+		}
+	}
+}
+
+func isBlock(a atom.Atom) bool {
+	switch a {
+	case atom.P, atom.Div, atom.Li, atom.Ul, atom.Ol, atom.Blockquote, atom.Pre,
+		atom.H1, atom.H2, atom.H3, atom.H4, atom.H5, atom.H6, atom.Hr, atom.Tr, atom.Section, atom.Article:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,222 @@
+package textclean
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// Rules configures a Cleaner, every crawled source can have its own.
+type Rules struct {
+	// DropLines are regular expressions, a line matching any of them is removed.
+	DropLines []string `json:"drop_lines"`
+	// Remove are regular expressions whose matches are removed inside lines.
+	Remove []string `json:"remove"`
+	// SymbolRatio drops lines where symbols make up at least this share of the
+	// non-space runes, it catches decorative banners. Quotes, dashes and ellipses are
+	// not symbols, dialogue is kept. Zero disables the check.
+	SymbolRatio float64 `json:"symbol_ratio"`
+	// SymbolMinLength is the number of non-space runes under which a line is never
+	// taken for a banner, short lines are too few runes for a ratio.
+	SymbolMinLength int `json:"symbol_min_length"`
+	// MinChapterLength is the number of runes under which a chapter is considered truncated.
+	// Keep it low, author notes and announcements are real chapters of a few lines.
+	MinChapterLength int `json:"min_chapter_length"`
+	// PaywallMarkers are case-insensitive phrases shown instead of locked chapters.
+	PaywallMarkers []string `json:"paywall_markers"`
+}
+
+func DefaultRules() *Rules {
+	return &Rules{
+		// banners mixing symbols and a few words, e.g. "✪☫▬▬▬AS▬▬✡ĐÃ KỊP TÁC✡", are around 0.5
+		SymbolRatio:     0.4,
+		SymbolMinLength: 5,
+		// only a page with next to no text is taken for a truncated chapter
+		MinChapterLength: 20,
+	}
+}
+
+// LoadRules reads Rules from a JSON file.
+func LoadRules(path string) (*Rules, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	rules := DefaultRules()
+	if err := json.Unmarshal(content, rules); err != nil {
+		return nil, fmt.Errorf("textclean: %s: %w", path, err)
+	}
+	return rules, nil
+}
+
+// Merge returns a copy of r with the patterns and markers of other appended and its
+// non zero thresholds applied.
+func (r *Rules) Merge(other *Rules) *Rules {
+	merged := *r
+	merged.DropLines = append(append([]string{}, r.DropLines...), other.DropLines...)
+	merged.Remove = append(append([]string{}, r.Remove...), other.Remove...)
+	merged.PaywallMarkers = append(append([]string{}, r.PaywallMarkers...), other.PaywallMarkers...)
+	if other.SymbolRatio != 0 {
+		merged.SymbolRatio = other.SymbolRatio
+	}
+	if other.SymbolMinLength != 0 {
+		merged.SymbolMinLength = other.SymbolMinLength
+	}
+	if other.MinChapterLength != 0 {
+		merged.MinChapterLength = other.MinChapterLength
+	}
+	return &merged
+}
+
+type Verdict string
+
+const (
+	Complete  Verdict = "complete"
+	Truncated Verdict = "truncated"
+	Paywalled Verdict = "paywalled"
+)
+
+type Cleaner struct {
+	rules     Rules
+	dropLines []*regexp.Regexp
+	remove    []*regexp.Regexp
+	paywall   []string
+}
+
+func New(rules *Rules) (*Cleaner, error) {
+	if rules == nil {
+		rules = DefaultRules()
+	}
+
+	c := &Cleaner{rules: *rules}
+	for _, pattern := range rules.DropLines {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("textclean: drop line %q: %w", pattern, err)
+		}
+		c.dropLines = append(c.dropLines, re)
+	}
+	for _, pattern := range rules.Remove {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("textclean: remove %q: %w", pattern, err)
+		}
+		c.remove = append(c.remove, re)
+	}
+	for _, marker := range rules.PaywallMarkers {
+		c.paywall = append(c.paywall, strings.ToLower(norm.NFC.String(marker)))
+	}
+	return c, nil
+}
+
+// CleanHTML converts an HTML fragment to text and cleans it.
+func (c *Cleaner) CleanHTML(fragment string) string {
+	return c.CleanText(HTMLToText(fragment))
+}
+
+// CleanText normalizes text to NFC, removes banners and ads, collapses whitespace
+// and returns the paragraphs separated by a blank line.
+func (c *Cleaner) CleanText(text string) string {
+	text = norm.NFC.String(text)
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+	text = strings.ReplaceAll(text, "\r", "\n")
+
+	var paragraphs []string
+	for _, line := range strings.Split(text, "\n") {
+		if c.dropLine(line) {
+			continue
+		}
+		for _, re := range c.remove {
+			line = re.ReplaceAllString(line, "")
+		}
+		line = collapseSpaces(line)
+		if line == "" || c.decorative(line) {
+			continue
+		}
+		paragraphs = append(paragraphs, line)
+	}
+	return strings.Join(paragraphs, "\n\n")
+}
+
+// Check tells whether a cleaned chapter looks complete.
+func (c *Cleaner) Check(text string) Verdict {
+	lower := strings.ToLower(text)
+	for _, marker := range c.paywall {
+		if strings.Contains(lower, marker) {
+			return Paywalled
+		}
+	}
+	if utf8.RuneCountInString(text) < c.rules.MinChapterLength {
+		return Truncated
+	}
+	return Complete
+}
+
+func (c *Cleaner) dropLine(line string) bool {
+	for _, re := range c.dropLines {
+		if re.MatchString(line) {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *Cleaner) decorative(line string) bool {
+	if c.rules.SymbolRatio <= 0 {
+		return false
+	}
+
+	symbols, total := 0, 0
+	for _, r := range line {
+		if unicode.IsSpace(r) {
+			continue
+		}
+		total++
+		if isSymbol(r) {
+			symbols++
+		}
+	}
+	return total >= c.rules.SymbolMinLength && total > 0 && float64(symbols)/float64(total) >= c.rules.SymbolRatio
+}
+
+// isSymbol tells whether r is drawn rather than written: pictographs, box drawing
+// (both other symbols), math and modifier symbols and the underscores of separators.
+// Currency signs and all other punctuation are text.
+func isSymbol(r rune) bool {
+	return unicode.In(r, unicode.So, unicode.Sm, unicode.Sk, unicode.Pc)
+}
+
+func collapseSpaces(line string) string {
+	var b strings.Builder
+	space := false
+	for _, r := range line {
+		switch {
+		case isInvisible(r):
+			continue
+		case unicode.IsSpace(r):
+			space = true
+			continue
+		}
+		if space && b.Len() > 0 {
+			b.WriteByte(' ')
+		}
+		space = false
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+func isInvisible(r rune) bool {
+	switch r {
+	case '\u200b', '\u200c', '\u200d', '\u2060', '\ufeff', '\u00ad':
+		return true
+	}
+	return false
+}
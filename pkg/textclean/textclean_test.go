@@ -0,0 +1,178 @@
+package textclean_test
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"demo-cosebase/internal/crawler/tangthuvien"
+	"demo-cosebase/pkg/textclean"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+func fixture(t *testing.T, name string) string {
+	t.Helper()
+	content, err := os.ReadFile("testdata/" + name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(content)
+}
+
+func cleaner(t *testing.T, rules *textclean.Rules) *textclean.Cleaner {
+	t.Helper()
+	c, err := textclean.New(rules)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func paragraphs(text string) []string {
+	if text == "" {
+		return nil
+	}
+	return strings.Split(text, "\n\n")
+}
+
+func TestCleanTextDefaultRules(t *testing.T) {
+	c := cleaner(t, textclean.DefaultRules())
+
+	got := paragraphs(c.CleanText(fixture(t, "intro.txt")))
+	want := []string{
+		"Tô Hiểu ký kết Luân Hồi khế ước, tiến vào từng thế giới chấp hành nhiệm vụ.",
+		"Hắn mắt thấy một cái thế giới băng diệt thành hạt bụi, đã từng cầm đao chiến với Vương giả bị di vong.",
+		"Ám nha tại thì thầm, cự thú dưới Hắc Uyên gào thét.",
+		"Hoan nghênh đi tới, Luân Hồi Nhạc Viên....",
+		"✡✡✡Map truyện: Mời coi các chương ở quyển 0 để biết thêm chi tiết!!!!!✡✡✡",
+		"✡✡✡Chuyên mục này được chuyển vào chương 7 quyển 0 và có thêm cực nhiều thông tin hơn!!!✡✡✡",
+		"Review:",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("got paragraphs\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+
+	for _, banner := range []string{
+		"✪☫▬▬▬AS▬▬✡ĐÃ KỊP TÁC✡",
+		"✪☫▬▬▬▬▬▬▬▬▬▬▬AS▬▬✡ĐÃ KỊP TÁC✡▬▬ꙄA▬▬▬▬▬▬▬▬▬▬▬☫✪",
+		"______________________________",
+	} {
+		if got := c.CleanText(banner); got != "" {
+			t.Errorf("banner %q kept as %q", banner, got)
+		}
+	}
+}
+
+func TestCleanTextDialogue(t *testing.T) {
+	for name, rules := range map[string]*textclean.Rules{
+		"default":     textclean.DefaultRules(),
+		"tangthuvien": textclean.DefaultRules().Merge((&tangthuvien.Source{}).CleaningRules()),
+	} {
+		t.Run(name, func(t *testing.T) {
+			c := cleaner(t, rules)
+
+			got := paragraphs(c.CleanText(fixture(t, "dialogue.txt")))
+			want := []string{
+				`"Ừ."`,
+				"“Ừ.”",
+				`"Hả?"`,
+				"— Ngươi là ai?",
+				"“...”",
+				"Tô Hiểu nhìn hắn, không nói gì.",
+				"'Vâng!'",
+				"“Đi thôi…”",
+			}
+			if strings.Join(got, "\n") != strings.Join(want, "\n") {
+				t.Fatalf("got paragraphs\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+			}
+		})
+	}
+}
+
+func TestCleanTextTangThuVien(t *testing.T) {
+	c := cleaner(t, textclean.DefaultRules().Merge((&tangthuvien.Source{}).CleaningRules()))
+
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{"banner", "✪☫▬▬▬AS▬▬✡ĐÃ KỊP TÁC✡", ""},
+		{"separator", "▬▬▬▬▬▬", ""},
+		{"symbols around a note", "✡✡✡Map truyện: Mời coi các chương ở quyển 0!!!✡✡✡", "Map truyện: Mời coi các chương ở quyển 0!!!"},
+		{"source line", "Nguồn: Truyện Tàng Thư Viện", ""},
+		{"watermark link", "Hắn cười. (nguồn: https://tangthuvien.net/doc-truyen/dao-quan)", "Hắn cười."},
+		{"watermark sentence", "Ám nha tại thì thầm. Bạn đang đọc truyện mới tại tangthuvien.vn", "Ám nha tại thì thầm."},
+		{"invisible and repeated spaces", "Tô​ Hiểu   ký kết khế ước.", "Tô Hiểu ký kết khế ước."},
+		{"decomposed accents", norm.NFD.String("Luân Hồi Nhạc Viên"), "Luân Hồi Nhạc Viên"},
+		{"text", "Tô Hiểu ký kết Luân Hồi khế ước.", "Tô Hiểu ký kết Luân Hồi khế ước."},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := c.CleanText(test.in); got != test.want {
+				t.Fatalf("CleanText(%q) = %q, want %q", test.in, got, test.want)
+			}
+		})
+	}
+}
+
+func TestHTMLToText(t *testing.T) {
+	c := cleaner(t, textclean.DefaultRules())
+
+	got := paragraphs(c.CleanHTML(fixture(t, "book-info.html")))
+	want := []string{
+		"Luân Hồi Lạc Viên - 轮回乐园",
+		"Na Nhất Chích Văn Tử Đang ra",
+		"Đồng Nhân",
+		// inline elements are joined as they are
+		"795 Yêu thích|",
+		"4198704Lượt xem|",
+		"7609 Theo dõi|",
+		"0Đề cử tháng này",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("got paragraphs\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+
+	chapter := `<div id="chapter">Chương 1: Luân Hồi<br>
+<p>Tô Hiểu mở mắt.</p><p>Ám nha tại thì thầm,<br/>cự thú gào thét.</p>
+<script>var ads = "<p>quảng cáo</p>";</script><!-- <p>comment</p> --><style>p{}</style>
+<p>Hoan nghênh đi tới &amp; chúc may mắn.</p></div>`
+	got = paragraphs(c.CleanHTML(chapter))
+	want = []string{
+		"Chương 1: Luân Hồi",
+		"Tô Hiểu mở mắt.",
+		"Ám nha tại thì thầm,",
+		"cự thú gào thét.",
+		"Hoan nghênh đi tới & chúc may mắn.",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("got paragraphs\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestCheck(t *testing.T) {
+	c := cleaner(t, textclean.DefaultRules().Merge((&tangthuvien.Source{}).CleaningRules()))
+	intro := c.CleanText(fixture(t, "intro.txt"))
+
+	tests := []struct {
+		name string
+		text string
+		want textclean.Verdict
+	}{
+		{"chapter", intro, textclean.Complete},
+		{"author note", "Hôm nay tác giả bị ốm, xin nghỉ một ngày.", textclean.Complete},
+		{"empty", "", textclean.Truncated},
+		{"next to nothing", "Chương 12", textclean.Truncated},
+		{"paywall", intro + "\n\nChương này là chương VIP, vui lòng mua để đọc.", textclean.Paywalled},
+		{"paywall decomposed", c.CleanText(norm.NFD.String("Vui lòng đăng nhập để đọc tiếp.")), textclean.Paywalled},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := c.Check(test.text); got != test.want {
+				t.Fatalf("Check = %s, want %s", got, test.want)
+			}
+		})
+	}
+}
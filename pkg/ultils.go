@@ -1,6 +1,7 @@
 package pkg
 
 import (
+	"context"
 	"crypto/rand"
 	"database/sql"
 	"fmt"
@@ -13,10 +14,9 @@ import (
 	"github.com/uptrace/bun/dialect/pgdialect"
 	"github.com/uptrace/bun/driver/pgdriver"
 	"golang.org/x/text/unicode/norm"
-	"io"
 	"math/big"
 	math_rand "math/rand"
-	"net/http"
+	"net"
 	"net/url"
 	"os"
 	"regexp"
@@ -73,19 +73,6 @@ func SendMail(email *Email, auth sasl.Client) error {
 	return nil
 }
 
-func FetchContent(url string) (string, error) {
-	resp, err := http.Get(url)
-	if err != nil {
-		return "", err
-	}
-	defer resp.Body.Close()
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return "", err
-	}
-	return string(body), nil
-}
-
 func GetDb() (*bun.DB, error) {
 	sqldb := sql.OpenDB(pgdriver.NewConnector(
 		pgdriver.WithDSN(os.Getenv("DB_DSN")),
@@ -145,3 +132,22 @@ func GetStoryID(content string) int {
 	}
 	return num
 }
+
+// PingMail checks the SMTP server SendMail goes through answers, nothing is sent.
+func PingMail(ctx context.Context) error {
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", ADDRESS_SMTP)
+	if err != nil {
+		return err
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	c := smtp.NewClient(conn)
+	defer c.Close()
+	if err := c.Noop(); err != nil {
+		return err
+	}
+	return c.Quit()
+}